@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/services"
+)
+
+const usage = `usage:
+  todolist                 запуск сервера
+  todolist backup <file>   резервная копия базы данных в файл
+  todolist verify <file>   проверка резервной копии
+  todolist restore <file>  восстановление базы данных из резервной копии
+  todolist passwd <login>  задать или сменить пароль пользователя, пароль читается из стандартного ввода
+  todolist hash            вывести хэш пароля из стандартного ввода для TODO_PASSWORD
+  todolist oidc-link <login> <subject>
+                           связать пользователя провайдера OpenID Connect с учётной записью`
+
+// runCommand выполняет команду командной строки
+func runCommand(args []string) error {
+	if len(args) == 1 && args[0] == "hash" {
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		hash, err := services.HashPassword(password)
+		if err != nil {
+			return fmt.Errorf("hashing failed: %w", err)
+		}
+		fmt.Println(hash)
+		return nil
+	}
+
+	if len(args) == 3 && args[0] == "oidc-link" {
+		if err := services.LinkOIDCIdentity(config.LoadOIDCConfig(), args[1], args[2]); err != nil {
+			return fmt.Errorf("link failed: %w", err)
+		}
+		log.Printf("Identity %s is linked to %s", args[2], args[1])
+		return nil
+	}
+
+	if len(args) != 2 {
+		return errors.New(usage)
+	}
+	command, path := args[0], args[1]
+
+	switch command {
+	case "backup":
+		if err := database.Backup(path); err != nil {
+			return fmt.Errorf("backup failed: %w", err)
+		}
+		log.Printf("Backup created: %s", path)
+	case "verify":
+		if err := database.VerifyBackup(path); err != nil {
+			return fmt.Errorf("backup is invalid: %w", err)
+		}
+		log.Printf("Backup is valid: %s", path)
+	case "restore":
+		if err := database.Restore(path); err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+		log.Printf("Database restored from: %s", path)
+	case "passwd":
+		login := path
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if err := services.SetPassword(login, password); err != nil {
+			return fmt.Errorf("password change failed: %w", err)
+		}
+		log.Printf("Password changed for %s, existing sessions are closed", login)
+	default:
+		return errors.New(usage)
+	}
+
+	return nil
+}
+
+// readPassword читает пароль из первой строки ввода
+func readPassword(r io.Reader) (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
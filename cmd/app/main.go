@@ -1,20 +1,48 @@
 package main
 
 import (
-	"github.com/joho/godotenv"
+	"context"
 	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"todo-rest/internal/config"
 	"todo-rest/internal/database"
+	"todo-rest/internal/services"
 	"todo-rest/internal/transport/server"
 )
 
 func main() {
+	// Загрузка переменных окружения из файла .env
+	if err := godotenv.Load(); err != nil {
+		log.Fatalf("Error loading .env file")
+	}
+
 	// Инициализируем базу данных
 	db := database.InitDb()
 	defer db.Close()
 
-	// Загрузка переменных окружения из файла .env
-	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file")
+	// Выполняем команду командной строки вместо запуска сервера
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			db.Close()
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Запускаем резервное копирование по расписанию
+	if backup := config.LoadBackupConfig(); backup.Dir != "" {
+		go database.ScheduleBackups(context.Background(), backup.Dir, backup.Interval, backup.Keep)
+	}
+
+	// Запускаем доставку webhook из очереди
+	go services.RunWebhooks(context.Background(), config.LoadWebhookConfig(), time.Second)
+
+	// Запускаем отправку напоминаний
+	if reminders := config.LoadReminderConfig(); reminders.Enabled {
+		go services.RunReminders(context.Background(), reminders, services.NewNotifiers(reminders))
 	}
 
 	// Получаем порт и запускаем сервер
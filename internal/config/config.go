@@ -1,6 +1,13 @@
 package config
 
-import "os"
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 const DateFormat = "20060102"
 const LimitSearch = 20
@@ -8,11 +15,314 @@ const LimitSearch = 20
 type JWTConfig struct {
 	Password string
 	Secret   string
+	// Registration разрешает самостоятельную регистрацию пользователей
+	Registration bool
+	// AccessTTL — срок действия токена доступа
+	AccessTTL time.Duration
+	// RefreshTTL — срок, в течение которого сессию можно продлить refresh-токеном
+	RefreshTTL time.Duration
+	// SecureCookies отправляет куки сессии только по HTTPS, даже если запрос
+	// пришёл по HTTP от прокси без заголовка X-Forwarded-Proto
+	SecureCookies bool
 }
 
+// LoadJWTConfig читает настройки аутентификации. Токен доступа действует
+// TODO_ACCESS_TTL, а сессия продлевается refresh-токеном, если с прошлого
+// продления прошло не больше TODO_REFRESH_TTL
 func LoadJWTConfig() *JWTConfig {
-	return &JWTConfig{
-		Password: os.Getenv("TODO_PASSWORD"),
-		Secret:   os.Getenv("TODO_JWT_SECRET"),
+	cfg := &JWTConfig{
+		Password:      os.Getenv("TODO_PASSWORD"),
+		Secret:        os.Getenv("TODO_JWT_SECRET"),
+		Registration:  os.Getenv("TODO_REGISTRATION") == "open",
+		SecureCookies: os.Getenv("TODO_SECURE_COOKIES") == "on",
+		AccessTTL:     15 * time.Minute,
+		RefreshTTL:    30 * 24 * time.Hour,
 	}
+	if ttl, err := time.ParseDuration(os.Getenv("TODO_ACCESS_TTL")); err == nil && ttl > 0 {
+		cfg.AccessTTL = ttl
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("TODO_REFRESH_TTL")); err == nil && ttl > 0 {
+		cfg.RefreshTTL = ttl
+	}
+	return cfg
+}
+
+type BackupConfig struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+}
+
+// LoadBackupConfig читает настройки резервного копирования по расписанию.
+// Копирование включено, если задан каталог TODO_BACKUP_DIR
+func LoadBackupConfig() *BackupConfig {
+	cfg := &BackupConfig{
+		Dir:      os.Getenv("TODO_BACKUP_DIR"),
+		Interval: 24 * time.Hour,
+		Keep:     7,
+	}
+	if interval, err := time.ParseDuration(os.Getenv("TODO_BACKUP_INTERVAL")); err == nil && interval > 0 {
+		cfg.Interval = interval
+	}
+	if keep, err := strconv.Atoi(os.Getenv("TODO_BACKUP_KEEP")); err == nil && keep > 0 {
+		cfg.Keep = keep
+	}
+	return cfg
+}
+
+type WebhookConfig struct {
+	RetryBase   time.Duration
+	MaxAttempts int
+	Timeout     time.Duration
+	// AllowPrivate разрешает доставку на локальные и внутренние адреса
+	AllowPrivate bool
+}
+
+// LoadWebhookConfig читает настройки доставки webhook: задержку перед первой
+// повторной попыткой (далее она удваивается), число попыток и время ожидания ответа.
+// Доставка на внутренние адреса включается значением TODO_WEBHOOK_ALLOW_PRIVATE=on
+func LoadWebhookConfig() *WebhookConfig {
+	cfg := &WebhookConfig{
+		RetryBase:    30 * time.Second,
+		MaxAttempts:  8,
+		Timeout:      10 * time.Second,
+		AllowPrivate: os.Getenv("TODO_WEBHOOK_ALLOW_PRIVATE") == "on",
+	}
+	if base, err := time.ParseDuration(os.Getenv("TODO_WEBHOOK_RETRY_BASE")); err == nil && base > 0 {
+		cfg.RetryBase = base
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("TODO_WEBHOOK_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		cfg.MaxAttempts = attempts
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("TODO_WEBHOOK_TIMEOUT")); err == nil && timeout > 0 {
+		cfg.Timeout = timeout
+	}
+	return cfg
+}
+
+type ReminderConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	At       time.Duration
+	Leads    []time.Duration
+
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPTo       []string
+	SMTPUser     string
+	SMTPPassword string
+
+	WebhookURL    string
+	WebhookSecret string
+}
+
+// LoadReminderConfig читает настройки напоминаний. Напоминание о задаче приходит
+// в момент TODO_REMINDER_AT дня задачи минус каждое из опережений TODO_REMINDER_LEADS.
+// Email отправляется, если задан TODO_SMTP_ADDR, webhook — если задан TODO_REMINDER_WEBHOOK_URL,
+// в ленту уведомлений напоминания записываются всегда
+func LoadReminderConfig() *ReminderConfig {
+	cfg := &ReminderConfig{
+		Enabled:  os.Getenv("TODO_REMINDERS") != "off",
+		Interval: time.Minute,
+		At:       9 * time.Hour,
+		Leads:    []time.Duration{0},
+
+		SMTPAddr:     os.Getenv("TODO_SMTP_ADDR"),
+		SMTPFrom:     os.Getenv("TODO_SMTP_FROM"),
+		SMTPUser:     os.Getenv("TODO_SMTP_USER"),
+		SMTPPassword: os.Getenv("TODO_SMTP_PASSWORD"),
+
+		WebhookURL:    os.Getenv("TODO_REMINDER_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("TODO_REMINDER_WEBHOOK_SECRET"),
+	}
+	if interval, err := time.ParseDuration(os.Getenv("TODO_REMINDER_INTERVAL")); err == nil && interval > 0 {
+		cfg.Interval = interval
+	}
+	if at, err := time.Parse("15:04", os.Getenv("TODO_REMINDER_AT")); err == nil {
+		cfg.At = time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute
+	}
+	if leads := os.Getenv("TODO_REMINDER_LEADS"); leads != "" {
+		cfg.Leads = nil
+		for _, item := range strings.Split(leads, ",") {
+			if lead, err := time.ParseDuration(strings.TrimSpace(item)); err == nil && lead >= 0 {
+				cfg.Leads = append(cfg.Leads, lead)
+			}
+		}
+		if len(cfg.Leads) == 0 {
+			cfg.Leads = []time.Duration{0}
+		}
+	}
+	for _, to := range strings.Split(os.Getenv("TODO_SMTP_TO"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			cfg.SMTPTo = append(cfg.SMTPTo, to)
+		}
+	}
+	return cfg
+}
+
+// Правила обработки прошедших дат задач: перенос на сегодня или сохранение с отметкой о просрочке
+const (
+	OverdueClamp = "clamp"
+	OverdueKeep  = "keep"
+)
+
+// LoadOverduePolicy читает правило обработки прошедших дат из TODO_OVERDUE_POLICY.
+// По умолчанию прошедшая дата заменяется на сегодняшнюю
+func LoadOverduePolicy() string {
+	if os.Getenv("TODO_OVERDUE_POLICY") == OverdueKeep {
+		return OverdueKeep
+	}
+	return OverdueClamp
+}
+
+// Алгоритмы хэширования паролей
+const (
+	PasswordBcrypt   = "bcrypt"
+	PasswordArgon2id = "argon2id"
+)
+
+// LoadPasswordAlgo читает алгоритм хэширования новых паролей из TODO_PASSWORD_ALGO.
+// По умолчанию используется bcrypt. Проверяются хэши обоих алгоритмов
+func LoadPasswordAlgo() string {
+	if os.Getenv("TODO_PASSWORD_ALGO") == PasswordArgon2id {
+		return PasswordArgon2id
+	}
+	return PasswordBcrypt
+}
+
+type SigninConfig struct {
+	Enabled bool
+	// FreeAttempts — число неудачных попыток подряд, после которых начинаются задержки
+	FreeAttempts int
+	// Delay — задержка после первой сверх FreeAttempts неудачной попытки, далее она удваивается
+	Delay time.Duration
+	// MaxAttempts и IPMaxAttempts — число неудачных попыток для учётной записи и адреса,
+	// после которого вход блокируется на Lockout
+	MaxAttempts   int
+	IPMaxAttempts int
+	// Lockout — срок блокировки; неудачные попытки старше него не учитываются
+	Lockout time.Duration
+}
+
+// LoadSigninConfig читает настройки защиты входа от подбора пароля.
+// Защита выключается значением TODO_SIGNIN_PROTECTION=off
+func LoadSigninConfig() *SigninConfig {
+	cfg := &SigninConfig{
+		Enabled:       os.Getenv("TODO_SIGNIN_PROTECTION") != "off",
+		FreeAttempts:  3,
+		Delay:         time.Second,
+		MaxAttempts:   10,
+		IPMaxAttempts: 50,
+		Lockout:       15 * time.Minute,
+	}
+	if free, err := strconv.Atoi(os.Getenv("TODO_SIGNIN_FREE_ATTEMPTS")); err == nil && free >= 0 {
+		cfg.FreeAttempts = free
+	}
+	if delay, err := time.ParseDuration(os.Getenv("TODO_SIGNIN_DELAY")); err == nil && delay >= 0 {
+		cfg.Delay = delay
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("TODO_SIGNIN_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		cfg.MaxAttempts = attempts
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("TODO_SIGNIN_IP_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		cfg.IPMaxAttempts = attempts
+	}
+	if lockout, err := time.ParseDuration(os.Getenv("TODO_SIGNIN_LOCKOUT")); err == nil && lockout > 0 {
+		cfg.Lockout = lockout
+	}
+	return cfg
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Read, Write и NextDate — число запросов на чтение, изменяющих запросов и запросов
+	// к /api/nextdate, которые клиент может сделать за Window. Неизрасходованные запросы
+	// накапливаются не больше этого числа
+	Read     int
+	Write    int
+	NextDate int
+	Window   time.Duration
+}
+
+// LoadRateLimitConfig читает ограничения частоты запросов клиентов.
+// Ограничения выключаются значением TODO_RATELIMIT=off
+func LoadRateLimitConfig() *RateLimitConfig {
+	cfg := &RateLimitConfig{
+		Enabled:  os.Getenv("TODO_RATELIMIT") != "off",
+		Read:     1200,
+		Write:    600,
+		NextDate: 1200,
+		Window:   time.Minute,
+	}
+	if limit, err := strconv.Atoi(os.Getenv("TODO_RATELIMIT_READ")); err == nil && limit > 0 {
+		cfg.Read = limit
+	}
+	if limit, err := strconv.Atoi(os.Getenv("TODO_RATELIMIT_WRITE")); err == nil && limit > 0 {
+		cfg.Write = limit
+	}
+	if limit, err := strconv.Atoi(os.Getenv("TODO_RATELIMIT_NEXTDATE")); err == nil && limit > 0 {
+		cfg.NextDate = limit
+	}
+	if window, err := time.ParseDuration(os.Getenv("TODO_RATELIMIT_WINDOW")); err == nil && window > 0 {
+		cfg.Window = window
+	}
+	return cfg
+}
+
+type OIDCConfig struct {
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+	// LoginClaim — утверждение ID-токена, из которого берётся имя новой учётной записи
+	LoginClaim string `json:"login_claim"`
+	// AutoCreate создаёт учётную запись при первом входе через провайдера
+	AutoCreate bool `json:"auto_create"`
+}
+
+// Enabled сообщает, настроен ли вход через провайдера OpenID Connect
+func (c *OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != "" && c.RedirectURL != ""
+}
+
+// LoadOIDCConfig читает настройки входа через провайдера OpenID Connect из JSON-файла
+// TODO_OIDC_CONFIG, а затем из переменных TODO_OIDC_*, которые заменяют значения из файла
+func LoadOIDCConfig() *OIDCConfig {
+	cfg := &OIDCConfig{
+		Scopes:     []string{"openid", "profile", "email"},
+		LoginClaim: "preferred_username",
+		AutoCreate: true,
+	}
+	if path := os.Getenv("TODO_OIDC_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, cfg)
+		}
+		if err != nil {
+			log.Printf("Failed to read OIDC config %s: %v", path, err)
+		}
+	}
+
+	for env, value := range map[string]*string{
+		"TODO_OIDC_ISSUER":        &cfg.Issuer,
+		"TODO_OIDC_CLIENT_ID":     &cfg.ClientID,
+		"TODO_OIDC_CLIENT_SECRET": &cfg.ClientSecret,
+		"TODO_OIDC_REDIRECT_URL":  &cfg.RedirectURL,
+		"TODO_OIDC_LOGIN_CLAIM":   &cfg.LoginClaim,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*value = v
+		}
+	}
+	if scopes := os.Getenv("TODO_OIDC_SCOPES"); scopes != "" {
+		cfg.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+	switch os.Getenv("TODO_OIDC_AUTO_CREATE") {
+	case "on":
+		cfg.AutoCreate = true
+	case "off":
+		cfg.AutoCreate = false
+	}
+	return cfg
 }
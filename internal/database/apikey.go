@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// ErrAPIKeyNotFound возвращается, если ключа API с указанным хэшем или идентификатором нет
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// apiKeyColumns — столбцы ключа API в порядке, ожидаемом scanAPIKey
+const apiKeyColumns = "id, owner, name, prefix, scopes, created_at, last_used_at"
+
+// AddAPIKey сохраняет ключ API. Ключ хранится только в виде хэша
+func AddAPIKey(key models.APIKey, keyHash string) (int, error) {
+	res, err := db.Exec("INSERT INTO api_keys (owner, name, prefix, key_hash, scopes, created_at) VALUES (:owner, :name, :prefix, :key_hash, :scopes, :created_at)",
+		sql.Named("owner", key.Owner),
+		sql.Named("name", key.Name),
+		sql.Named("prefix", key.Prefix),
+		sql.Named("key_hash", keyHash),
+		sql.Named("scopes", strings.Join(key.Scopes, ",")),
+		sql.Named("created_at", key.CreatedAt))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetAPIKeys возвращает ключи API пользователя без самих ключей
+func GetAPIKeys(owner int64) ([]models.APIKey, error) {
+	rows, err := db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE owner = :owner ORDER BY id",
+		sql.Named("owner", owner))
+	if err != nil {
+		return []models.APIKey{}, errors.New("error getting api key list")
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return []models.APIKey{}, errors.New("data reading error")
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.APIKey{}, errors.New("data reading error")
+	}
+
+	return keys, nil
+}
+
+// GetAPIKeyByHash находит ключ API по хэшу предъявленного ключа
+func GetAPIKeyByHash(keyHash string) (models.APIKey, error) {
+	key, err := scanAPIKey(db.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = :key_hash",
+		sql.Named("key_hash", keyHash)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// TouchAPIKey отмечает время последнего использования ключа API
+func TouchAPIKey(id string, now time.Time) error {
+	_, err := db.Exec("UPDATE api_keys SET last_used_at = :now WHERE id = :id",
+		sql.Named("now", now.UTC().Format(time.RFC3339)),
+		sql.Named("id", id))
+	return err
+}
+
+// DeleteAPIKey отзывает ключ API пользователя
+func DeleteAPIKey(owner int64, id string) error {
+	res, err := db.Exec("DELETE FROM api_keys WHERE id = :id AND owner = :owner",
+		sql.Named("id", id),
+		sql.Named("owner", owner))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// scanAPIKey читает ключ API из строки результата
+func scanAPIKey(s scanner) (models.APIKey, error) {
+	var key models.APIKey
+	var id int64
+	var scopes string
+	if err := s.Scan(&id, &key.Owner, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt); err != nil {
+		return models.APIKey{}, err
+	}
+	key.ID = strconv.FormatInt(id, 10)
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	return key, nil
+}
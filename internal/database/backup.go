@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// backupPrefix — префикс имён файлов резервных копий, создаваемых по расписанию
+const backupPrefix = "scheduler-"
+
+// Backup создаёт согласованную копию базы данных в файле path, не останавливая запись в неё.
+// Файл path не должен существовать
+func Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// VerifyBackup проверяет, что файл является целой базой данных планировщика
+func VerifyBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var result string
+	if err := src.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("not a database file: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	var count int
+	if err := src.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'scheduler'").Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("scheduler table is missing")
+	}
+
+	return nil
+}
+
+// Restore заменяет содержимое базы данных копией из файла path.
+// Копия предварительно проверяется, а затем переносится через backup API SQLite,
+// поэтому сервер может продолжать работу
+func Restore(path string) error {
+	if err := VerifyBackup(path); err != nil {
+		return err
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	err = dstConn.Raw(func(dstDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			dst, ok := dstDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("unexpected database driver")
+			}
+			src, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("unexpected database driver")
+			}
+
+			backup, err := dst.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Копия могла быть сделана предыдущей версией приложения
+	return migrate()
+}
+
+// RotateBackup создаёт резервную копию в каталоге dir и удаляет самые старые копии сверх keep
+func RotateBackup(dir string, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, backupPrefix+time.Now().Format("20060102-150405")+".db")
+	if err := Backup(path); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return path, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, ".db") {
+			backups = append(backups, name)
+		}
+	}
+
+	// Имена содержат время создания, поэтому сортировка по имени совпадает с хронологической
+	sort.Strings(backups)
+	for len(backups) > keep && keep > 0 {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return path, err
+		}
+		backups = backups[1:]
+	}
+
+	return path, nil
+}
+
+// ScheduleBackups создаёт резервные копии в каталоге dir с периодом interval, пока не отменён ctx
+func ScheduleBackups(ctx context.Context, dir string, interval time.Duration, keep int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := RotateBackup(dir, keep)
+			if err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+				continue
+			}
+			log.Printf("Backup created: %s", path)
+		}
+	}
+}
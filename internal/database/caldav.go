@@ -0,0 +1,86 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"todo-rest/internal/models"
+)
+
+// ErrObjectNotFound возвращается, если ресурса CalDAV с указанным именем нет
+var ErrObjectNotFound = errors.New("caldav object not found")
+
+// SaveCalDAVObject сохраняет имя ресурса CalDAV и UID компонента для задачи
+func SaveCalDAVObject(obj models.CalDAVObject) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO caldav_objects (name, task_id, uid) VALUES (:name, :task_id, :uid)",
+		sql.Named("name", obj.Name),
+		sql.Named("task_id", obj.TaskID),
+		sql.Named("uid", obj.UID))
+	return err
+}
+
+// GetCalDAVObject находит ресурс CalDAV по имени
+func GetCalDAVObject(name string) (models.CalDAVObject, error) {
+	var obj models.CalDAVObject
+
+	row := db.QueryRow("SELECT name, task_id, uid FROM caldav_objects WHERE name = :name", sql.Named("name", name))
+	if err := row.Scan(&obj.Name, &obj.TaskID, &obj.UID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.CalDAVObject{}, ErrObjectNotFound
+		}
+		return models.CalDAVObject{}, err
+	}
+
+	return obj, nil
+}
+
+// GetCalDAVObjects возвращает все ресурсы CalDAV по идентификаторам задач
+func GetCalDAVObjects() (map[string]models.CalDAVObject, error) {
+	rows, err := db.Query("SELECT name, task_id, uid FROM caldav_objects")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := make(map[string]models.CalDAVObject)
+	for rows.Next() {
+		var obj models.CalDAVObject
+		if err := rows.Scan(&obj.Name, &obj.TaskID, &obj.UID); err != nil {
+			return nil, err
+		}
+		objects[obj.TaskID] = obj
+	}
+
+	return objects, rows.Err()
+}
+
+// SyncToken возвращает номер последней записи истории личных задач пользователя, по которому
+// клиенты синхронизации определяют изменения. Изменения других пользователей токен не меняют
+func SyncToken(owner int64) (int64, error) {
+	var token int64
+	err := db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM task_history WHERE owner = :owner AND list_id = 0",
+		sql.Named("owner", owner)).Scan(&token)
+	return token, err
+}
+
+// ChangedTaskIDs возвращает идентификаторы личных задач пользователя, изменённых после записи истории since
+func ChangedTaskIDs(owner int64, since int64) ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT task_id FROM task_history WHERE id > :since AND owner = :owner AND list_id = 0 ORDER BY task_id",
+		sql.Named("since", since),
+		sql.Named("owner", owner))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -3,9 +3,11 @@ package database
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"todo-rest/internal/config"
 	"todo-rest/internal/models"
@@ -15,6 +17,12 @@ import (
 
 var db *sql.DB
 
+// ErrTaskNotFound возвращается, если задачи с указанным идентификатором нет
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrVersionConflict возвращается, если задача была изменена после её чтения
+var ErrVersionConflict = errors.New("task version conflict")
+
 // InitDb инициализирует базу данных и создаёт необходимые таблицы и индексы, если они не существуют
 func InitDb() *sql.DB {
 	// Получаем путь к базе данных из переменной окружения
@@ -39,37 +47,321 @@ func InitDb() *sql.DB {
 		file.Close()
 	}
 
-	// Открываем или создаем базу данных
-	db, err = sql.Open("sqlite3", dbFile)
+	// Открываем или создаем базу данных.
+	// Транзакции сразу захватывают блокировку на запись, конкурирующие ждут её освобождения
+	db, err = sql.Open("sqlite3", dbFile+"?_txlock=immediate&_busy_timeout=5000")
 	if err != nil {
 		log.Fatalf("Error opening database: %v", err)
 	}
 
-	// Создаем таблицу и индекс
-	createTable := `
+	// Создаем таблицы и индексы
+	if err := migrate(); err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+// schema описывает таблицы и индексы базы данных
+const schema = `
     CREATE TABLE IF NOT EXISTS scheduler (
         id INTEGER PRIMARY KEY AUTOINCREMENT,
         date VARCHAR(10) NOT NULL,
         title VARCHAR(128) NOT NULL,
         comment TEXT,
-        repeat VARCHAR(128)
+        repeat VARCHAR(128),
+        version INTEGER NOT NULL DEFAULT 1,
+        anchor VARCHAR(10) NOT NULL DEFAULT '',
+        owner INTEGER NOT NULL DEFAULT 1,
+        list_id INTEGER NOT NULL DEFAULT 0
     );
     CREATE INDEX IF NOT EXISTS idx_date ON scheduler (date);
-    `
-	if _, err := db.Exec(createTable); err != nil {
-		log.Fatal(err)
+
+    CREATE TABLE IF NOT EXISTS users (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        login VARCHAR(64) NOT NULL UNIQUE,
+        password_hash VARCHAR(255) NOT NULL DEFAULT '',
+        role VARCHAR(16) NOT NULL,
+        created_at VARCHAR(32) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS task_history (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        action VARCHAR(16) NOT NULL,
+        actor VARCHAR(128) NOT NULL DEFAULT '',
+        before TEXT,
+        after TEXT,
+        created_at VARCHAR(32) NOT NULL,
+        owner INTEGER NOT NULL DEFAULT 1,
+        list_id INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS idx_history_task ON task_history (task_id);
+
+    CREATE TABLE IF NOT EXISTS lists (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name VARCHAR(128) NOT NULL,
+        created_at VARCHAR(32) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS list_members (
+        list_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        role VARCHAR(16) NOT NULL,
+        PRIMARY KEY (list_id, user_id)
+    );
+    CREATE INDEX IF NOT EXISTS idx_members_user ON list_members (user_id);
+
+    CREATE TABLE IF NOT EXISTS sessions (
+        id VARCHAR(64) PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        subject VARCHAR(64) NOT NULL DEFAULT '',
+        expires_at VARCHAR(32) NOT NULL DEFAULT ''
+    );
+    CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id);
+
+    CREATE TABLE IF NOT EXISTS api_keys (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        owner INTEGER NOT NULL,
+        name VARCHAR(128) NOT NULL,
+        prefix VARCHAR(16) NOT NULL,
+        key_hash VARCHAR(64) NOT NULL UNIQUE,
+        scopes VARCHAR(64) NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        last_used_at VARCHAR(32) NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE IF NOT EXISTS totp (
+        user_id INTEGER PRIMARY KEY,
+        secret VARCHAR(64) NOT NULL,
+        enabled INTEGER NOT NULL DEFAULT 0,
+        last_step INTEGER NOT NULL DEFAULT 0,
+        created_at VARCHAR(32) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+        hash VARCHAR(64) PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        used_at VARCHAR(32) NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE IF NOT EXISTS signin_challenges (
+        hash VARCHAR(64) PRIMARY KEY,
+        user_id INTEGER NOT NULL,
+        subject VARCHAR(64) NOT NULL,
+        attempt_id INTEGER NOT NULL DEFAULT 0,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at VARCHAR(32) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS oidc_states (
+        hash VARCHAR(64) PRIMARY KEY,
+        verifier VARCHAR(128) NOT NULL,
+        nonce VARCHAR(128) NOT NULL,
+        expires_at VARCHAR(32) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS user_identities (
+        issuer VARCHAR(255) NOT NULL,
+        subject VARCHAR(255) NOT NULL,
+        user_id INTEGER NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        PRIMARY KEY (issuer, subject)
+    );
+
+    CREATE TABLE IF NOT EXISTS signin_failures (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        login VARCHAR(64) NOT NULL,
+        ip VARCHAR(64) NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        login_cleared INTEGER NOT NULL DEFAULT 0,
+        ip_cleared INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE INDEX IF NOT EXISTS idx_signin_login ON signin_failures (login, created_at);
+    CREATE INDEX IF NOT EXISTS idx_signin_ip ON signin_failures (ip, created_at);
+
+    CREATE TABLE IF NOT EXISTS refresh_tokens (
+        hash VARCHAR(64) PRIMARY KEY,
+        session_id VARCHAR(64) NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        used_at VARCHAR(32) NOT NULL DEFAULT ''
+    );
+    CREATE INDEX IF NOT EXISTS idx_refresh_session ON refresh_tokens (session_id);
+
+    CREATE TABLE IF NOT EXISTS list_invitations (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        list_id INTEGER NOT NULL,
+        user_id INTEGER NOT NULL,
+        role VARCHAR(16) NOT NULL,
+        invited_by INTEGER NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        UNIQUE (list_id, user_id)
+    );
+
+    CREATE TABLE IF NOT EXISTS feeds (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name VARCHAR(128) NOT NULL DEFAULT '',
+        component VARCHAR(16) NOT NULL,
+        token_hash VARCHAR(64) NOT NULL UNIQUE,
+        created_at VARCHAR(32) NOT NULL,
+        owner INTEGER NOT NULL DEFAULT 1
+    );
+
+    CREATE TABLE IF NOT EXISTS caldav_objects (
+        name VARCHAR(255) PRIMARY KEY,
+        task_id INTEGER NOT NULL UNIQUE,
+        uid VARCHAR(255) NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS webhooks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        url TEXT NOT NULL,
+        secret VARCHAR(64) NOT NULL,
+        events VARCHAR(128) NOT NULL DEFAULT '',
+        created_at VARCHAR(32) NOT NULL,
+        owner INTEGER NOT NULL DEFAULT 1
+    );
+
+    CREATE TABLE IF NOT EXISTS webhook_deliveries (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        webhook_id INTEGER NOT NULL,
+        event VARCHAR(16) NOT NULL,
+        payload TEXT NOT NULL,
+        status VARCHAR(16) NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        last_status INTEGER NOT NULL DEFAULT 0,
+        last_error TEXT NOT NULL DEFAULT '',
+        next_attempt_at VARCHAR(32) NOT NULL DEFAULT '',
+        created_at VARCHAR(32) NOT NULL,
+        delivered_at VARCHAR(32) NOT NULL DEFAULT ''
+    );
+    CREATE INDEX IF NOT EXISTS idx_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+    CREATE INDEX IF NOT EXISTS idx_deliveries_webhook ON webhook_deliveries (webhook_id);
+
+    CREATE TABLE IF NOT EXISTS reminders (
+        task_id INTEGER NOT NULL,
+        task_date VARCHAR(10) NOT NULL,
+        lead VARCHAR(32) NOT NULL,
+        channel VARCHAR(16) NOT NULL,
+        sent_at VARCHAR(32) NOT NULL,
+        UNIQUE (task_id, task_date, lead, channel)
+    );
+
+    CREATE TABLE IF NOT EXISTS notifications (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        title VARCHAR(128) NOT NULL,
+        date VARCHAR(10) NOT NULL,
+        due_at VARCHAR(32) NOT NULL,
+        created_at VARCHAR(32) NOT NULL,
+        owner INTEGER NOT NULL DEFAULT 1
+    );
+`
+
+// columns описывает столбцы, которых нет в базах данных, созданных предыдущими версиями.
+// Задачи и записи, созданные до появления пользователей, принадлежат администратору
+var columns = []struct {
+	table, column, definition string
+}{
+	{"scheduler", "version", "INTEGER NOT NULL DEFAULT 1"},
+	{"scheduler", "anchor", "VARCHAR(10) NOT NULL DEFAULT ''"},
+	{"scheduler", "owner", "INTEGER NOT NULL DEFAULT 1"},
+	{"task_history", "owner", "INTEGER NOT NULL DEFAULT 1"},
+	{"feeds", "owner", "INTEGER NOT NULL DEFAULT 1"},
+	{"webhooks", "owner", "INTEGER NOT NULL DEFAULT 1"},
+	{"notifications", "owner", "INTEGER NOT NULL DEFAULT 1"},
+	{"scheduler", "list_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"task_history", "list_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"sessions", "subject", "VARCHAR(64) NOT NULL DEFAULT ''"},
+	{"sessions", "expires_at", "VARCHAR(32) NOT NULL DEFAULT ''"},
+}
+
+// migrate создаёт недостающие таблицы и индексы, добавляет столбцы,
+// которых нет в базах данных, созданных предыдущими версиями, и учётную запись администратора
+func migrate() error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
 	}
 
-	return db
+	for _, c := range columns {
+		if err := addColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Индексы по владельцу и списку создаются после добавления столбцов
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_owner_date ON scheduler (owner, date);
+        CREATE INDEX IF NOT EXISTS idx_list_date ON scheduler (list_id, date);
+        CREATE INDEX IF NOT EXISTS idx_history_owner ON task_history (owner, id)`); err != nil {
+		return err
+	}
+
+	// Администратор входит по общему паролю TODO_PASSWORD, пока ему не задан пароль командой passwd
+	_, err := db.Exec("INSERT OR IGNORE INTO users (id, login, password_hash, role, created_at) VALUES (:id, :login, '', :role, :created_at)",
+		sql.Named("id", models.AdminID),
+		sql.Named("login", models.AdminLogin),
+		sql.Named("role", models.RoleAdmin),
+		sql.Named("created_at", time.Now().UTC().Format(time.RFC3339)))
+	return err
+}
+
+// addColumn добавляет столбец в таблицу, если его ещё нет
+func addColumn(table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// taskColumns — столбцы задачи в порядке, ожидаемом scanTask
+const taskColumns = "id, date, title, comment, repeat, version, anchor, owner, list_id"
+
+// scanTask читает задачу из строки результата
+func scanTask(s scanner, task *models.Task) error {
+	return s.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Version, &task.Anchor, &task.Owner, &task.List)
+}
+
+// inScope — условие отбора задач и записей истории области видимости, заданной
+// параметрами :owner и :list: личных задач пользователя или задач общего списка
+const inScope = "list_id = :list AND (:list <> 0 OR owner = :owner)"
+
+// scopeArgs возвращает параметры условия inScope
+func scopeArgs(scope models.Scope) []any {
+	return []any{sql.Named("owner", scope.Owner), sql.Named("list", scope.List)}
 }
 
-// AddTask добавляет задачу в базу данных
+// AddTask добавляет задачу пользователя task.Owner в список task.List или в его личные задачи
 func AddTask(task models.Task) (int, error) {
-	res, err := db.Exec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (:date, :title, :comment, :repeat)",
+	return addTask(db, task)
+}
+
+func addTask(q querier, task models.Task) (int, error) {
+	res, err := q.Exec("INSERT INTO scheduler (date, title, comment, repeat, anchor, owner, list_id) VALUES (:date, :title, :comment, :repeat, :anchor, :owner, :list)",
 		sql.Named("date", task.Date),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat))
+		sql.Named("repeat", task.Repeat),
+		sql.Named("anchor", task.Anchor),
+		sql.Named("owner", task.Owner),
+		sql.Named("list", task.List))
 	if err != nil {
 		return 0, err
 	}
@@ -82,15 +374,49 @@ func AddTask(task models.Task) (int, error) {
 	return int(id), nil
 }
 
-// GetTasks выводит список всех задач или по фильтру
-func GetTasks(filter models.TaskFilter) (tasks []models.Task, err error) {
-	query := "SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date LIMIT :limit"
+// RestoreTask возвращает удалённую задачу в базу данных с прежним идентификатором
+func RestoreTask(task models.Task) error {
+	return restoreTask(db, task)
+}
+
+func restoreTask(q querier, task models.Task) error {
+	if task.Version < 1 {
+		task.Version = 1
+	}
+	_, err := q.Exec("INSERT INTO scheduler (id, date, title, comment, repeat, version, anchor, owner, list_id) VALUES (:id, :date, :title, :comment, :repeat, :version, :anchor, :owner, :list)",
+		sql.Named("id", task.ID),
+		sql.Named("date", task.Date),
+		sql.Named("title", task.Title),
+		sql.Named("comment", task.Comment),
+		sql.Named("repeat", task.Repeat),
+		sql.Named("version", task.Version),
+		sql.Named("anchor", task.Anchor),
+		sql.Named("owner", task.Owner),
+		sql.Named("list", task.List))
+	return err
+}
+
+// TaskIDUsed проверяет, занят ли идентификатор задачей любого пользователя
+func TaskIDUsed(id string) (bool, error) {
+	return taskIDUsed(db, id)
+}
+
+func taskIDUsed(q querier, id string) (bool, error) {
+	var count int
+	err := q.QueryRow("SELECT count(*) FROM scheduler WHERE id = :id", sql.Named("id", id)).Scan(&count)
+	return count > 0, err
+}
+
+// GetTasks выводит список всех задач области видимости или по фильтру
+func GetTasks(scope models.Scope, filter models.TaskFilter) (tasks []models.Task, err error) {
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE " + inScope + " ORDER BY date LIMIT :limit"
 	if filter.Search != "" && !filter.SearchData {
-		query = "SELECT id, date, title, comment, repeat FROM scheduler WHERE title LIKE :search OR comment LIKE :search ORDER BY date LIMIT :limit"
+		query = "SELECT " + taskColumns + " FROM scheduler WHERE " + inScope + " AND (title LIKE :search OR comment LIKE :search) ORDER BY date LIMIT :limit"
 	} else if filter.Search != "" && filter.SearchData {
-		query = "SELECT id, date, title, comment, repeat FROM scheduler WHERE date = :search LIMIT :limit"
+		query = "SELECT " + taskColumns + " FROM scheduler WHERE " + inScope + " AND date = :search LIMIT :limit"
 	}
-	rows, err := db.Query(query, sql.Named("search", filter.Search), sql.Named("limit", config.LimitSearch))
+	args := append(scopeArgs(scope), sql.Named("search", filter.Search), sql.Named("limit", config.LimitSearch))
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return []models.Task{}, errors.New("error getting task list")
 	}
@@ -98,7 +424,7 @@ func GetTasks(filter models.TaskFilter) (tasks []models.Task, err error) {
 
 	for rows.Next() {
 		var task models.Task
-		if err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+		if err := scanTask(rows, &task); err != nil {
 			return []models.Task{}, errors.New("data reading error")
 		}
 		tasks = append(tasks, task)
@@ -114,25 +440,98 @@ func GetTasks(filter models.TaskFilter) (tasks []models.Task, err error) {
 	return tasks, nil
 }
 
-func GetTask(id string) (models.Task, error) {
+// GetOverdueTasks возвращает задачи области видимости, дата которых раньше today
+func GetOverdueTasks(scope models.Scope, today string) ([]models.Task, error) {
+	args := append(scopeArgs(scope), sql.Named("today", today), sql.Named("limit", config.LimitSearch))
+	rows, err := db.Query("SELECT "+taskColumns+" FROM scheduler WHERE "+inScope+" AND date < :today ORDER BY date LIMIT :limit", args...)
+	if err != nil {
+		return []models.Task{}, errors.New("error getting task list")
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			return []models.Task{}, errors.New("data reading error")
+		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Task{}, errors.New("data reading error")
+	}
+
+	return tasks, nil
+}
+
+// EachTask последовательно передаёт в fn все задачи области видимости без ограничения количества
+func EachTask(scope models.Scope, fn func(task models.Task) error) error {
+	return eachTask(db, "WHERE "+inScope, scopeArgs(scope), fn)
+}
+
+// EachTaskOfAllUsers последовательно передаёт в fn задачи всех пользователей и списков.
+// Используется фоновыми заданиями, которые обслуживают всех пользователей
+func EachTaskOfAllUsers(fn func(task models.Task) error) error {
+	return eachTask(db, "", nil, fn)
+}
+
+func eachTask(q querier, where string, args []any, fn func(task models.Task) error) error {
+	rows, err := q.Query("SELECT "+taskColumns+" FROM scheduler "+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetTask возвращает задачу области видимости по идентификатору.
+// Задача другого пользователя или списка не находится
+func GetTask(scope models.Scope, id string) (models.Task, error) {
+	return getTask(db, scope, id)
+}
+
+func getTask(q querier, scope models.Scope, id string) (models.Task, error) {
 	var task models.Task
 
-	row := db.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id = :id", sql.Named("id", id))
-	if err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat); err != nil {
+	row := q.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id AND "+inScope,
+		append(scopeArgs(scope), sql.Named("id", id))...)
+	if err := scanTask(row, &task); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Task{}, ErrTaskNotFound
+		}
 		return models.Task{}, err
 	}
 
 	return task, nil
 }
 
-// UpdateTask изменяет параметры задачи
+// UpdateTask изменяет параметры задачи пользователя task.Owner или списка task.List,
+// если её версия не изменилась с момента чтения
 func UpdateTask(task models.Task) (models.Task, error) {
-	res, err := db.Exec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id",
-		sql.Named("date", task.Date),
-		sql.Named("title", task.Title),
-		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat),
-		sql.Named("id", task.ID))
+	return updateTask(db, models.Scope{Owner: task.Owner, List: task.List}, task)
+}
+
+func updateTask(q querier, scope models.Scope, task models.Task) (models.Task, error) {
+	res, err := q.Exec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat, anchor = :anchor, version = version + 1 WHERE id = :id AND version = :version AND "+inScope,
+		append(scopeArgs(scope),
+			sql.Named("date", task.Date),
+			sql.Named("title", task.Title),
+			sql.Named("comment", task.Comment),
+			sql.Named("repeat", task.Repeat),
+			sql.Named("anchor", task.Anchor),
+			sql.Named("id", task.ID),
+			sql.Named("version", task.Version))...)
 	if err != nil {
 		return models.Task{}, err
 	}
@@ -143,17 +542,24 @@ func UpdateTask(task models.Task) (models.Task, error) {
 	}
 
 	if rowsAffected == 0 {
-		return models.Task{}, errors.New("failed to delete")
+		return models.Task{}, ErrVersionConflict
 	}
 
+	task.Version++
 	return task, nil
 
 }
 
-// DeleteTask удаляет задачу
-func DeleteTask(id string) error {
-	res, err := db.Exec("DELETE FROM scheduler WHERE id = :id",
-		sql.Named("id", id))
+// DeleteTask удаляет задачу области видимости, если её версия не изменилась с момента чтения
+func DeleteTask(scope models.Scope, id string, version int64) error {
+	return deleteTask(db, scope, id, version)
+}
+
+func deleteTask(q querier, scope models.Scope, id string, version int64) error {
+	res, err := q.Exec("DELETE FROM scheduler WHERE id = :id AND version = :version AND "+inScope,
+		append(scopeArgs(scope),
+			sql.Named("id", id),
+			sql.Named("version", version))...)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -165,7 +571,7 @@ func DeleteTask(id string) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("failed to delete")
+		return ErrVersionConflict
 	}
 
 	return nil
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// ErrFeedNotFound возвращается, если подписки с указанным токеном или идентификатором нет
+var ErrFeedNotFound = errors.New("feed not found")
+
+// AddFeed сохраняет подписку календаря. Токен хранится только в виде хэша
+func AddFeed(feed models.Feed, tokenHash string) (int, error) {
+	res, err := db.Exec("INSERT INTO feeds (name, component, token_hash, created_at, owner) VALUES (:name, :component, :token_hash, :created_at, :owner)",
+		sql.Named("name", feed.Name),
+		sql.Named("component", feed.Component),
+		sql.Named("token_hash", tokenHash),
+		sql.Named("created_at", time.Now().UTC().Format(time.RFC3339)),
+		sql.Named("owner", feed.Owner))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetFeeds возвращает список подписок календаря пользователя
+func GetFeeds(owner int64) ([]models.Feed, error) {
+	rows, err := db.Query("SELECT id, name, component, created_at, owner FROM feeds WHERE owner = :owner ORDER BY id",
+		sql.Named("owner", owner))
+	if err != nil {
+		return []models.Feed{}, errors.New("error getting feed list")
+	}
+	defer rows.Close()
+
+	feeds := []models.Feed{}
+	for rows.Next() {
+		var feed models.Feed
+		if err := rows.Scan(&feed.ID, &feed.Name, &feed.Component, &feed.CreatedAt, &feed.Owner); err != nil {
+			return []models.Feed{}, errors.New("data reading error")
+		}
+		feeds = append(feeds, feed)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Feed{}, errors.New("data reading error")
+	}
+
+	return feeds, nil
+}
+
+// GetFeedByToken находит подписку по хэшу её токена
+func GetFeedByToken(tokenHash string) (models.Feed, error) {
+	var feed models.Feed
+
+	row := db.QueryRow("SELECT id, name, component, created_at, owner FROM feeds WHERE token_hash = :token_hash",
+		sql.Named("token_hash", tokenHash))
+	if err := row.Scan(&feed.ID, &feed.Name, &feed.Component, &feed.CreatedAt, &feed.Owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Feed{}, ErrFeedNotFound
+		}
+		return models.Feed{}, err
+	}
+
+	return feed, nil
+}
+
+// DeleteFeed удаляет подписку пользователя, после чего её ссылка перестаёт работать
+func DeleteFeed(owner int64, id string) error {
+	res, err := db.Exec("DELETE FROM feeds WHERE id = :id AND owner = :owner", sql.Named("id", id), sql.Named("owner", owner))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrFeedNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// AddHistory сохраняет запись об изменении задачи
+func AddHistory(entry models.TaskHistory) error {
+	return addHistory(db, entry)
+}
+
+func addHistory(q querier, entry models.TaskHistory) error {
+	before, err := marshalSnapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalSnapshot(entry.After)
+	if err != nil {
+		return err
+	}
+	if entry.CreatedAt == "" {
+		entry.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	_, err = q.Exec("INSERT INTO task_history (task_id, action, actor, before, after, created_at, owner, list_id) VALUES (:task_id, :action, :actor, :before, :after, :created_at, :owner, :list)",
+		sql.Named("task_id", entry.TaskID),
+		sql.Named("action", entry.Action),
+		sql.Named("actor", entry.Actor),
+		sql.Named("before", before),
+		sql.Named("after", after),
+		sql.Named("created_at", entry.CreatedAt),
+		sql.Named("owner", entry.Owner),
+		sql.Named("list", entry.List))
+	return err
+}
+
+// GetHistory возвращает историю изменений задачи области видимости в хронологическом порядке
+func GetHistory(scope models.Scope, taskID string) ([]models.TaskHistory, error) {
+	rows, err := db.Query("SELECT id, task_id, action, actor, before, after, created_at FROM task_history WHERE task_id = :task_id AND "+inScope+" ORDER BY id",
+		append(scopeArgs(scope), sql.Named("task_id", taskID))...)
+	if err != nil {
+		return []models.TaskHistory{}, errors.New("error getting task history")
+	}
+	defer rows.Close()
+
+	history := []models.TaskHistory{}
+	for rows.Next() {
+		entry, err := scanHistory(rows)
+		if err != nil {
+			return []models.TaskHistory{}, errors.New("data reading error")
+		}
+		history = append(history, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.TaskHistory{}, errors.New("data reading error")
+	}
+
+	return history, nil
+}
+
+// GetHistoryEntry возвращает одну запись истории задачи области видимости
+func GetHistoryEntry(scope models.Scope, taskID, entryID string) (models.TaskHistory, error) {
+	row := db.QueryRow("SELECT id, task_id, action, actor, before, after, created_at FROM task_history WHERE id = :id AND task_id = :task_id AND "+inScope,
+		append(scopeArgs(scope),
+			sql.Named("id", entryID),
+			sql.Named("task_id", taskID))...)
+	return scanHistory(row)
+}
+
+// scanHistory читает запись истории и восстанавливает снимки задачи
+func scanHistory(s scanner) (models.TaskHistory, error) {
+	var entry models.TaskHistory
+	var before, after sql.NullString
+	if err := s.Scan(&entry.ID, &entry.TaskID, &entry.Action, &entry.Actor, &before, &after, &entry.CreatedAt); err != nil {
+		return models.TaskHistory{}, err
+	}
+
+	var err error
+	if entry.Before, err = unmarshalSnapshot(before); err != nil {
+		return models.TaskHistory{}, err
+	}
+	if entry.After, err = unmarshalSnapshot(after); err != nil {
+		return models.TaskHistory{}, err
+	}
+
+	return entry, nil
+}
+
+// marshalSnapshot сериализует снимок задачи, nil сохраняется как NULL
+func marshalSnapshot(task *models.Task) (sql.NullString, error) {
+	if task == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalSnapshot восстанавливает снимок задачи из JSON
+func unmarshalSnapshot(data sql.NullString) (*models.Task, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	var task models.Task
+	if err := json.Unmarshal([]byte(data.String), &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
@@ -0,0 +1,326 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// Ошибки работы с общими списками
+var (
+	ErrListNotFound       = errors.New("list not found")
+	ErrMemberNotFound     = errors.New("member not found")
+	ErrAlreadyMember      = errors.New("user is already a member")
+	ErrLastOwner          = errors.New("list must keep at least one owner")
+	ErrInvitationNotFound = errors.New("invitation not found")
+)
+
+// AddList создаёт общий список, владельцем которого становится пользователь owner
+func AddList(name string, owner int64) (models.List, error) {
+	list := models.List{Name: name, Role: models.ListOwner, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	err := RunInTx(models.Scope{Owner: owner}, func(tx *Tx) error {
+		res, err := tx.tx.Exec("INSERT INTO lists (name, created_at) VALUES (:name, :created_at)",
+			sql.Named("name", list.Name),
+			sql.Named("created_at", list.CreatedAt))
+		if err != nil {
+			return err
+		}
+		if list.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("INSERT INTO list_members (list_id, user_id, role) VALUES (:list_id, :user_id, :role)",
+			sql.Named("list_id", list.ID),
+			sql.Named("user_id", owner),
+			sql.Named("role", models.ListOwner))
+		return err
+	})
+	if err != nil {
+		return models.List{}, err
+	}
+
+	return list, nil
+}
+
+// GetLists возвращает общие списки, участником которых является пользователь, с его ролью в них
+func GetLists(user int64) ([]models.List, error) {
+	rows, err := db.Query("SELECT l.id, l.name, m.role, l.created_at FROM lists l JOIN list_members m ON m.list_id = l.id"+
+		" WHERE m.user_id = :user ORDER BY l.id",
+		sql.Named("user", user))
+	if err != nil {
+		return []models.List{}, errors.New("error getting list of lists")
+	}
+	defer rows.Close()
+
+	lists := []models.List{}
+	for rows.Next() {
+		var list models.List
+		if err := rows.Scan(&list.ID, &list.Name, &list.Role, &list.CreatedAt); err != nil {
+			return []models.List{}, errors.New("data reading error")
+		}
+		lists = append(lists, list)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.List{}, errors.New("data reading error")
+	}
+
+	return lists, nil
+}
+
+// GetListRole возвращает роль пользователя в общем списке. Для списка, участником
+// которого пользователь не является, возвращается ErrListNotFound
+func GetListRole(list, user int64) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM list_members WHERE list_id = :list AND user_id = :user",
+		sql.Named("list", list),
+		sql.Named("user", user)).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrListNotFound
+	}
+	return role, err
+}
+
+// GetListMemberIDs возвращает идентификаторы участников общего списка
+func GetListMemberIDs(list int64) ([]int64, error) {
+	rows, err := db.Query("SELECT user_id FROM list_members WHERE list_id = :list ORDER BY user_id", sql.Named("list", list))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetListMembers возвращает участников общего списка
+func GetListMembers(list int64) ([]models.ListMember, error) {
+	rows, err := db.Query("SELECT m.user_id, u.login, m.role FROM list_members m JOIN users u ON u.id = m.user_id"+
+		" WHERE m.list_id = :list ORDER BY m.user_id",
+		sql.Named("list", list))
+	if err != nil {
+		return []models.ListMember{}, errors.New("error getting member list")
+	}
+	defer rows.Close()
+
+	members := []models.ListMember{}
+	for rows.Next() {
+		var member models.ListMember
+		if err := rows.Scan(&member.UserID, &member.Login, &member.Role); err != nil {
+			return []models.ListMember{}, errors.New("data reading error")
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.ListMember{}, errors.New("data reading error")
+	}
+
+	return members, nil
+}
+
+// RemoveListMember лишает пользователя доступа к общему списку. Последнего
+// владельца удалить нельзя: список без владельца некому было бы администрировать
+func RemoveListMember(list, user int64) error {
+	return RunInTx(models.Scope{Owner: user, List: list}, func(tx *Tx) error {
+		var role string
+		err := tx.tx.QueryRow("SELECT role FROM list_members WHERE list_id = :list AND user_id = :user",
+			sql.Named("list", list),
+			sql.Named("user", user)).Scan(&role)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMemberNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if role == models.ListOwner {
+			var owners int
+			err := tx.tx.QueryRow("SELECT count(*) FROM list_members WHERE list_id = :list AND role = :role",
+				sql.Named("list", list),
+				sql.Named("role", models.ListOwner)).Scan(&owners)
+			if err != nil {
+				return err
+			}
+			if owners == 1 {
+				return ErrLastOwner
+			}
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM list_members WHERE list_id = :list AND user_id = :user",
+			sql.Named("list", list),
+			sql.Named("user", user))
+		return err
+	})
+}
+
+// DeleteList удаляет общий список вместе с его задачами, историей, участниками и приглашениями
+func DeleteList(list int64) error {
+	return RunInTx(models.Scope{List: list}, func(tx *Tx) error {
+		res, err := tx.tx.Exec("DELETE FROM lists WHERE id = :list", sql.Named("list", list))
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrListNotFound
+		}
+
+		return deleteListData(tx.tx, "list_id = :list", sql.Named("list", list))
+	})
+}
+
+// deleteListData удаляет задачи, историю, участников и приглашения списков, отобранных условием where
+func deleteListData(q querier, where string, args ...any) error {
+	for _, query := range []string{
+		"DELETE FROM caldav_objects WHERE task_id IN (SELECT id FROM scheduler WHERE list_id <> 0 AND " + where + ")",
+		"DELETE FROM scheduler WHERE list_id <> 0 AND " + where,
+		"DELETE FROM task_history WHERE list_id <> 0 AND " + where,
+		"DELETE FROM list_members WHERE " + where,
+		"DELETE FROM list_invitations WHERE " + where,
+	} {
+		if _, err := q.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invitationColumns — столбцы приглашения в порядке, ожидаемом scanInvitation
+const invitationColumns = "i.id, i.list_id, l.name, u.login, i.role, COALESCE(b.login, ''), i.created_at" +
+	" FROM list_invitations i JOIN lists l ON l.id = i.list_id JOIN users u ON u.id = i.user_id" +
+	" LEFT JOIN users b ON b.id = i.invited_by"
+
+// AddInvitation приглашает пользователя в общий список. Повторное приглашение
+// заменяет роль, предложенную ранее
+func AddInvitation(list, user, invitedBy int64, role string) (int64, error) {
+	var id int64
+	err := RunInTx(models.Scope{Owner: invitedBy, List: list}, func(tx *Tx) error {
+		var members int
+		err := tx.tx.QueryRow("SELECT count(*) FROM list_members WHERE list_id = :list AND user_id = :user",
+			sql.Named("list", list),
+			sql.Named("user", user)).Scan(&members)
+		if err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyMember
+		}
+
+		return tx.tx.QueryRow(`INSERT INTO list_invitations (list_id, user_id, role, invited_by, created_at)
+            VALUES (:list, :user, :role, :invited_by, :created_at)
+            ON CONFLICT (list_id, user_id) DO UPDATE SET role = excluded.role, invited_by = excluded.invited_by
+            RETURNING id`,
+			sql.Named("list", list),
+			sql.Named("user", user),
+			sql.Named("role", role),
+			sql.Named("invited_by", invitedBy),
+			sql.Named("created_at", time.Now().UTC().Format(time.RFC3339))).Scan(&id)
+	})
+	return id, err
+}
+
+// GetInvitations возвращает приглашения, ожидающие ответа пользователя
+func GetInvitations(user int64) ([]models.Invitation, error) {
+	return queryInvitations("SELECT "+invitationColumns+" WHERE i.user_id = :id ORDER BY i.id", user)
+}
+
+// GetListInvitations возвращает приглашения в общий список, ещё не принятые пользователями
+func GetListInvitations(list int64) ([]models.Invitation, error) {
+	return queryInvitations("SELECT "+invitationColumns+" WHERE i.list_id = :id ORDER BY i.id", list)
+}
+
+func queryInvitations(query string, id int64) ([]models.Invitation, error) {
+	rows, err := db.Query(query, sql.Named("id", id))
+	if err != nil {
+		return []models.Invitation{}, errors.New("error getting invitation list")
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		var inv models.Invitation
+		if err := rows.Scan(&inv.ID, &inv.ListID, &inv.ListName, &inv.Login, &inv.Role, &inv.InvitedBy, &inv.CreatedAt); err != nil {
+			return []models.Invitation{}, errors.New("data reading error")
+		}
+		invitations = append(invitations, inv)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Invitation{}, errors.New("data reading error")
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation принимает приглашение пользователя: он становится участником
+// списка с предложенной ролью, а приглашение удаляется
+func AcceptInvitation(user int64, id string) (models.List, error) {
+	var list models.List
+	err := RunInTx(models.Scope{Owner: user}, func(tx *Tx) error {
+		err := tx.tx.QueryRow("SELECT l.id, l.name, i.role, l.created_at FROM list_invitations i JOIN lists l ON l.id = i.list_id"+
+			" WHERE i.id = :id AND i.user_id = :user",
+			sql.Named("id", id),
+			sql.Named("user", user)).Scan(&list.ID, &list.Name, &list.Role, &list.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvitationNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("INSERT OR REPLACE INTO list_members (list_id, user_id, role) VALUES (:list, :user, :role)",
+			sql.Named("list", list.ID),
+			sql.Named("user", user),
+			sql.Named("role", list.Role))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM list_invitations WHERE id = :id", sql.Named("id", id))
+		return err
+	})
+	if err != nil {
+		return models.List{}, err
+	}
+
+	return list, nil
+}
+
+// DeclineInvitation удаляет приглашение, адресованное пользователю
+func DeclineInvitation(user int64, id string) error {
+	return deleteInvitation("DELETE FROM list_invitations WHERE id = :id AND user_id = :owner", id, user)
+}
+
+// CancelInvitation отзывает приглашение в общий список
+func CancelInvitation(list int64, id string) error {
+	return deleteInvitation("DELETE FROM list_invitations WHERE id = :id AND list_id = :owner", id, list)
+}
+
+func deleteInvitation(query, id string, owner int64) error {
+	res, err := db.Exec(query, sql.Named("id", id), sql.Named("owner", owner))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// Ошибки входа через провайдера OpenID Connect
+var (
+	ErrOIDCStateNotFound = errors.New("oidc state not found")
+	ErrIdentityNotFound  = errors.New("identity not found")
+)
+
+// AddOIDCState сохраняет незавершённый вход через провайдера до expiresAt. Параметр state
+// хранится только в виде хэша. Заодно удаляются истёкшие незавершённые входы
+func AddOIDCState(hash string, state models.OIDCState, now, expiresAt time.Time) error {
+	return RunInTx(models.Scope{}, func(tx *Tx) error {
+		if _, err := tx.tx.Exec("DELETE FROM oidc_states WHERE expires_at < :now",
+			sql.Named("now", now.UTC().Format(time.RFC3339))); err != nil {
+			return err
+		}
+		_, err := tx.tx.Exec("INSERT INTO oidc_states (hash, verifier, nonce, expires_at) VALUES (:hash, :verifier, :nonce, :expires_at)",
+			sql.Named("hash", hash),
+			sql.Named("verifier", state.Verifier),
+			sql.Named("nonce", state.Nonce),
+			sql.Named("expires_at", expiresAt.UTC().Format(time.RFC3339)))
+		return err
+	})
+}
+
+// TakeOIDCState возвращает и удаляет незавершённый вход, если его срок не истёк к now.
+// Каждый state действует один раз
+func TakeOIDCState(hash string, now time.Time) (models.OIDCState, error) {
+	var state models.OIDCState
+	err := RunInTx(models.Scope{}, func(tx *Tx) error {
+		err := tx.tx.QueryRow("SELECT verifier, nonce FROM oidc_states WHERE hash = :hash AND expires_at >= :now",
+			sql.Named("hash", hash),
+			sql.Named("now", now.UTC().Format(time.RFC3339))).Scan(&state.Verifier, &state.Nonce)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOIDCStateNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.tx.Exec("DELETE FROM oidc_states WHERE hash = :hash", sql.Named("hash", hash))
+		return err
+	})
+	if err != nil {
+		return models.OIDCState{}, err
+	}
+	return state, nil
+}
+
+// GetIdentity возвращает учётную запись, связанную с пользователем subject провайдера issuer
+func GetIdentity(issuer, subject string) (models.User, error) {
+	var id int64
+	err := db.QueryRow("SELECT user_id FROM user_identities WHERE issuer = :issuer AND subject = :subject",
+		sql.Named("issuer", issuer),
+		sql.Named("subject", subject)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrIdentityNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	return GetUser(id)
+}
+
+// AddIdentityUser создаёт учётную запись без пароля и связывает её с пользователем провайдера.
+// Войти в такую учётную запись можно только через провайдера, пока ей не задан пароль
+func AddIdentityUser(user models.User, identity models.Identity) (models.User, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := RunInTx(models.Scope{}, func(tx *Tx) error {
+		res, err := tx.tx.Exec("INSERT INTO users (login, password_hash, role, created_at) VALUES (:login, '', :role, :created_at)",
+			sql.Named("login", user.Login),
+			sql.Named("role", user.Role),
+			sql.Named("created_at", now))
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return ErrLoginTaken
+			}
+			return err
+		}
+		if user.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		return addIdentity(tx.tx, identity, user.ID, now)
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return GetUser(user.ID)
+}
+
+// LinkIdentity связывает пользователя провайдера с существующей учётной записью
+func LinkIdentity(identity models.Identity) error {
+	return addIdentity(db, identity, identity.User, time.Now().UTC().Format(time.RFC3339))
+}
+
+func addIdentity(q querier, identity models.Identity, user int64, now string) error {
+	_, err := q.Exec(`INSERT INTO user_identities (issuer, subject, user_id, created_at) VALUES (:issuer, :subject, :user_id, :created_at)
+        ON CONFLICT (issuer, subject) DO UPDATE SET user_id = excluded.user_id`,
+		sql.Named("issuer", identity.Issuer),
+		sql.Named("subject", identity.Subject),
+		sql.Named("user_id", user),
+		sql.Named("created_at", now))
+	return err
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// ClaimReminder отмечает напоминание отправленным по каналу. Возвращает false,
+// если оно уже было отправлено, — так одно напоминание не уходит дважды
+func ClaimReminder(reminder models.Reminder, channel string, now time.Time) (bool, error) {
+	res, err := db.Exec("INSERT OR IGNORE INTO reminders (task_id, task_date, lead, channel, sent_at) VALUES (:task_id, :task_date, :lead, :channel, :sent_at)",
+		sql.Named("task_id", reminder.TaskID),
+		sql.Named("task_date", reminder.Date),
+		sql.Named("lead", reminder.Lead),
+		sql.Named("channel", channel),
+		sql.Named("sent_at", now.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// ReleaseReminder снимает отметку об отправке, чтобы напоминание, которое не удалось
+// доставить, было отправлено повторно
+func ReleaseReminder(reminder models.Reminder, channel string) error {
+	_, err := db.Exec("DELETE FROM reminders WHERE task_id = :task_id AND task_date = :task_date AND lead = :lead AND channel = :channel",
+		sql.Named("task_id", reminder.TaskID),
+		sql.Named("task_date", reminder.Date),
+		sql.Named("lead", reminder.Lead),
+		sql.Named("channel", channel))
+	return err
+}
+
+// AddNotification добавляет напоминание в ленту уведомлений
+func AddNotification(n models.Notification) (models.Notification, error) {
+	if n.CreatedAt == "" {
+		n.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	res, err := db.Exec("INSERT INTO notifications (task_id, title, date, due_at, created_at, owner) VALUES (:task_id, :title, :date, :due_at, :created_at, :owner)",
+		sql.Named("task_id", n.TaskID),
+		sql.Named("title", n.Title),
+		sql.Named("date", n.Date),
+		sql.Named("due_at", n.DueAt),
+		sql.Named("created_at", n.CreatedAt),
+		sql.Named("owner", n.Owner))
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	if n.ID, err = res.LastInsertId(); err != nil {
+		return models.Notification{}, err
+	}
+	return n, nil
+}
+
+// GetNotifications возвращает уведомления пользователя с номером больше since в порядке добавления
+func GetNotifications(owner int64, since int64, limit int) ([]models.Notification, error) {
+	rows, err := db.Query("SELECT id, task_id, title, date, due_at, created_at, owner FROM notifications WHERE id > :since AND owner = :owner ORDER BY id LIMIT :limit",
+		sql.Named("since", since),
+		sql.Named("owner", owner),
+		sql.Named("limit", limit))
+	if err != nil {
+		return []models.Notification{}, errors.New("error getting notification list")
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.TaskID, &n.Title, &n.Date, &n.DueAt, &n.CreatedAt, &n.Owner); err != nil {
+			return []models.Notification{}, errors.New("data reading error")
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Notification{}, errors.New("data reading error")
+	}
+
+	return notifications, nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// Ошибки проверки сессий
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrRefreshReused   = errors.New("refresh token reused")
+)
+
+// AddSession открывает сессию пользователя с первым refresh-токеном, хэш которого
+// передаётся в refreshHash. Заодно удаляются сессии, срок продления которых истёк
+func AddSession(session models.Session, refreshHash string, expiresAt time.Time) error {
+	now := time.Now().UTC()
+	return RunInTx(models.Scope{Owner: session.User}, func(tx *Tx) error {
+		for _, query := range []string{
+			"DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE expires_at < :now)",
+			"DELETE FROM sessions WHERE expires_at < :now",
+		} {
+			if _, err := tx.tx.Exec(query, sql.Named("now", now.Format(time.RFC3339))); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.tx.Exec("INSERT INTO sessions (id, user_id, created_at, subject, expires_at) VALUES (:id, :user_id, :created_at, :subject, :expires_at)",
+			sql.Named("id", session.ID),
+			sql.Named("user_id", session.User),
+			sql.Named("created_at", now.Format(time.RFC3339)),
+			sql.Named("subject", session.Subject),
+			sql.Named("expires_at", expiresAt.UTC().Format(time.RFC3339)))
+		if err != nil {
+			return err
+		}
+		return addRefreshToken(tx.tx, refreshHash, session.ID, now)
+	})
+}
+
+func addRefreshToken(q querier, hash, session string, now time.Time) error {
+	_, err := q.Exec("INSERT INTO refresh_tokens (hash, session_id, created_at) VALUES (:hash, :session_id, :created_at)",
+		sql.Named("hash", hash),
+		sql.Named("session_id", session),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+	return err
+}
+
+// GetSession возвращает сессию, если она не закрыта и срок её продления не истёк к now
+func GetSession(id string, now time.Time) (models.Session, error) {
+	return getSession(db, id, now)
+}
+
+func getSession(q querier, id string, now time.Time) (models.Session, error) {
+	session := models.Session{ID: id}
+	err := q.QueryRow("SELECT user_id, subject FROM sessions WHERE id = :id AND expires_at >= :now",
+		sql.Named("id", id),
+		sql.Named("now", now.UTC().Format(time.RFC3339))).Scan(&session.User, &session.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// RotateRefreshToken обменивает refresh-токен с хэшем oldHash на новый с хэшем newHash
+// и продлевает сессию до expiresAt. Каждый refresh-токен действует один раз: повторное
+// предъявление означает, что токен украден, поэтому сессия закрывается и возвращается ErrRefreshReused
+func RotateRefreshToken(oldHash, newHash string, now, expiresAt time.Time) (models.Session, error) {
+	var session models.Session
+	reused := false
+	err := RunInTx(models.Scope{}, func(tx *Tx) error {
+		var id, usedAt string
+		err := tx.tx.QueryRow("SELECT session_id, used_at FROM refresh_tokens WHERE hash = :hash",
+			sql.Named("hash", oldHash)).Scan(&id, &usedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if usedAt != "" {
+			reused = true
+			return deleteSession(tx.tx, id)
+		}
+
+		if session, err = getSession(tx.tx, id, now); err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("UPDATE refresh_tokens SET used_at = :now WHERE hash = :hash",
+			sql.Named("now", now.UTC().Format(time.RFC3339)),
+			sql.Named("hash", oldHash))
+		if err != nil {
+			return err
+		}
+		if err := addRefreshToken(tx.tx, newHash, id, now); err != nil {
+			return err
+		}
+		_, err = tx.tx.Exec("UPDATE sessions SET expires_at = :expires_at WHERE id = :id",
+			sql.Named("expires_at", expiresAt.UTC().Format(time.RFC3339)),
+			sql.Named("id", id))
+		return err
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+	if reused {
+		return models.Session{}, ErrRefreshReused
+	}
+	return session, nil
+}
+
+// DeleteSession закрывает сессию: её токены доступа и refresh-токены перестают действовать
+func DeleteSession(id string) error {
+	return deleteSession(db, id)
+}
+
+func deleteSession(q querier, id string) error {
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE session_id = :id",
+		"DELETE FROM sessions WHERE id = :id",
+	} {
+		if _, err := q.Exec(query, sql.Named("id", id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUserSessions закрывает все сессии пользователя
+func DeleteUserSessions(user int64) error {
+	for _, query := range []string{
+		"DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = :user_id)",
+		"DELETE FROM sessions WHERE user_id = :user_id",
+	} {
+		if _, err := db.Exec(query, sql.Named("user_id", user)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// signinColumns — столбцы журнала неудачных попыток входа в порядке, ожидаемом scanSigninFailure
+const signinColumns = "id, login, ip, created_at, login_cleared, ip_cleared"
+
+// ReserveSigninAttempt учитывает попытку входа с учётной записи login и адреса ip как неудачную,
+// если check, получив статистику неудачных попыток начиная с since, не вернул время, до которого
+// вход запрещён. Проверка и запись выполняются в одной транзакции, поэтому параллельные попытки
+// не обходят задержки. При успешном входе запись снимается функцией ReleaseSigninAttempt
+func ReserveSigninAttempt(login, ip string, now, since time.Time,
+	check func(account, addr models.SigninStats) time.Time) (int64, time.Time, error) {
+	var id int64
+	var retryAt time.Time
+	err := RunInTx(models.Scope{}, func(tx *Tx) error {
+		account, err := signinStats(tx.tx, "login", "login_cleared", login, since)
+		if err != nil {
+			return err
+		}
+		addr, err := signinStats(tx.tx, "ip", "ip_cleared", ip, since)
+		if err != nil {
+			return err
+		}
+		if retryAt = check(account, addr); !retryAt.IsZero() {
+			return nil
+		}
+
+		res, err := tx.tx.Exec("INSERT INTO signin_failures (login, ip, created_at) VALUES (:login, :ip, :created_at)",
+			sql.Named("login", login),
+			sql.Named("ip", ip),
+			sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	return id, retryAt, err
+}
+
+// signinStats считает неудачные попытки входа со значением value столбца column начиная с since,
+// кроме снятых отметкой в столбце cleared
+func signinStats(q querier, column, cleared, value string, since time.Time) (models.SigninStats, error) {
+	var stats models.SigninStats
+	var last sql.NullString
+	err := q.QueryRow("SELECT COUNT(*), MAX(created_at) FROM signin_failures WHERE "+column+" = :value AND "+cleared+" = 0 AND created_at >= :since",
+		sql.Named("value", value),
+		sql.Named("since", since.UTC().Format(time.RFC3339))).Scan(&stats.Count, &last)
+	if err != nil {
+		return models.SigninStats{}, err
+	}
+	if last.Valid {
+		if stats.Last, err = time.Parse(time.RFC3339, last.String); err != nil {
+			return models.SigninStats{}, err
+		}
+	}
+	return stats, nil
+}
+
+// ReleaseSigninAttempt снимает запись попытки id после успешного входа
+// и перестаёт учитывать прежние неудачные попытки входа в учётную запись login
+func ReleaseSigninAttempt(id int64, login string) error {
+	return RunInTx(models.Scope{}, func(tx *Tx) error {
+		if _, err := tx.tx.Exec("DELETE FROM signin_failures WHERE id = :id", sql.Named("id", id)); err != nil {
+			return err
+		}
+		_, err := tx.tx.Exec("UPDATE signin_failures SET login_cleared = 1 WHERE login = :login", sql.Named("login", login))
+		return err
+	})
+}
+
+// ClearSigninFailures снимает блокировку входа: неудачные попытки входа в учётную запись login
+// и с адреса ip перестают учитываться, но остаются в журнале. Без login и ip снимаются все блокировки
+func ClearSigninFailures(login, ip string) (int64, error) {
+	var cleared int64
+	err := RunInTx(models.Scope{}, func(tx *Tx) error {
+		if login != "" || ip == "" {
+			n, err := clearSigninFailures(tx.tx, "login", "login_cleared", login)
+			if err != nil {
+				return err
+			}
+			cleared += n
+		}
+		if ip != "" || login == "" {
+			n, err := clearSigninFailures(tx.tx, "ip", "ip_cleared", ip)
+			if err != nil {
+				return err
+			}
+			cleared += n
+		}
+		return nil
+	})
+	return cleared, err
+}
+
+// clearSigninFailures отмечает в столбце cleared попытки со значением value столбца column,
+// пустое value отмечает все попытки
+func clearSigninFailures(q querier, column, cleared, value string) (int64, error) {
+	res, err := q.Exec("UPDATE signin_failures SET "+cleared+" = 1 WHERE "+cleared+" = 0 AND (:value = '' OR "+column+" = :value)",
+		sql.Named("value", value))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetSigninFailures возвращает журнал неудачных попыток входа, начиная с последних.
+// Непустые login и ip ограничивают журнал учётной записью и адресом
+func GetSigninFailures(login, ip string, limit int) ([]models.SigninFailure, error) {
+	rows, err := db.Query("SELECT "+signinColumns+" FROM signin_failures WHERE (:login = '' OR login = :login) AND (:ip = '' OR ip = :ip) ORDER BY id DESC LIMIT :limit",
+		sql.Named("login", login),
+		sql.Named("ip", ip),
+		sql.Named("limit", limit))
+	if err != nil {
+		return []models.SigninFailure{}, errors.New("error getting signin failure list")
+	}
+	defer rows.Close()
+
+	failures := []models.SigninFailure{}
+	for rows.Next() {
+		failure, err := scanSigninFailure(rows)
+		if err != nil {
+			return []models.SigninFailure{}, errors.New("data reading error")
+		}
+		failures = append(failures, failure)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.SigninFailure{}, errors.New("data reading error")
+	}
+
+	return failures, nil
+}
+
+// scanSigninFailure читает запись журнала неудачных попыток входа из строки результата
+func scanSigninFailure(s scanner) (models.SigninFailure, error) {
+	var failure models.SigninFailure
+	var id int64
+	if err := s.Scan(&id, &failure.Login, &failure.IP, &failure.CreatedAt, &failure.LoginCleared, &failure.IPCleared); err != nil {
+		return models.SigninFailure{}, err
+	}
+	failure.ID = strconv.FormatInt(id, 10)
+	return failure, nil
+}
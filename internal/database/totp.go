@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// Ошибки второго фактора
+var (
+	ErrTOTPNotFound         = errors.New("totp not enrolled")
+	ErrTOTPStepUsed         = errors.New("totp code already used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrChallengeNotFound    = errors.New("signin challenge not found")
+)
+
+// SetTOTPSecret сохраняет новый секрет TOTP пользователя. Второй фактор включается
+// после подтверждения кодом функцией EnableTOTP
+func SetTOTPSecret(user int64, secret string, now time.Time) error {
+	_, err := db.Exec(`INSERT INTO totp (user_id, secret, created_at) VALUES (:user_id, :secret, :created_at)
+        ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, enabled = 0, last_step = 0, created_at = excluded.created_at`,
+		sql.Named("user_id", user),
+		sql.Named("secret", secret),
+		sql.Named("created_at", now.UTC().Format(time.RFC3339)))
+	return err
+}
+
+// GetTOTPSecret возвращает секрет TOTP пользователя
+func GetTOTPSecret(user int64) (models.TOTPSecret, error) {
+	secret := models.TOTPSecret{User: user}
+	err := db.QueryRow("SELECT secret, enabled, last_step FROM totp WHERE user_id = :user_id",
+		sql.Named("user_id", user)).Scan(&secret.Secret, &secret.Enabled, &secret.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTPSecret{}, ErrTOTPNotFound
+	}
+	return secret, err
+}
+
+// UseTOTPStep принимает код интервала step, если код этого или более позднего интервала
+// ещё не принимался. Иначе возвращает ErrTOTPStepUsed: перехваченный код нельзя использовать повторно
+func UseTOTPStep(user, step int64) error {
+	res, err := db.Exec("UPDATE totp SET last_step = :step WHERE user_id = :user_id AND last_step < :step",
+		sql.Named("step", step),
+		sql.Named("user_id", user))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPStepUsed
+	}
+	return nil
+}
+
+// EnableTOTP включает второй фактор пользователя и заменяет его коды восстановления
+func EnableTOTP(user int64, recoveryHashes []string) error {
+	return RunInTx(models.Scope{Owner: user}, func(tx *Tx) error {
+		if _, err := tx.tx.Exec("UPDATE totp SET enabled = 1 WHERE user_id = :user_id", sql.Named("user_id", user)); err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx.tx, user, recoveryHashes)
+	})
+}
+
+// ReplaceRecoveryCodes заменяет коды восстановления пользователя новыми
+func ReplaceRecoveryCodes(user int64, recoveryHashes []string) error {
+	return RunInTx(models.Scope{Owner: user}, func(tx *Tx) error {
+		return replaceRecoveryCodes(tx.tx, user, recoveryHashes)
+	})
+}
+
+func replaceRecoveryCodes(q querier, user int64, recoveryHashes []string) error {
+	if _, err := q.Exec("DELETE FROM totp_recovery_codes WHERE user_id = :user_id", sql.Named("user_id", user)); err != nil {
+		return err
+	}
+	for _, hash := range recoveryHashes {
+		_, err := q.Exec("INSERT INTO totp_recovery_codes (hash, user_id) VALUES (:hash, :user_id)",
+			sql.Named("hash", hash),
+			sql.Named("user_id", user))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode отмечает код восстановления использованным. Каждый код действует один раз
+func UseRecoveryCode(user int64, hash string, now time.Time) error {
+	res, err := db.Exec("UPDATE totp_recovery_codes SET used_at = :now WHERE hash = :hash AND user_id = :user_id AND used_at = ''",
+		sql.Named("now", now.UTC().Format(time.RFC3339)),
+		sql.Named("hash", hash),
+		sql.Named("user_id", user))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecoveryCodeNotFound
+	}
+	return nil
+}
+
+// CountRecoveryCodes возвращает число неиспользованных кодов восстановления пользователя
+func CountRecoveryCodes(user int64) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM totp_recovery_codes WHERE user_id = :user_id AND used_at = ''",
+		sql.Named("user_id", user)).Scan(&count)
+	return count, err
+}
+
+// DeleteTOTP отключает второй фактор пользователя вместе с кодами восстановления
+func DeleteTOTP(user int64) error {
+	return RunInTx(models.Scope{Owner: user}, func(tx *Tx) error {
+		for _, query := range []string{
+			"DELETE FROM totp_recovery_codes WHERE user_id = :user_id",
+			"DELETE FROM totp WHERE user_id = :user_id",
+		} {
+			if _, err := tx.tx.Exec(query, sql.Named("user_id", user)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddChallenge сохраняет незавершённый вход, ожидающий кода второго фактора, до expiresAt.
+// Идентификатор хранится только в виде хэша. Заодно удаляются истёкшие незавершённые входы
+func AddChallenge(hash string, challenge models.SigninChallenge, now, expiresAt time.Time) error {
+	return RunInTx(models.Scope{Owner: challenge.User}, func(tx *Tx) error {
+		if _, err := tx.tx.Exec("DELETE FROM signin_challenges WHERE expires_at < :now",
+			sql.Named("now", now.UTC().Format(time.RFC3339))); err != nil {
+			return err
+		}
+		_, err := tx.tx.Exec("INSERT INTO signin_challenges (hash, user_id, subject, attempt_id, expires_at) VALUES (:hash, :user_id, :subject, :attempt_id, :expires_at)",
+			sql.Named("hash", hash),
+			sql.Named("user_id", challenge.User),
+			sql.Named("subject", challenge.Subject),
+			sql.Named("attempt_id", challenge.Attempt),
+			sql.Named("expires_at", expiresAt.UTC().Format(time.RFC3339)))
+		return err
+	})
+}
+
+// GetChallenge возвращает незавершённый вход, если его срок не истёк к now
+func GetChallenge(hash string, now time.Time) (models.SigninChallenge, error) {
+	var challenge models.SigninChallenge
+	err := db.QueryRow("SELECT user_id, subject, attempt_id FROM signin_challenges WHERE hash = :hash AND expires_at >= :now",
+		sql.Named("hash", hash),
+		sql.Named("now", now.UTC().Format(time.RFC3339))).Scan(&challenge.User, &challenge.Subject, &challenge.Attempt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SigninChallenge{}, ErrChallengeNotFound
+	}
+	return challenge, err
+}
+
+// FailChallenge учитывает неверный код второго фактора. После maxAttempts неверных кодов
+// незавершённый вход удаляется, и вход нужно начинать заново с пароля
+func FailChallenge(hash string, maxAttempts int) error {
+	return RunInTx(models.Scope{}, func(tx *Tx) error {
+		if _, err := tx.tx.Exec("UPDATE signin_challenges SET attempts = attempts + 1 WHERE hash = :hash",
+			sql.Named("hash", hash)); err != nil {
+			return err
+		}
+		_, err := tx.tx.Exec("DELETE FROM signin_challenges WHERE hash = :hash AND attempts >= :max",
+			sql.Named("hash", hash),
+			sql.Named("max", maxAttempts))
+		return err
+	})
+}
+
+// DeleteChallenge завершает вход. ErrChallengeNotFound означает, что вход уже завершён
+// параллельным запросом, поэтому один незавершённый вход не открывает две сессии
+func DeleteChallenge(hash string) error {
+	res, err := db.Exec("DELETE FROM signin_challenges WHERE hash = :hash", sql.Named("hash", hash))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrChallengeNotFound
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+
+	"todo-rest/internal/models"
+)
+
+// querier объединяет *sql.DB и *sql.Tx, чтобы запросы выполнялись как вне, так и внутри транзакции
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// scanner объединяет *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Tx — единица работы: все операции выполняются в одной транзакции с блокировкой на запись
+// над задачами одной области видимости: личными задачами пользователя или общим списком
+type Tx struct {
+	tx      *sql.Tx
+	scope   models.Scope
+	history []models.TaskHistory
+}
+
+// RunInTx выполняет fn в транзакции над задачами области видимости scope. Транзакция фиксируется,
+// если fn не вернула ошибку, иначе откатывается, а ошибка возвращается вызывающему
+func RunInTx(scope models.Scope, fn func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&Tx{tx: tx, scope: scope}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Owner возвращает пользователя, от имени которого выполняется транзакция
+func (t *Tx) Owner() int64 {
+	return t.scope.Owner
+}
+
+// Scope возвращает область видимости, над задачами которой выполняется транзакция
+func (t *Tx) Scope() models.Scope {
+	return t.scope
+}
+
+// AddTask добавляет задачу в рамках транзакции
+func (t *Tx) AddTask(task models.Task) (int, error) {
+	task.Owner, task.List = t.scope.Owner, t.scope.List
+	return addTask(t.tx, task)
+}
+
+// RestoreTask возвращает удалённую задачу с прежним идентификатором в рамках транзакции
+func (t *Tx) RestoreTask(task models.Task) error {
+	task.Owner, task.List = t.scope.Owner, t.scope.List
+	return restoreTask(t.tx, task)
+}
+
+// TaskIDUsed проверяет в рамках транзакции, занят ли идентификатор задачей любого пользователя
+func (t *Tx) TaskIDUsed(id string) (bool, error) {
+	return taskIDUsed(t.tx, id)
+}
+
+// GetAllTasks читает все задачи в рамках транзакции
+func (t *Tx) GetAllTasks() ([]models.Task, error) {
+	tasks := []models.Task{}
+	err := eachTask(t.tx, "WHERE "+inScope, scopeArgs(t.scope), func(task models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	return tasks, err
+}
+
+// GetTask читает задачу в рамках транзакции
+func (t *Tx) GetTask(id string) (models.Task, error) {
+	return getTask(t.tx, t.scope, id)
+}
+
+// UpdateTask изменяет задачу в рамках транзакции
+func (t *Tx) UpdateTask(task models.Task) (models.Task, error) {
+	task.List = t.scope.List
+	return updateTask(t.tx, t.scope, task)
+}
+
+// DeleteTask удаляет задачу в рамках транзакции
+func (t *Tx) DeleteTask(id string, version int64) error {
+	return deleteTask(t.tx, t.scope, id, version)
+}
+
+// AddHistory сохраняет запись истории в рамках транзакции
+func (t *Tx) AddHistory(entry models.TaskHistory) error {
+	entry.Owner, entry.List = t.scope.Owner, t.scope.List
+	if err := addHistory(t.tx, entry); err != nil {
+		return err
+	}
+	t.history = append(t.history, entry)
+	return nil
+}
+
+// History возвращает записи истории, сохранённые в рамках транзакции
+func (t *Tx) History() []models.TaskHistory {
+	return t.history
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// Ошибки работы с учётными записями
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrLoginTaken   = errors.New("login already taken")
+)
+
+// AddUser сохраняет учётную запись. Пароль хранится только в виде хэша
+func AddUser(user models.User, passwordHash string) (int64, error) {
+	res, err := db.Exec("INSERT INTO users (login, password_hash, role, created_at) VALUES (:login, :password_hash, :role, :created_at)",
+		sql.Named("login", user.Login),
+		sql.Named("password_hash", passwordHash),
+		sql.Named("role", user.Role),
+		sql.Named("created_at", time.Now().UTC().Format(time.RFC3339)))
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, ErrLoginTaken
+		}
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// GetUser возвращает учётную запись по идентификатору
+func GetUser(id int64) (models.User, error) {
+	user, _, err := scanUser(db.QueryRow("SELECT id, login, role, created_at, password_hash FROM users WHERE id = :id",
+		sql.Named("id", id)))
+	return user, err
+}
+
+// GetUserByLogin возвращает учётную запись и хэш её пароля по имени пользователя
+func GetUserByLogin(login string) (models.User, string, error) {
+	return scanUser(db.QueryRow("SELECT id, login, role, created_at, password_hash FROM users WHERE login = :login",
+		sql.Named("login", login)))
+}
+
+// GetUsers возвращает список учётных записей
+func GetUsers() ([]models.User, error) {
+	rows, err := db.Query("SELECT id, login, role, created_at FROM users ORDER BY id")
+	if err != nil {
+		return []models.User{}, errors.New("error getting user list")
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Login, &user.Role, &user.CreatedAt); err != nil {
+			return []models.User{}, errors.New("data reading error")
+		}
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.User{}, errors.New("data reading error")
+	}
+
+	return users, nil
+}
+
+// HasCredentials сообщает, что в базе есть учётные данные для входа: пароль администратора,
+// учётные записи пользователей или ключи API
+func HasCredentials() (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id != :admin OR password_hash != '')
+		OR EXISTS (SELECT 1 FROM api_keys)`, sql.Named("admin", models.AdminID)).Scan(&exists)
+	return exists, err
+}
+
+// SetPasswordHash заменяет хэш пароля учётной записи
+func SetPasswordHash(id int64, hash string) error {
+	res, err := db.Exec("UPDATE users SET password_hash = :hash WHERE id = :id",
+		sql.Named("hash", hash),
+		sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// DeleteUser удаляет учётную запись вместе с её личными задачами, историей, подписками,
+// webhook и уведомлениями. Пользователь выходит из общих списков, а списки,
+// в которых не осталось владельцев, удаляются
+func DeleteUser(id int64) error {
+	return RunInTx(models.Scope{Owner: id}, func(tx *Tx) error {
+		res, err := tx.tx.Exec("DELETE FROM users WHERE id = :id", sql.Named("id", id))
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrUserNotFound
+		}
+
+		for _, query := range []string{
+			"DELETE FROM caldav_objects WHERE task_id IN (SELECT id FROM scheduler WHERE owner = :id AND list_id = 0)",
+			"DELETE FROM scheduler WHERE owner = :id AND list_id = 0",
+			"DELETE FROM task_history WHERE owner = :id AND list_id = 0",
+			"DELETE FROM feeds WHERE owner = :id",
+			"DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE owner = :id)",
+			"DELETE FROM webhooks WHERE owner = :id",
+			"DELETE FROM notifications WHERE owner = :id",
+			"DELETE FROM api_keys WHERE owner = :id",
+			"DELETE FROM totp WHERE user_id = :id",
+			"DELETE FROM totp_recovery_codes WHERE user_id = :id",
+			"DELETE FROM signin_challenges WHERE user_id = :id",
+			"DELETE FROM user_identities WHERE user_id = :id",
+			"DELETE FROM list_members WHERE user_id = :id",
+			"DELETE FROM list_invitations WHERE user_id = :id",
+			"DELETE FROM refresh_tokens WHERE session_id IN (SELECT id FROM sessions WHERE user_id = :id)",
+			"DELETE FROM sessions WHERE user_id = :id",
+		} {
+			if _, err := tx.tx.Exec(query, sql.Named("id", id)); err != nil {
+				return err
+			}
+		}
+
+		orphaned := "list_id IN (SELECT id FROM lists WHERE id NOT IN (SELECT list_id FROM list_members WHERE role = :role))"
+		if err := deleteListData(tx.tx, orphaned, sql.Named("role", models.ListOwner)); err != nil {
+			return err
+		}
+		_, err = tx.tx.Exec("DELETE FROM lists WHERE id NOT IN (SELECT list_id FROM list_members WHERE role = :role)",
+			sql.Named("role", models.ListOwner))
+		return err
+	})
+}
+
+// scanUser читает учётную запись и хэш её пароля
+func scanUser(row *sql.Row) (models.User, string, error) {
+	var user models.User
+	var hash string
+	if err := row.Scan(&user.ID, &user.Login, &user.Role, &user.CreatedAt, &hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, "", ErrUserNotFound
+		}
+		return models.User{}, "", err
+	}
+	return user, hash, nil
+}
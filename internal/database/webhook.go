@@ -0,0 +1,178 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"todo-rest/internal/models"
+)
+
+// ErrWebhookNotFound возвращается, если webhook с указанным идентификатором нет
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// AddWebhook сохраняет webhook вместе с секретом для подписи запросов
+func AddWebhook(hook models.Webhook) (int, error) {
+	res, err := db.Exec("INSERT INTO webhooks (url, secret, events, created_at, owner) VALUES (:url, :secret, :events, :created_at, :owner)",
+		sql.Named("url", hook.URL),
+		sql.Named("secret", hook.Secret),
+		sql.Named("events", strings.Join(hook.Events, ",")),
+		sql.Named("created_at", time.Now().UTC().Format(time.RFC3339)),
+		sql.Named("owner", hook.Owner))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetWebhooks возвращает список webhook пользователя без секретов
+func GetWebhooks(owner int64) ([]models.Webhook, error) {
+	rows, err := db.Query("SELECT id, url, events, created_at, owner FROM webhooks WHERE owner = :owner ORDER BY id",
+		sql.Named("owner", owner))
+	if err != nil {
+		return []models.Webhook{}, errors.New("error getting webhook list")
+	}
+	defer rows.Close()
+
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		var events string
+		if err := rows.Scan(&hook.ID, &hook.URL, &events, &hook.CreatedAt, &hook.Owner); err != nil {
+			return []models.Webhook{}, errors.New("data reading error")
+		}
+		hook.Events = []string{}
+		if events != "" {
+			hook.Events = strings.Split(events, ",")
+		}
+		hooks = append(hooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.Webhook{}, errors.New("data reading error")
+	}
+
+	return hooks, nil
+}
+
+// DeleteWebhook удаляет webhook пользователя вместе с журналом и очередью его доставок
+func DeleteWebhook(owner int64, id string) error {
+	return RunInTx(models.Scope{Owner: owner}, func(tx *Tx) error {
+		res, err := tx.tx.Exec("DELETE FROM webhooks WHERE id = :id AND owner = :owner", sql.Named("id", id), sql.Named("owner", owner))
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return ErrWebhookNotFound
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = :id", sql.Named("id", id))
+		return err
+	})
+}
+
+// AddDeliveries ставит событие в очередь доставки всем подписанным на него webhook пользователя,
+// а для задачи общего списка — webhook всех его участников, в рамках транзакции, в которой изменена задача
+func (t *Tx) AddDeliveries(event, payload string, now time.Time) error {
+	stamp := now.UTC().Format(time.RFC3339)
+	_, err := t.tx.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at)
+        SELECT id, :event, :payload, :status, :now, :now FROM webhooks
+        WHERE (:list = 0 AND owner = :owner OR owner IN (SELECT user_id FROM list_members WHERE list_id = :list))
+        AND (events = '' OR ',' || events || ',' LIKE '%,' || :event || ',%')`,
+		sql.Named("event", event),
+		sql.Named("payload", payload),
+		sql.Named("status", models.DeliveryPending),
+		sql.Named("now", stamp),
+		sql.Named("owner", t.scope.Owner),
+		sql.Named("list", t.scope.List))
+	return err
+}
+
+// GetDueDeliveries возвращает доставки, время очередной попытки которых наступило,
+// вместе с адресом и секретом webhook
+func GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.Query("SELECT "+deliveryColumns+", w.url, w.secret"+
+		" FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id"+
+		" WHERE d.status = :status AND d.next_attempt_at <= :now ORDER BY d.id LIMIT :limit",
+		sql.Named("status", models.DeliveryPending),
+		sql.Named("now", now.UTC().Format(time.RFC3339)),
+		sql.Named("limit", limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanDelivery(rows, &d, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetDeliveries возвращает журнал доставок webhook пользователя, начиная с последних
+func GetDeliveries(owner int64, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id"+
+		" WHERE d.webhook_id = :webhook_id AND w.owner = :owner ORDER BY d.id DESC LIMIT :limit",
+		sql.Named("webhook_id", webhookID),
+		sql.Named("owner", owner),
+		sql.Named("limit", limit))
+	if err != nil {
+		return []models.WebhookDelivery{}, errors.New("error getting delivery list")
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return []models.WebhookDelivery{}, errors.New("data reading error")
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err = rows.Err(); err != nil {
+		return []models.WebhookDelivery{}, errors.New("data reading error")
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery сохраняет результат попытки доставки
+func UpdateDelivery(d models.WebhookDelivery) error {
+	_, err := db.Exec(`UPDATE webhook_deliveries SET status = :status, attempts = :attempts, last_status = :last_status,
+        last_error = :last_error, next_attempt_at = :next_attempt_at, delivered_at = :delivered_at WHERE id = :id`,
+		sql.Named("status", d.Status),
+		sql.Named("attempts", d.Attempts),
+		sql.Named("last_status", d.LastStatus),
+		sql.Named("last_error", d.LastError),
+		sql.Named("next_attempt_at", d.NextAttemptAt),
+		sql.Named("delivered_at", d.DeliveredAt),
+		sql.Named("id", d.ID))
+	return err
+}
+
+// deliveryColumns — столбцы доставки в порядке, ожидаемом scanDelivery
+const deliveryColumns = "d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.last_status," +
+	" d.last_error, d.next_attempt_at, d.created_at, d.delivered_at"
+
+// scanDelivery читает доставку из строки результата. extra — дополнительные столбцы после deliveryColumns
+func scanDelivery(s scanner, d *models.WebhookDelivery, extra ...any) error {
+	dest := []any{&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.LastStatus,
+		&d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt}
+	return s.Scan(append(dest, extra...)...)
+}
@@ -0,0 +1,28 @@
+package models
+
+// Области действия ключей API: просмотр, изменение задач и администрирование.
+// Каждая следующая область включает предыдущие
+const (
+	ScopeRead       = "read"
+	ScopeTasksWrite = "tasks:write"
+	ScopeAdmin      = "admin"
+)
+
+// APIKey описывает именованный ключ API для скриптов и интеграций.
+// Сам ключ возвращается только в ответе на запрос создания, хранится его хэш
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+	Key        string   `json:"key,omitempty"`
+	Owner      int64    `json:"-"`
+}
+
+// APIKeyResponse описывает ответ на запрос создания ключа API
+type APIKeyResponse struct {
+	APIKey
+	Error string `json:"error,omitempty"`
+}
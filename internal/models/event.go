@@ -0,0 +1,27 @@
+package models
+
+// Типы событий об изменении задач
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	EventDone    = "done"
+	// EventReload сообщает, что пропущенные события недоступны и список задач нужно перечитать
+	EventReload = "reload"
+)
+
+// Event описывает событие об изменении задачи, передаваемое подключённым клиентам
+type Event struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	TaskID string `json:"task_id,omitempty"`
+	Task   *Task  `json:"task,omitempty"`
+	Actor  string `json:"actor,omitempty"`
+	Time   string `json:"time"`
+	// Owner — пользователь, которому передаётся событие, 0 — всем пользователям
+	Owner int64 `json:"-"`
+	// List — общий список, участникам которого передаётся событие
+	List int64 `json:"list_id,omitempty"`
+	// Members — участники списка List на момент публикации события
+	Members []int64 `json:"-"`
+}
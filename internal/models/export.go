@@ -0,0 +1,47 @@
+package models
+
+// ExportVersion — версия формата выгрузки задач
+const ExportVersion = 1
+
+// Режимы загрузки задач
+const (
+	ImportMerge   = "merge"
+	ImportReplace = "replace"
+)
+
+// ExportTask описывает задачу в выгрузке вместе с её служебными данными
+type ExportTask struct {
+	ID      string `json:"id,omitempty"`
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Comment string `json:"comment,omitempty"`
+	Repeat  string `json:"repeat,omitempty"`
+	Version int64  `json:"version,omitempty"`
+	// Anchor — дата по расписанию, с которой отложена повторяющаяся задача
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// ExportDocument описывает файл выгрузки всех задач
+type ExportDocument struct {
+	Version    int          `json:"version"`
+	ExportedAt string       `json:"exported_at,omitempty"`
+	Tasks      []ExportTask `json:"tasks"`
+}
+
+// ImportError описывает ошибку загрузки одной задачи. Row — номер строки файла CSV
+// или todo.txt, для JSON и iCalendar — порядковый номер задачи в файле
+type ImportError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult описывает итог загрузки задач
+type ImportResult struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Deleted int           `json:"deleted"`
+	Errors  []ImportError `json:"errors"`
+	Error   string        `json:"error,omitempty"`
+}
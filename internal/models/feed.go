@@ -0,0 +1,43 @@
+package models
+
+// Типы компонентов календаря, в которые выгружаются задачи
+const (
+	ComponentTodo  = "VTODO"
+	ComponentEvent = "VEVENT"
+)
+
+// Feed описывает подписку календаря на задачи по секретной ссылке
+type Feed struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Component string `json:"component"`
+	CreatedAt string `json:"created_at"`
+	Token     string `json:"token,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Owner     int64  `json:"-"`
+}
+
+// FeedResponse описывает ответ на запрос создания подписки
+type FeedResponse struct {
+	Feed
+	Error string `json:"error,omitempty"`
+}
+
+// CalendarItem описывает компонент VTODO или VEVENT, прочитанный из файла iCalendar
+type CalendarItem struct {
+	Component string
+	UID       string
+	Title     string
+	Comment   string
+	Start     string
+	Due       string
+	RRule     string
+	Status    string
+}
+
+// CalDAVObject связывает имя ресурса CalDAV, выбранное клиентом, с задачей
+type CalDAVObject struct {
+	Name   string
+	TaskID string
+	UID    string
+}
@@ -0,0 +1,31 @@
+package models
+
+// Действия, фиксируемые в истории изменений задачи
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionDone   = "done"
+	ActionRevert = "revert"
+	ActionSnooze = "snooze"
+)
+
+// TaskHistory описывает запись в истории изменений задачи
+type TaskHistory struct {
+	ID        int64                  `json:"id"`
+	TaskID    string                 `json:"task_id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	CreatedAt string                 `json:"created_at"`
+	Before    *Task                  `json:"before,omitempty"`
+	After     *Task                  `json:"after,omitempty"`
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	Owner     int64                  `json:"-"`
+	List      int64                  `json:"-"`
+}
+
+// FieldChange описывает изменение одного поля задачи
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// Роли участников общего списка задач
+const (
+	ListViewer = "viewer"
+	ListEditor = "editor"
+	ListOwner  = "owner"
+)
+
+// Scope определяет, с какими задачами работает запрос: с личными задачами
+// пользователя Owner или, если задан List, с задачами общего списка
+type Scope struct {
+	Owner int64
+	List  int64
+}
+
+// List описывает общий список задач и роль в нём вызывающей стороны
+type List struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ListResponse описывает ответ на запрос создания списка
+type ListResponse struct {
+	List
+	Error string `json:"error,omitempty"`
+}
+
+// ListMember описывает участника общего списка
+type ListMember struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+	Role   string `json:"role"`
+}
+
+// Invitation описывает приглашение пользователя в общий список
+type Invitation struct {
+	ID        int64  `json:"id"`
+	ListID    int64  `json:"list_id"`
+	ListName  string `json:"list_name,omitempty"`
+	Login     string `json:"login"`
+	Role      string `json:"role"`
+	InvitedBy string `json:"invited_by,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// InvitationResponse описывает ответ на запрос приглашения
+type InvitationResponse struct {
+	Invitation
+	Error string `json:"error,omitempty"`
+}
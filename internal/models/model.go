@@ -7,6 +7,16 @@ type Task struct {
 	Title   string `json:"title" db:"title"`
 	Comment string `json:"comment,omitempty" db:"comment"`
 	Repeat  string `json:"repeat,omitempty" db:"repeat"`
+	Version int64  `json:"-" db:"version"`
+	// Anchor — дата по расписанию повторения, с которой задача отложена.
+	// От неё отсчитывается следующая дата после выполнения
+	Anchor string `json:"anchor,omitempty" db:"anchor"`
+	// Overdue отмечает в ответах задачи, дата которых прошла
+	Overdue bool `json:"overdue,omitempty" db:"-"`
+	// Owner — идентификатор пользователя, которому принадлежит задача
+	Owner int64 `json:"-" db:"owner"`
+	// List — общий список, в котором находится задача, 0 для личной задачи
+	List int64 `json:"list_id,omitempty" db:"list_id"`
 }
 
 // TaskResponse описывает структуру ответа
@@ -15,8 +25,10 @@ type TaskResponse struct {
 	Error string `json:"error,omitempty"`
 }
 
-// SignRequest содержит структуру для пароля из JSON-запроса
+// Credentials содержит имя пользователя и пароль из JSON-запроса.
+// Без имени пользователя пароль сравнивается с общим паролем TODO_PASSWORD
 type Credentials struct {
+	Login    string `json:"login,omitempty"`
 	Password string `json:"password"`
 }
 
@@ -29,5 +41,20 @@ type TaskFilter struct {
 // JWTTokenResponse содержит структуру для ответа с токеном
 type JWTTokenResponse struct {
 	Token string `json:"token,omitempty"`
-	Error string `json:"error,omitempty"`
+	// RefreshToken обменивается на новую пару токенов, когда срок действия Token истекает
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresIn — срок действия Token в секундах
+	ExpiresIn int64 `json:"expires_in,omitempty"`
+	// CSRFToken передаётся в заголовке X-CSRF-Token изменяющих запросов с токеном из куки
+	CSRFToken string `json:"csrf_token,omitempty"`
+	// TOTPRequired означает, что пароль верен, но для входа нужен код второго фактора:
+	// его вместе с Challenge передают в /api/signin/totp, а ExpiresIn — срок действия Challenge
+	TOTPRequired bool   `json:"totp_required,omitempty"`
+	Challenge    string `json:"challenge,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RefreshRequest описывает запрос обмена refresh-токена на новую пару токенов
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
@@ -0,0 +1,34 @@
+package models
+
+// Каналы доставки напоминаний
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelFeed    = "feed"
+)
+
+// EventReminder — тип события о напоминании, передаваемого подключённым клиентам
+const EventReminder = "reminder"
+
+// Reminder описывает напоминание о задаче. Lead — опережение относительно
+// момента задачи DueAt, с которым отправлено напоминание
+type Reminder struct {
+	TaskID  string `json:"task_id"`
+	Title   string `json:"title"`
+	Comment string `json:"comment,omitempty"`
+	Date    string `json:"date"`
+	DueAt   string `json:"due_at"`
+	Lead    string `json:"lead"`
+	Owner   int64  `json:"-"`
+}
+
+// Notification описывает запись ленты уведомлений для настольных клиентов
+type Notification struct {
+	ID        int64  `json:"id"`
+	TaskID    string `json:"task_id"`
+	Title     string `json:"title"`
+	Date      string `json:"date"`
+	DueAt     string `json:"due_at"`
+	CreatedAt string `json:"created_at"`
+	Owner     int64  `json:"-"`
+}
@@ -0,0 +1,47 @@
+package models
+
+// TOTPStatus описывает второй фактор пользователя
+type TOTPStatus struct {
+	Enabled bool `json:"enabled"`
+	// RecoveryCodes — число неиспользованных кодов восстановления
+	RecoveryCodes int    `json:"recovery_codes"`
+	Error         string `json:"error,omitempty"`
+}
+
+// TOTPEnrollment содержит секрет TOTP и URI otpauth:// для QR-кода приложения-аутентификатора
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TOTPRequest содержит код из приложения-аутентификатора или код восстановления.
+// При входе он дополняется выданным после проверки пароля Challenge
+type TOTPRequest struct {
+	Challenge    string `json:"challenge,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// RecoveryCodesResponse содержит коды восстановления. Они показываются один раз
+type RecoveryCodesResponse struct {
+	Codes []string `json:"recovery_codes,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// TOTPSecret — секрет TOTP пользователя. LastStep — последний принятый интервал,
+// код которого повторно не принимается
+type TOTPSecret struct {
+	User     int64
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+// SigninChallenge — незавершённый вход, ожидающий кода второго фактора
+type SigninChallenge struct {
+	User    int64
+	Subject string
+	// Attempt — попытка входа, учтённая как неудачная, пока второй фактор не подтверждён
+	Attempt int64
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Роли пользователей
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// AdminID — учётная запись администратора. Ей принадлежат задачи, созданные
+// до появления пользователей, в неё выполняется вход по общему паролю TODO_PASSWORD
+const AdminID int64 = 1
+
+// AdminLogin — имя учётной записи администратора
+const AdminLogin = "admin"
+
+// User описывает учётную запись пользователя. Пароль передаётся только в запросах
+type User struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+	Password  string `json:"password,omitempty"`
+}
+
+// UserResponse описывает ответ на запрос создания пользователя
+type UserResponse struct {
+	User
+	Error string `json:"error,omitempty"`
+}
+
+// Session описывает сессию, открытую при входе пользователя. Subject записывается
+// в историю изменений как автор, пустой Subject означает вход по общему паролю
+type Session struct {
+	ID      string
+	User    int64
+	Subject string
+}
+
+// SigninFailure — запись журнала неудачных попыток входа
+type SigninFailure struct {
+	ID        string `json:"id"`
+	Login     string `json:"login"`
+	IP        string `json:"ip"`
+	CreatedAt string `json:"created_at"`
+	// LoginCleared и IPCleared означают, что попытка больше не учитывается для учётной записи
+	// (после успешного входа или снятия блокировки) и для адреса (после снятия блокировки)
+	LoginCleared bool `json:"login_cleared"`
+	IPCleared    bool `json:"ip_cleared"`
+}
+
+// SigninStats описывает неудачные попытки входа с учётной записи или адреса
+type SigninStats struct {
+	Count int
+	Last  time.Time
+}
+
+// OIDCState — незавершённый вход через провайдера OpenID Connect: проверочный код PKCE
+// и nonce, который провайдер возвращает в ID-токене
+type OIDCState struct {
+	Verifier string
+	Nonce    string
+}
+
+// Identity связывает пользователя провайдера OpenID Connect с учётной записью
+type Identity struct {
+	Issuer  string
+	Subject string
+	User    int64
+}
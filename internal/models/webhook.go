@@ -0,0 +1,52 @@
+package models
+
+// Состояния доставки webhook
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook описывает адрес, на который отправляются события об изменении задач.
+// Пустой список событий означает подписку на все события
+type Webhook struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+	Secret    string   `json:"secret,omitempty"`
+	Owner     int64    `json:"-"`
+}
+
+// WebhookResponse описывает ответ на запрос регистрации webhook
+type WebhookResponse struct {
+	Webhook
+	Error string `json:"error,omitempty"`
+}
+
+// WebhookPayload описывает тело запроса, отправляемого на адрес webhook
+type WebhookPayload struct {
+	Event  string `json:"event"`
+	TaskID string `json:"task_id"`
+	Task   *Task  `json:"task,omitempty"`
+	Actor  string `json:"actor"`
+	Time   string `json:"time"`
+}
+
+// WebhookDelivery описывает доставку события на адрес webhook и результат последней попытки.
+// URL и Secret заполняются только для доставок, ожидающих отправки
+type WebhookDelivery struct {
+	ID            string `json:"id"`
+	WebhookID     string `json:"webhook_id"`
+	Event         string `json:"event"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastStatus    int    `json:"last_status,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+	DeliveredAt   string `json:"delivered_at,omitempty"`
+	URL           string `json:"-"`
+	Secret        string `json:"-"`
+}
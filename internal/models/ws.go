@@ -0,0 +1,36 @@
+package models
+
+// Типы сообщений, которые сервер отправляет по WebSocket
+const (
+	MessageResult = "result"
+	MessageEvent  = "event"
+)
+
+// WSRequest описывает операцию, полученную по WebSocket.
+// ID возвращается в ответе, чтобы клиент мог сопоставить его с запросом
+type WSRequest struct {
+	ID      string `json:"id"`
+	Op      string `json:"op"`
+	TaskID  string `json:"task_id,omitempty"`
+	Task    *Task  `json:"task,omitempty"`
+	Search  string `json:"search,omitempty"`
+	IfMatch string `json:"if_match,omitempty"`
+	Overdue string `json:"overdue,omitempty"`
+	// List — общий список, с задачами которого выполняется операция
+	List string `json:"list,omitempty"`
+}
+
+// WSMessage описывает ответ на операцию или уведомление об изменении задачи
+type WSMessage struct {
+	Type   string `json:"type"`
+	ID     string `json:"id,omitempty"`
+	Op     string `json:"op,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Task   *Task  `json:"task,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Tasks  []Task `json:"tasks,omitempty"`
+	Event  *Event `json:"event,omitempty"`
+	// RetryAfter — через сколько секунд можно повторить операцию, отклонённую ограничением частоты
+	RetryAfter int `json:"retry_after,omitempty"`
+}
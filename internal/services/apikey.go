@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// APIKeyPrefix отличает ключи API от токенов доступа в заголовке Authorization
+const APIKeyPrefix = "todo_"
+
+// apiKeyPrefixLen — длина начала ключа, сохраняемого открыто, чтобы ключ можно было узнать в списке
+const apiKeyPrefixLen = len(APIKeyPrefix) + 8
+
+// Ошибки проверки ключей API
+var (
+	ErrAPIKeyName  = errors.New("API key name is required")
+	ErrAPIKeyScope = errors.New("unknown API key scope")
+	ErrScopeDenied = errors.New("API key scope exceeds caller permissions")
+)
+
+// scopeRank упорядочивает области действия: каждая следующая включает предыдущие
+var scopeRank = map[string]int{
+	models.ScopeRead:       1,
+	models.ScopeTasksWrite: 2,
+	models.ScopeAdmin:      3,
+}
+
+// scopesKey — ключ контекста для областей действия ключа API, которым выполнен запрос
+type scopesKey struct{}
+
+// WithScopes сохраняет в контексте запроса области действия ключа API
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// HasScope сообщает, разрешена ли вызывающей стороне область действия scope.
+// Запросы по токену доступа ограничены только ролью пользователя
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+	if !ok {
+		return true
+	}
+	for _, granted := range scopes {
+		if scopeRank[granted] >= scopeRank[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// methodScope возвращает область действия, необходимую для метода запроса
+func methodScope(method string) string {
+	if safeMethod(method) {
+		return models.ScopeRead
+	}
+	return models.ScopeTasksWrite
+}
+
+// CreateAPIKey выпускает ключ API пользователя из контекста. Ключ не может получить
+// области действия шире, чем у вызывающей стороны, а admin доступна только администраторам
+func CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return models.APIKey{}, ErrAPIKeyName
+	}
+	if len(key.Scopes) == 0 {
+		key.Scopes = []string{models.ScopeRead}
+	}
+	slices.Sort(key.Scopes)
+	key.Scopes = slices.Compact(key.Scopes)
+	for _, scope := range key.Scopes {
+		if _, ok := scopeRank[scope]; !ok {
+			return models.APIKey{}, ErrAPIKeyScope
+		}
+		if !HasScope(ctx, scope) || (scope == models.ScopeAdmin && CurrentUser(ctx).Role != models.RoleAdmin) {
+			return models.APIKey{}, ErrScopeDenied
+		}
+	}
+
+	secret, err := RandomToken(tokenIDBytes)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	plain := APIKeyPrefix + secret
+
+	key.Owner = UserID(ctx)
+	key.Prefix = plain[:apiKeyPrefixLen]
+	key.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	key.LastUsedAt = ""
+	id, err := database.AddAPIKey(key, HashToken(plain))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.ID = strconv.Itoa(id)
+	key.Key = plain
+	return key, nil
+}
+
+// APIKeyContext проверяет ключ API и возвращает контекст запроса от имени владельца ключа,
+// отмечая время использования ключа. ErrScopeDenied возвращается, если областей ключа
+// недостаточно для метода запроса
+func APIKeyContext(ctx context.Context, plain, method string) (context.Context, error) {
+	keyHash := HashToken(plain)
+	key, err := getAPIKey(ctx, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	user, err := database.GetUser(key.Owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.TouchAPIKey(key.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ctx = WithScopes(WithUser(withAPIKey(ctx, keyHash), user), key.Scopes)
+	if !HasScope(ctx, methodScope(method)) {
+		return nil, ErrScopeDenied
+	}
+	return WithIdentity(ctx, user.Login), nil
+}
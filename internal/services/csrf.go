@@ -0,0 +1,41 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"todo-rest/internal/config"
+)
+
+// Кука, из которой веб-интерфейс читает CSRF-токен, и заголовок, в котором он его возвращает
+const (
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRFToken возвращает CSRF-токен сессии. Он вычисляется из идентификатора сессии
+// и секрета подписи, поэтому не хранится на сервере и не может быть подобран
+// или подставлен в куку чужим сайтом
+func CSRFToken(cfg *config.JWTConfig, session string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write([]byte("csrf:" + session))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRF проверяет CSRF-токен из заголовка запроса, изменяющего данные сессии session
+func validCSRF(cfg *config.JWTConfig, r *http.Request, session string) bool {
+	token := r.Header.Get(CSRFHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(CSRFToken(cfg, session))) == 1
+}
+
+// safeMethod сообщает, что метод запроса не изменяет данные
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "REPORT":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+)
+
+// CSVColumn связывает поле задачи со столбцом CSV файла
+type CSVColumn struct {
+	Field  string
+	Header string
+}
+
+// csvFields — поля задачи, доступные для выгрузки в CSV, в порядке по умолчанию
+var csvFields = []string{"id", "date", "title", "comment", "repeat"}
+
+// ParseCSVColumns разбирает соответствие столбцов вида "title:Задача,date:Срок".
+// Пустая строка означает все поля с одноимёнными столбцами
+func ParseCSVColumns(spec string) ([]CSVColumn, error) {
+	if strings.TrimSpace(spec) == "" {
+		columns := make([]CSVColumn, len(csvFields))
+		for i, field := range csvFields {
+			columns[i] = CSVColumn{Field: field, Header: field}
+		}
+		return columns, nil
+	}
+
+	var columns []CSVColumn
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		field, header, ok := strings.Cut(item, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !ok || header == "" {
+			header = field
+		}
+		if !isCSVField(field) {
+			return nil, fmt.Errorf("unknown task field: %s", field)
+		}
+		if seen[field] {
+			return nil, fmt.Errorf("duplicate task field: %s", field)
+		}
+		seen[field] = true
+		columns = append(columns, CSVColumn{Field: field, Header: header})
+	}
+	return columns, nil
+}
+
+// WriteCSV выгружает задачи в CSV с заголовком из соответствия столбцов
+func WriteCSV(w io.Writer, columns []CSVColumn, tasks []models.Task) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		record := make([]string, len(columns))
+		for i, c := range columns {
+			record[i] = taskField(task, c.Field)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// CSVTask — задача из CSV и номер строки файла, с которой начинается её запись
+type CSVTask struct {
+	Line int
+	Task models.ExportTask
+}
+
+// ReadCSV читает задачи из CSV. Первая строка файла — заголовок, столбцы сопоставляются
+// с полями задачи по соответствию columns без учёта регистра, лишние столбцы пропускаются
+func ReadCSV(r io.Reader, columns []CSVColumn) ([]CSVTask, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("CSV header is missing")
+	}
+
+	index := make(map[string]int)
+	for _, c := range columns {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")), c.Header) {
+				index[c.Field] = i
+			}
+		}
+	}
+	if _, ok := index["title"]; !ok {
+		return nil, errors.New("CSV title column is missing")
+	}
+
+	var tasks []CSVTask
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value := func(field string) string {
+			i, ok := index[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		line, _ := cr.FieldPos(0)
+		tasks = append(tasks, CSVTask{Line: line, Task: models.ExportTask{
+			ID:      value("id"),
+			Date:    NormalizeDate(value("date")),
+			Title:   value("title"),
+			Comment: value("comment"),
+			Repeat:  value("repeat"),
+		}})
+	}
+	return tasks, nil
+}
+
+// NormalizeDate приводит дату в форматах 2006-01-02 и 02.01.2006 к формату задачи.
+// Нераспознанное значение возвращается без изменений, чтобы ошибку вернула проверка задачи
+func NormalizeDate(value string) string {
+	for _, layout := range []string{"2006-01-02", "02.01.2006"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format(config.DateFormat)
+		}
+	}
+	return value
+}
+
+// isCSVField проверяет, что поле задачи можно выгрузить в CSV
+func isCSVField(field string) bool {
+	return slices.Contains(csvFields, field)
+}
+
+// taskField возвращает значение поля задачи по его имени
+func taskField(task models.Task, field string) string {
+	switch field {
+	case "id":
+		return task.ID
+	case "date":
+		return task.Date
+	case "title":
+		return task.Title
+	case "comment":
+		return task.Comment
+	case "repeat":
+		return task.Repeat
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+
+	"todo-rest/internal/models"
+)
+
+// ETag формирует значение заголовка ETag для версии задачи
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// IfMatch возвращает условие изменения задачи по значению заголовка If-Match.
+// Пустой заголовок означает безусловное изменение. Теги сравниваются строго:
+// слабый тег W/"..." не совпадает ни с одной версией (RFC 9110, 13.1.1)
+func IfMatch(header string) Precondition {
+	return func(current models.Task) error {
+		if header == "" {
+			return nil
+		}
+
+		tag := ETag(current.Version)
+		for _, item := range strings.Split(header, ",") {
+			item = strings.TrimSpace(item)
+			if item == "*" || item == tag {
+				return nil
+			}
+		}
+
+		return ErrPreconditionFailed
+	}
+}
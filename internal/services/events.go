@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// eventBufferSize — количество последних событий, которые можно повторить при переподключении
+const eventBufferSize = 256
+
+// subscriberBufferSize — размер очереди событий подписчика. Подписчик, который
+// не успевает их читать, отключается и при переподключении получает пропущенные события
+const subscriberBufferSize = 64
+
+// Events — шина событий об изменении задач
+var Events = NewBus(eventBufferSize)
+
+// Bus рассылает события подписчикам и хранит последние события
+// для повторной отправки после переподключения. Подписчик получает только
+// события своего пользователя, общих списков, участником которых он является,
+// и события для всех пользователей
+type Bus struct {
+	mu     sync.Mutex
+	buffer []models.Event
+	size   int
+	lastID int64
+	subs   map[chan models.Event]int64
+}
+
+// NewBus создаёт шину, хранящую size последних событий
+func NewBus(size int) *Bus {
+	return &Bus{
+		size: size,
+		subs: make(map[chan models.Event]int64),
+	}
+}
+
+// AllUsers — подписка фоновых заданий на события всех пользователей
+const AllUsers int64 = 0
+
+// visible проверяет, что событие передаётся подписчику пользователя owner
+func visible(event models.Event, owner int64) bool {
+	if owner == AllUsers {
+		return true
+	}
+	if event.List != 0 {
+		return slices.Contains(event.Members, owner)
+	}
+	return event.Owner == 0 || event.Owner == owner
+}
+
+// Publish присваивает событию номер и время и рассылает его подписчикам
+func (b *Bus) Publish(event models.Event) models.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Time = time.Now().UTC().Format(time.RFC3339)
+
+	b.buffer = append(b.buffer, event)
+	if len(b.buffer) > b.size {
+		b.buffer = b.buffer[len(b.buffer)-b.size:]
+	}
+
+	for ch, owner := range b.subs {
+		if !visible(event, owner) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+
+	return event
+}
+
+// Subscribe подписывается на события пользователя owner. Если задан номер последнего
+// полученного события, возвращаются события после него, а если они уже недоступны — событие EventReload.
+// Канал закрывается при отписке или если подписчик не успевает читать события
+func (b *Bus) Subscribe(owner int64, lastID int64, resume bool) (replay []models.Event, events <-chan models.Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if resume && lastID != b.lastID {
+		oldest := b.lastID + 1
+		if len(b.buffer) > 0 {
+			oldest = b.buffer[0].ID
+		}
+		if lastID >= oldest-1 && lastID < b.lastID {
+			for _, event := range b.buffer {
+				if event.ID > lastID && visible(event, owner) {
+					replay = append(replay, event)
+				}
+			}
+		} else {
+			// События после lastID вытеснены из буфера или номер получен до перезапуска сервера
+			replay = []models.Event{{ID: b.lastID, Type: models.EventReload, Time: time.Now().UTC().Format(time.RFC3339)}}
+		}
+	}
+
+	ch := make(chan models.Event, subscriberBufferSize)
+	b.subs[ch] = owner
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return replay, ch, cancel
+}
+
+// RunInTx выполняет fn в транзакции над задачами вызывающей стороны. По сохранённым
+// записям истории в той же транзакции ставятся в очередь доставки webhook,
+// а после её фиксации публикуются события
+func RunInTx(ctx context.Context, fn func(tx *database.Tx) error) error {
+	var history []models.TaskHistory
+	scope := TaskScope(ctx)
+	err := database.RunInTx(scope, func(tx *database.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		history = tx.History()
+		return enqueueWebhooks(tx, history, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+
+	// Участники списка читаются до публикации, чтобы не обращаться к базе под блокировкой шины
+	var members []int64
+	if scope.List != 0 && len(history) > 0 {
+		if members, err = database.GetListMemberIDs(scope.List); err != nil {
+			log.Printf("Failed to get members of list %d: %v", scope.List, err)
+		}
+	}
+
+	for _, entry := range history {
+		event := historyEvent(entry)
+		event.Members = members
+		Events.Publish(event)
+	}
+	return nil
+}
+
+// historyEvent формирует событие по записи истории изменений
+func historyEvent(entry models.TaskHistory) models.Event {
+	event := models.Event{
+		TaskID: entry.TaskID,
+		Task:   entry.After,
+		Actor:  entry.Actor,
+		Owner:  entry.Owner,
+		List:   entry.List,
+	}
+
+	switch {
+	case entry.Action == models.ActionDone:
+		event.Type = models.EventDone
+	case entry.After == nil:
+		event.Type = models.EventDeleted
+	case entry.Before == nil:
+		event.Type = models.EventCreated
+	default:
+		event.Type = models.EventUpdated
+	}
+	return event
+}
@@ -0,0 +1,33 @@
+package services
+
+import "todo-rest/internal/models"
+
+// DiffTasks возвращает список изменённых полей между двумя снимками задачи
+func DiffTasks(before, after *models.Task) map[string]models.FieldChange {
+	var from, to models.Task
+	if before != nil {
+		from = *before
+	}
+	if after != nil {
+		to = *after
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"date", from.Date, to.Date},
+		{"title", from.Title, to.Title},
+		{"comment", from.Comment, to.Comment},
+		{"repeat", from.Repeat, to.Repeat},
+		{"anchor", from.Anchor, to.Anchor},
+	}
+
+	changes := make(map[string]models.FieldChange)
+	for _, f := range fields {
+		if f.from != f.to {
+			changes[f.name] = models.FieldChange{From: f.from, To: f.to}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+)
+
+// icalDays — дни недели в нотации RFC 5545, индекс соответствует номеру дня в правиле "w"
+var icalDays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// RepeatToRRule переводит правило повторения задачи в RRULE из RFC 5545
+func RepeatToRRule(repeat string) (string, error) {
+	parts := strings.Fields(repeat)
+	if len(parts) == 0 {
+		return "", errors.New("repeat rule is empty")
+	}
+
+	switch parts[0] {
+	case "d":
+		if len(parts) != 2 {
+			return "", errors.New("invalid day repeat format")
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days < 1 || days > 400 {
+			return "", errors.New("invalid day interval")
+		}
+		return fmt.Sprintf("FREQ=DAILY;INTERVAL=%d", days), nil
+	case "y":
+		return "FREQ=YEARLY", nil
+	case "w":
+		if len(parts) != 2 {
+			return "", errors.New("invalid weekday repeat format")
+		}
+		days, err := parseList(parts[1], 1, 7)
+		if err != nil {
+			return "", errors.New("invalid day of the week")
+		}
+		byDay := make([]string, len(days))
+		for i, d := range days {
+			byDay[i] = icalDays[d]
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(byDay, ","), nil
+	case "m":
+		if len(parts) < 2 || len(parts) > 3 {
+			return "", errors.New("invalid month repeat format")
+		}
+		days, err := parseList(parts[1], -2, 31)
+		if err != nil || slices.Contains(days, 0) {
+			return "", errors.New("invalid day of the month")
+		}
+		byMonthDay := "BYMONTHDAY=" + joinInts(days)
+		if len(parts) == 2 {
+			return "FREQ=MONTHLY;" + byMonthDay, nil
+		}
+		months, err := parseList(parts[2], 1, 12)
+		if err != nil {
+			return "", errors.New("invalid month")
+		}
+		return "FREQ=YEARLY;BYMONTH=" + joinInts(months) + ";" + byMonthDay, nil
+	default:
+		return "", fmt.Errorf("invalid repeat type: %s", parts[0])
+	}
+}
+
+// WriteCalendar записывает задачи в формате iCalendar как компоненты VTODO или VEVENT.
+// uid возвращает идентификатор компонента для задачи, при nil используется TaskUID
+func WriteCalendar(w io.Writer, name, component string, tasks []models.Task, now time.Time, uid func(models.Task) string) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		writeFolded(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//todo-rest//TODOlist//RU")
+	line("CALSCALE:GREGORIAN")
+	if name != "" {
+		line("X-WR-CALNAME:" + escapeText(name))
+	}
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, task := range tasks {
+		date, err := time.Parse(config.DateFormat, task.Date)
+		if err != nil {
+			continue
+		}
+
+		line("BEGIN:" + component)
+		if uid != nil {
+			line("UID:" + uid(task))
+		} else {
+			line("UID:" + TaskUID(task.ID))
+		}
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + task.Date)
+		if component == models.ComponentEvent {
+			line("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format(config.DateFormat))
+		} else {
+			line("DUE;VALUE=DATE:" + task.Date)
+		}
+		line("SUMMARY:" + escapeText(task.Title))
+		if task.Comment != "" {
+			line("DESCRIPTION:" + escapeText(task.Comment))
+		}
+		if task.Repeat != "" {
+			if rrule, err := RepeatToRRule(task.Repeat); err == nil {
+				line("RRULE:" + rrule)
+			}
+		}
+		if task.Version > 0 {
+			line("SEQUENCE:" + strconv.FormatInt(task.Version-1, 10))
+		}
+		line("END:" + component)
+	}
+
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// TaskUID возвращает постоянный идентификатор задачи для календарей
+func TaskUID(id string) string {
+	return "task-" + id + "@todo-rest"
+}
+
+// writeFolded записывает строку iCalendar, перенося её по 75 октетов согласно RFC 5545.
+// Продолжение начинается с пробела, который входит в эти 75 октетов
+func writeFolded(w *bufio.Writer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		// Не разрываем многобайтовые символы UTF-8
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+// escapeText экранирует значение текстового свойства iCalendar
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// parseList разбирает список целых чисел через запятую и проверяет их диапазон
+func parseList(s string, min, max int) ([]int, error) {
+	items := strings.Split(s, ",")
+	result := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(item)
+		if err != nil || n < min || n > max {
+			return nil, fmt.Errorf("invalid value: %s", item)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// joinInts объединяет числа в строку через запятую
+func joinInts(values []int) string {
+	items := make([]string, len(values))
+	for i, v := range values {
+		items[i] = strconv.Itoa(v)
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+)
+
+// ParseCalendar читает компоненты VTODO и VEVENT из файла iCalendar
+func ParseCalendar(r io.Reader) ([]models.CalendarItem, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.CalendarItem
+	var current *models.CalendarItem
+	// Глубина вложенных компонентов (например, VALARM) внутри текущего
+	nested := 0
+	for _, line := range lines {
+		name, params, value, ok := splitProperty(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && current == nil && (value == models.ComponentTodo || value == models.ComponentEvent):
+			current = &models.CalendarItem{Component: value}
+			continue
+		case name == "BEGIN" && current != nil:
+			nested++
+			continue
+		case name == "END" && current != nil && nested > 0:
+			nested--
+			continue
+		case name == "END" && current != nil && value == current.Component:
+			items = append(items, *current)
+			current = nil
+			continue
+		}
+		if current == nil || nested > 0 {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			current.UID = value
+		case "SUMMARY":
+			current.Title = unescapeText(value)
+		case "DESCRIPTION":
+			current.Comment = unescapeText(value)
+		case "DTSTART":
+			current.Start = parseICalDate(value, params)
+		case "DUE":
+			current.Due = parseICalDate(value, params)
+		case "RRULE":
+			current.RRule = value
+		case "STATUS":
+			current.Status = strings.ToUpper(value)
+		}
+	}
+
+	if current != nil {
+		return nil, errors.New("unterminated calendar component")
+	}
+	return items, nil
+}
+
+// CalendarItemTask переводит компонент календаря в задачу без проверки её параметров.
+// Датой задачи становится срок DUE, а при его отсутствии — DTSTART
+func CalendarItemTask(item models.CalendarItem) (models.Task, error) {
+	task := models.Task{
+		Title:   item.Title,
+		Comment: item.Comment,
+		Date:    item.Due,
+	}
+	if task.Date == "" {
+		task.Date = item.Start
+	}
+
+	if item.RRule != "" {
+		// Повторения отсчитываются от даты начала
+		anchor := item.Start
+		if anchor == "" {
+			anchor = task.Date
+		}
+		start, err := time.Parse(config.DateFormat, anchor)
+		if err != nil {
+			return models.Task{}, ErrDateFormat
+		}
+		repeat, err := RRuleToRepeat(item.RRule, start)
+		if err != nil {
+			return models.Task{}, fmt.Errorf("Unsupported RRULE: %w", err)
+		}
+		task.Repeat = repeat
+	}
+
+	return task, nil
+}
+
+// RRuleToRepeat переводит RRULE из RFC 5545 в правило повторения задачи.
+// start — дата начала повторений, по которой определяются опущенные в RRULE день недели и день месяца
+func RRuleToRepeat(rrule string, start time.Time) (string, error) {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(rrule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return "", fmt.Errorf("invalid RRULE part: %s", part)
+		}
+		rule[strings.ToUpper(key)] = strings.ToUpper(value)
+	}
+
+	for key := range rule {
+		switch key {
+		case "FREQ", "INTERVAL", "BYDAY", "BYMONTHDAY", "BYMONTH", "WKST":
+		default:
+			return "", fmt.Errorf("%s is not supported", key)
+		}
+	}
+
+	interval := 1
+	if v, ok := rule["INTERVAL"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return "", errors.New("invalid INTERVAL")
+		}
+		interval = n
+	}
+
+	var repeat string
+	switch rule["FREQ"] {
+	case "DAILY", "WEEKLY":
+		if _, ok := rule["BYMONTH"]; ok {
+			return "", fmt.Errorf("%s rule with BYMONTH is not supported", rule["FREQ"])
+		}
+		if _, ok := rule["BYMONTHDAY"]; ok {
+			return "", fmt.Errorf("%s rule with BYMONTHDAY is not supported", rule["FREQ"])
+		}
+		step := interval
+		if rule["FREQ"] == "WEEKLY" {
+			step = 7 * interval
+		}
+
+		byDay, hasByDay := rule["BYDAY"]
+		switch {
+		case hasByDay && interval != 1:
+			return "", fmt.Errorf("%s rule with INTERVAL and BYDAY is not supported", rule["FREQ"])
+		case hasByDay:
+			days, err := parseByDay(byDay)
+			if err != nil {
+				return "", err
+			}
+			repeat = "w " + joinInts(days)
+		case rule["FREQ"] == "WEEKLY" && interval == 1:
+			repeat = "w " + strconv.Itoa(isoWeekday(start))
+		default:
+			repeat = fmt.Sprintf("d %d", step)
+		}
+	case "MONTHLY":
+		if interval != 1 {
+			return "", errors.New("MONTHLY rule with INTERVAL is not supported")
+		}
+		if _, ok := rule["BYDAY"]; ok {
+			return "", errors.New("MONTHLY rule with BYDAY is not supported")
+		}
+		days := strconv.Itoa(start.Day())
+		if v, ok := rule["BYMONTHDAY"]; ok {
+			days = v
+		}
+		repeat = "m " + days
+		if v, ok := rule["BYMONTH"]; ok {
+			repeat += " " + v
+		}
+	case "YEARLY":
+		if interval != 1 {
+			return "", errors.New("YEARLY rule with INTERVAL is not supported")
+		}
+		if _, ok := rule["BYDAY"]; ok {
+			return "", errors.New("YEARLY rule with BYDAY is not supported")
+		}
+		months, hasMonths := rule["BYMONTH"]
+		days, hasDays := rule["BYMONTHDAY"]
+		if !hasMonths && !hasDays {
+			repeat = "y"
+			break
+		}
+		// Без BYMONTH дни BYMONTHDAY повторяются в каждом месяце (RFC 5545, 3.3.10)
+		if !hasMonths {
+			repeat = "m " + days
+			break
+		}
+		if !hasDays {
+			days = strconv.Itoa(start.Day())
+		}
+		repeat = "m " + days + " " + months
+	case "":
+		return "", errors.New("FREQ is required")
+	default:
+		return "", fmt.Errorf("FREQ=%s is not supported", rule["FREQ"])
+	}
+
+	// Проверяем, что полученное правило допустимо
+	if _, err := NextDate(start, start.Format(config.DateFormat), repeat); err != nil {
+		return "", fmt.Errorf("rule is out of supported range: %w", err)
+	}
+	return repeat, nil
+}
+
+// parseByDay переводит BYDAY в номера дней недели. Дни с порядковым номером (например, 1MO) не поддерживаются
+func parseByDay(value string) ([]int, error) {
+	var days []int
+	for _, day := range strings.Split(value, ",") {
+		n := -1
+		for i, name := range icalDays {
+			if i > 0 && name == day {
+				n = i
+			}
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("BYDAY=%s is not supported", day)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// isoWeekday возвращает номер дня недели, где понедельник — 1, а воскресенье — 7
+func isoWeekday(t time.Time) int {
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return weekday
+}
+
+// unfoldLines читает строки iCalendar, объединяя перенесённые продолжения
+func unfoldLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitProperty разбирает строку свойства вида NAME;PARAM=VALUE:value
+func splitProperty(line string) (name string, params map[string]string, value string, ok bool) {
+	head, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(head, ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, found := strings.Cut(p, "="); found {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// parseICalDate приводит значение DATE или DATE-TIME к формату даты задачи
+func parseICalDate(value string, params map[string]string) string {
+	if strings.HasSuffix(value, "Z") {
+		if t, err := time.Parse("20060102T150405Z", value); err == nil {
+			return t.Local().Format(config.DateFormat)
+		}
+	}
+	if tzid, ok := params["TZID"]; ok && len(value) == len("20060102T150405") {
+		if loc, err := time.LoadLocation(tzid); err == nil {
+			if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+				return t.Local().Format(config.DateFormat)
+			}
+		}
+	}
+	if len(value) >= 8 {
+		return value[:8]
+	}
+	return value
+}
+
+// unescapeText снимает экранирование текстового свойства iCalendar
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+
+	"todo-rest/internal/models"
+)
+
+// identityKey — ключ контекста для идентификатора вызывающей стороны
+type identityKey struct{}
+
+// userKey — ключ контекста для учётной записи вызывающей стороны
+type userKey struct{}
+
+// listKey — ключ контекста для общего списка, с задачами которого работает запрос
+type listKey struct{}
+
+// Идентификаторы вызывающей стороны при общем пароле и при отключённой аутентификации
+const (
+	IdentityUser      = "user"
+	IdentityAnonymous = "anonymous"
+)
+
+// WithIdentity сохраняет идентификатор вызывающей стороны в контексте запроса
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Identity возвращает идентификатор вызывающей стороны из контекста запроса
+func Identity(ctx context.Context) string {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	if !ok || identity == "" {
+		return IdentityAnonymous
+	}
+	return identity
+}
+
+// WithUser сохраняет учётную запись вызывающей стороны в контексте запроса
+func WithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// CurrentUser возвращает учётную запись вызывающей стороны. Без неё, например
+// в фоновых заданиях, действует администратор
+func CurrentUser(ctx context.Context) models.User {
+	user, ok := ctx.Value(userKey{}).(models.User)
+	if !ok {
+		return models.User{ID: models.AdminID, Login: models.AdminLogin, Role: models.RoleAdmin}
+	}
+	return user
+}
+
+// UserID возвращает идентификатор пользователя, задачи которого доступны вызывающей стороне
+func UserID(ctx context.Context) int64 {
+	return CurrentUser(ctx).ID
+}
+
+// WithList сохраняет в контексте запроса общий список, доступ к которому проверен
+func WithList(ctx context.Context, list int64) context.Context {
+	return context.WithValue(ctx, listKey{}, list)
+}
+
+// TaskScope возвращает область видимости задач запроса: общий список, выбранный
+// при авторизации, или личные задачи вызывающей стороны
+func TaskScope(ctx context.Context) models.Scope {
+	list, _ := ctx.Value(listKey{}).(int64)
+	return models.Scope{Owner: UserID(ctx), List: list}
+}
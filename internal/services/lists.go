@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// Ошибки проверки доступа к общему списку
+var (
+	ErrListRole      = errors.New("invalid list role")
+	ErrListName      = errors.New("list name is required")
+	ErrListForbidden = errors.New("insufficient list role")
+)
+
+// ListParam — параметр запроса с идентификатором общего списка
+const ListParam = "list"
+
+// roleRank упорядочивает роли участников списка по объёму прав
+var roleRank = map[string]int{
+	models.ListViewer: 1,
+	models.ListEditor: 2,
+	models.ListOwner:  3,
+}
+
+// ValidListRole проверяет, что role — одна из ролей участника списка
+func ValidListRole(role string) error {
+	if _, ok := roleRank[role]; !ok {
+		return ErrListRole
+	}
+	return nil
+}
+
+// ValidListName проверяет и нормализует название списка
+func ValidListName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrListName
+	}
+	return name, nil
+}
+
+// ListAccess проверяет, что вызывающая сторона участвует в списке list с ролью
+// не ниже need, и возвращает контекст, в котором задачи запроса берутся из этого списка.
+// Пустой list означает личные задачи, доступные без проверки. Для несуществующего
+// списка и списка, в котором пользователь не участвует, возвращается database.ErrListNotFound
+func ListAccess(ctx context.Context, list string, need string) (context.Context, error) {
+	if list == "" {
+		return ctx, nil
+	}
+
+	id, err := strconv.ParseInt(list, 10, 64)
+	if err != nil || id <= 0 {
+		return ctx, database.ErrListNotFound
+	}
+
+	role, err := database.GetListRole(id, UserID(ctx))
+	if err != nil {
+		return ctx, err
+	}
+	if roleRank[role] < roleRank[need] {
+		return ctx, ErrListForbidden
+	}
+
+	return WithList(ctx, id), nil
+}
+
+// Authorize проверяет аутентификацию и право вызывающей стороны на действие с задачами.
+// need — минимальная роль в общем списке из параметра list: просмотр требует роли viewer,
+// изменение — editor, управление списком — owner. Без параметра list запрос работает
+// с личными задачами пользователя
+func Authorize(cfg *config.JWTConfig, need string, next http.HandlerFunc) http.HandlerFunc {
+	return Auth(cfg, func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := ListAccess(r.Context(), r.URL.Query().Get(ListParam), need)
+		switch {
+		case errors.Is(err, database.ErrListNotFound):
+			http.Error(w, "List not found", http.StatusNotFound)
+			return
+		case errors.Is(err, ErrListForbidden):
+			http.Error(w, "Insufficient list role", http.StatusForbidden)
+			return
+		case err != nil:
+			http.Error(w, "Failed to check list access", http.StatusInternalServerError)
+			return
+		}
+		next(w, r.WithContext(ctx))
+	})
+}
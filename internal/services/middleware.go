@@ -1,34 +1,64 @@
 package services
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Auth проверяет токен из заголовка Authorization: Bearer или из куки token и сохраняет
+// в контексте запроса вызывающую сторону. Изменяющие запросы с токеном из куки
+// дополнительно требуют CSRF-токен в заголовке X-CSRF-Token. Токен доступа содержит идентификатор сессии,
+// открытой при входе, и действует до истечения его срока, пока сессия не закрыта.
+// Вместо токена доступа в заголовке можно передать ключ API: запросы на чтение требуют
+// области read, остальные — tasks:write
 func Auth(cfg *config.JWTConfig, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Смотрим наличие пароля
-		pass := cfg.Password
-		if len(pass) == 0 {
-			next(w, r)
+		enabled, err := AuthEnabled(cfg)
+		if err != nil {
+			http.Error(w, "Failed to check credentials", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			next(w, r.WithContext(AnonymousContext(r.Context())))
 			return
 		}
 
-		// Получаем куку
-		cookie, err := r.Cookie("token")
-		if err != nil {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
+		token := bearerToken(r)
+		if strings.HasPrefix(token, APIKeyPrefix) {
+			ctx, err := APIKeyContext(r.Context(), token, r.Method)
+			switch {
+			case errors.Is(err, ErrScopeDenied):
+				http.Error(w, "API key scope does not allow this request", http.StatusForbidden)
+				return
+			case err != nil:
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			next(w, r.WithContext(ctx))
 			return
 		}
+
+		fromCookie := token == ""
+		if fromCookie {
+			// Получаем куку
+			cookie, err := r.Cookie("token")
+			if err != nil {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+			token = cookie.Value
+		}
 		// Проверяем валидности токена
-		jwtToken, err := jwt.Parse(cookie.Value, func(t *jwt.Token) (interface{}, error) {
-			return []byte(cfg.Secret), nil
-		})
+		jwtToken, err := parseAccessToken(cfg, token)
 		if err != nil || !jwtToken.Valid {
 			http.Error(w, "Authentification required", http.StatusUnauthorized)
 			return
@@ -40,24 +70,95 @@ func Auth(cfg *config.JWTConfig, next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// Генерируем хэш текущего пароля
-		hash := sha256.New()
-		hash.Write([]byte(pass))
-		currentPassHash := hex.EncodeToString(hash.Sum(nil))
-
-		// Получаем хэш из пароля токена
-		passwordHash, ok := claims["passwordHash"].(string)
-		if !ok {
-			http.Error(w, "Failed to typecast to string", http.StatusUnauthorized)
+		// Сессия закрывается при выходе, смене пароля и удалении учётной записи
+		sid, _ := claims[ClaimSession].(string)
+		session, err := getSession(r.Context(), sid, time.Now())
+		if err != nil {
+			http.Error(w, "Session has expired, please re-authenticate", http.StatusUnauthorized)
 			return
 		}
+		// Браузер отправляет куку и с запросами чужих сайтов, поэтому изменяющий запрос
+		// с токеном из куки должен содержать CSRF-токен сессии в заголовке
+		if fromCookie && !safeMethod(r.Method) && !validCSRF(cfg, r, session.ID) {
+			http.Error(w, "CSRF token missing or invalid", http.StatusForbidden)
+			return
+		}
+		user, err := database.GetUser(session.User)
+		if err != nil {
+			http.Error(w, "User not found, please re-authenticate", http.StatusUnauthorized)
+			return
+		}
+
+		// Сохраняем идентификатор вызывающей стороны для журнала изменений
+		identity := IdentityUser
+		if session.Subject != "" {
+			identity = session.Subject
+		}
 
-		//Сравниваем текущий пароль с хэшем из токена
-		if passwordHash != currentPassHash {
-			http.Error(w, "Password has changed, please re-authenticate", http.StatusUnauthorized)
+		ctx := WithSession(WithUser(r.Context(), user), session.ID)
+		if expires, err := claims.GetExpirationTime(); err == nil && expires != nil {
+			ctx = WithExpiry(ctx, expires.Time)
+		}
+		next(w, r.WithContext(WithIdentity(ctx, identity)))
+	})
+}
+
+// AuthEnabled сообщает, что запросы требуют аутентификации. Она отключена, только пока нет
+// никаких учётных данных: общий пароль не задан ни в TODO_PASSWORD, ни командой passwd,
+// нет учётных записей пользователей и ключей API
+func AuthEnabled(cfg *config.JWTConfig) (bool, error) {
+	set, err := SharedPasswordSet(cfg)
+	if err != nil || set {
+		return set, err
+	}
+	return database.HasCredentials()
+}
+
+// AnonymousContext сохраняет в контексте вызывающую сторону при отключённой аутентификации.
+// Она работает с задачами администратора, но не получает его роль: административные запросы
+// и управление учётной записью ей недоступны, как ключу API с областью tasks:write
+func AnonymousContext(ctx context.Context) context.Context {
+	user := models.User{ID: models.AdminID, Login: models.AdminLogin, Role: models.RoleUser}
+	ctx = WithScopes(WithUser(ctx, user), []string{models.ScopeTasksWrite})
+	return WithIdentity(ctx, IdentityAnonymous)
+}
+
+// parseAccessToken проверяет подпись и срок действия токена доступа
+func parseAccessToken(cfg *config.JWTConfig, token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return []byte(cfg.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer или пустую строку
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// Admin пропускает к обработчику только администраторов, а ключи API — только с областью admin
+func Admin(cfg *config.JWTConfig, next http.HandlerFunc) http.HandlerFunc {
+	return Auth(cfg, func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r.Context()).Role != models.RoleAdmin || !HasScope(r.Context(), models.ScopeAdmin) {
+			http.Error(w, "Administrator role required", http.StatusForbidden)
 			return
 		}
+		next(w, r)
+	})
+}
 
+// Account пропускает к управлению учётной записью — ключами API, webhook, лентами
+// и вторым фактором — сессии пользователя, а из ключей API только ключи с областью admin:
+// ключ для работы с задачами не должен выпускать новые ключи и менять настройки входа
+func Account(cfg *config.JWTConfig, next http.HandlerFunc) http.HandlerFunc {
+	return Auth(cfg, func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r.Context(), models.ScopeAdmin) {
+			http.Error(w, "Session or admin API key required", http.StatusForbidden)
+			return
+		}
 		next(w, r)
 	})
 }
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// Notifier доставляет напоминания по одному каналу
+type Notifier interface {
+	// Channel возвращает имя канала, по которому отмечаются отправленные напоминания
+	Channel() string
+	// Accepts сообщает, что канал доставляет напоминания о задачах владельца reminder.Owner
+	Accepts(reminder models.Reminder) bool
+	Notify(ctx context.Context, reminder models.Reminder) error
+}
+
+// NewNotifiers создаёт каналы доставки напоминаний по настройкам. Адреса почты и webhook
+// из настроек принадлежат администратору сервера, поэтому по ним отправляются напоминания
+// только о его задачах, а остальные пользователи получают их в ленте уведомлений
+func NewNotifiers(cfg *config.ReminderConfig) []Notifier {
+	notifiers := []Notifier{FeedNotifier{}}
+	if cfg.SMTPAddr != "" && len(cfg.SMTPTo) > 0 {
+		notifiers = append(notifiers, &SMTPNotifier{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       cfg.SMTPTo,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			Owner:    models.AdminID,
+		})
+	}
+	if cfg.WebhookURL != "" {
+		notifiers = append(notifiers, &WebhookNotifier{
+			URL:    cfg.WebhookURL,
+			Secret: cfg.WebhookSecret,
+			Client: &http.Client{Timeout: 10 * time.Second},
+			Owner:  models.AdminID,
+		})
+	}
+	return notifiers
+}
+
+// SMTPNotifier отправляет напоминания о задачах пользователя Owner по электронной почте
+type SMTPNotifier struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+	Owner    int64
+}
+
+// Channel возвращает имя канала
+func (n *SMTPNotifier) Channel() string {
+	return models.ChannelEmail
+}
+
+// Accepts пропускает только напоминания о задачах владельца адресов
+func (n *SMTPNotifier) Accepts(reminder models.Reminder) bool {
+	return reminder.Owner == n.Owner
+}
+
+// Notify отправляет письмо с напоминанием. Аутентификация выполняется, если задано имя пользователя
+func (n *SMTPNotifier) Notify(_ context.Context, reminder models.Reminder) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host, _, err := net.SplitHostPort(n.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	return smtp.SendMail(n.Addr, auth, n.From, n.To, n.message(reminder, time.Now()))
+}
+
+// message формирует письмо с напоминанием
+func (n *SMTPNotifier) message(reminder models.Reminder, now time.Time) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + n.From + "\r\n")
+	b.WriteString("To: " + strings.Join(n.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", "Напоминание: "+reminder.Title) + "\r\n")
+	b.WriteString("Date: " + now.Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+
+	body := "Задача: " + reminder.Title + "\n" + "Срок: " + displayDate(reminder.Date) + "\n"
+	if reminder.Comment != "" {
+		body += "\n" + reminder.Comment + "\n"
+	}
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+// displayDate переводит дату задачи в формат 02.01.2006
+func displayDate(date string) string {
+	parsed, err := time.Parse(config.DateFormat, date)
+	if err != nil {
+		return date
+	}
+	return parsed.Format("02.01.2006")
+}
+
+// WebhookNotifier отправляет напоминания о задачах пользователя Owner POST запросом,
+// подписанным так же, как webhook событий
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+	Owner  int64
+}
+
+// Channel возвращает имя канала
+func (n *WebhookNotifier) Channel() string {
+	return models.ChannelWebhook
+}
+
+// Accepts пропускает только напоминания о задачах владельца адреса
+func (n *WebhookNotifier) Accepts(reminder models.Reminder) bool {
+	return reminder.Owner == n.Owner
+}
+
+// Notify отправляет напоминание. Ответ с кодом вне диапазона 2xx считается ошибкой
+func (n *WebhookNotifier) Notify(ctx context.Context, reminder models.Reminder) error {
+	body, err := json.Marshal(reminder)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-rest-webhook")
+	req.Header.Set(HeaderWebhookEvent, models.EventReminder)
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(n.Secret, body))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// FeedNotifier записывает напоминания в ленту уведомлений и сообщает о них
+// подключённым клиентам через шину событий
+type FeedNotifier struct{}
+
+// Channel возвращает имя канала
+func (FeedNotifier) Channel() string {
+	return models.ChannelFeed
+}
+
+// Accepts пропускает напоминания всех пользователей: каждый видит только свою ленту
+func (FeedNotifier) Accepts(models.Reminder) bool {
+	return true
+}
+
+// Notify добавляет напоминание в ленту уведомлений
+func (FeedNotifier) Notify(_ context.Context, reminder models.Reminder) error {
+	_, err := database.AddNotification(models.Notification{
+		TaskID: reminder.TaskID,
+		Title:  reminder.Title,
+		Date:   reminder.Date,
+		DueAt:  reminder.DueAt,
+		Owner:  reminder.Owner,
+	})
+	if err != nil {
+		return err
+	}
+
+	Events.Publish(models.Event{Type: models.EventReminder, TaskID: reminder.TaskID, Owner: reminder.Owner})
+	return nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Ошибки входа через провайдера OpenID Connect
+var (
+	ErrOIDCDisabled  = errors.New("OpenID Connect sign-in is not configured")
+	ErrOIDCState     = errors.New("Sign-in request has expired, please try again")
+	ErrOIDCToken     = errors.New("Identity provider returned an invalid ID token")
+	ErrOIDCNoAccount = errors.New("No local account is linked to this identity")
+)
+
+// Параметры входа через провайдера
+const (
+	// OIDCStateTTL — время, за которое пользователь должен вернуться от провайдера
+	OIDCStateTTL     = 10 * time.Minute
+	oidcRandomBytes  = 32
+	oidcHTTPTimeout  = 10 * time.Second
+	discoveryPath    = "/.well-known/openid-configuration"
+	pkceMethod       = "S256"
+	oidcDefaultLogin = "user"
+)
+
+// oidcClient выполняет запросы к провайдеру
+var oidcClient = &http.Client{Timeout: oidcHTTPTimeout}
+
+// oidcProvider — параметры провайдера из документа обнаружения и его ключи подписи
+type oidcProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+}
+
+// oidcProviders кэширует документы обнаружения по адресу провайдера
+var (
+	oidcProvidersMu sync.Mutex
+	oidcProviders   = map[string]*oidcProvider{}
+)
+
+// discover возвращает параметры провайдера cfg.Issuer, запрашивая документ обнаружения один раз
+func discover(cfg *config.OIDCConfig) (*oidcProvider, error) {
+	oidcProvidersMu.Lock()
+	defer oidcProvidersMu.Unlock()
+	if provider, ok := oidcProviders[cfg.Issuer]; ok {
+		return provider, nil
+	}
+
+	provider := &oidcProvider{}
+	if err := getJSON(strings.TrimSuffix(cfg.Issuer, "/")+discoveryPath, provider); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+	if provider.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", provider.Issuer, cfg.Issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("discovery document is incomplete")
+	}
+	oidcProviders[cfg.Issuer] = provider
+	return provider, nil
+}
+
+// getJSON читает JSON-ответ на GET запрос
+func getJSON(target string, v any) error {
+	resp, err := oidcClient.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", target, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// StartOIDC начинает вход через провайдера: сохраняет проверочный код PKCE и nonce
+// и возвращает state и адрес страницы входа провайдера
+func StartOIDC(cfg *config.OIDCConfig, now time.Time) (string, string, error) {
+	if !cfg.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	provider, err := discover(cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	var state, verifier, nonce string
+	for _, value := range []*string{&state, &verifier, &nonce} {
+		// Шестнадцатеричная строка допустима в URL и как проверочный код PKCE
+		if *value, err = RandomToken(oidcRandomBytes); err != nil {
+			return "", "", err
+		}
+	}
+	if err := database.AddOIDCState(HashToken(state), models.OIDCState{Verifier: verifier, Nonce: nonce}, now, now.Add(OIDCStateTTL)); err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", cfg.RedirectURL)
+	query.Set("scope", strings.Join(cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(verifier))
+	query.Set("code_challenge_method", pkceMethod)
+
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return state, provider.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// PKCEChallenge возвращает code_challenge для проверочного кода методом S256 (RFC 7636)
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// FinishOIDC завершает вход через провайдера: обменивает код авторизации на ID-токен,
+// проверяет его и возвращает связанную учётную запись. При первом входе учётная запись
+// создаётся, если это разрешено cfg.AutoCreate
+func FinishOIDC(cfg *config.OIDCConfig, state, code string, now time.Time) (models.User, error) {
+	if !cfg.Enabled() {
+		return models.User{}, ErrOIDCDisabled
+	}
+	pending, err := database.TakeOIDCState(HashToken(state), now)
+	if errors.Is(err, database.ErrOIDCStateNotFound) {
+		return models.User{}, ErrOIDCState
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	provider, err := discover(cfg)
+	if err != nil {
+		return models.User{}, err
+	}
+	rawIDToken, err := exchangeCode(cfg, provider, code, pending.Verifier)
+	if err != nil {
+		return models.User{}, err
+	}
+	claims, err := provider.verify(cfg, rawIDToken, pending.Nonce, now)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return models.User{}, ErrOIDCToken
+	}
+	user, err := database.GetIdentity(cfg.Issuer, subject)
+	if !errors.Is(err, database.ErrIdentityNotFound) {
+		return user, err
+	}
+	if !cfg.AutoCreate {
+		return models.User{}, ErrOIDCNoAccount
+	}
+
+	identity := models.Identity{Issuer: cfg.Issuer, Subject: subject}
+	return database.AddIdentityUser(models.User{Login: oidcLogin(cfg, claims), Role: models.RoleUser}, identity)
+}
+
+// exchangeCode обменивает код авторизации на ID-токен, предъявляя проверочный код PKCE
+func exchangeCode(cfg *config.OIDCConfig, provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURL)
+	form.Set("client_id", cfg.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token request rejected: %s %s", resp.Status, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+// verify проверяет подпись ID-токена ключом провайдера, издателя, получателя, срок действия и nonce
+func (p *oidcProvider) verify(cfg *config.OIDCConfig, raw, nonce string, now time.Time) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(kid)
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer(cfg.Issuer), jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(), jwt.WithTimeFunc(func() time.Time { return now }))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCToken, err)
+	}
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCToken)
+	}
+	return claims, nil
+}
+
+// key возвращает ключ подписи провайдера. Неизвестный ключ означает, что провайдер
+// сменил ключи, поэтому набор ключей запрашивается заново
+func (p *oidcProvider) key(kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// loginUnsafe — символы, недопустимые в имени пользователя
+var loginUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// oidcLogin выбирает имя новой учётной записи из утверждения cfg.LoginClaim ID-токена,
+// а если его нет — из адреса почты
+func oidcLogin(cfg *config.OIDCConfig, claims jwt.MapClaims) string {
+	login, _ := claims[cfg.LoginClaim].(string)
+	if login == "" {
+		email, _ := claims["email"].(string)
+		login, _, _ = strings.Cut(email, "@")
+	}
+	login = loginUnsafe.ReplaceAllString(login, "")
+	if !loginPattern.MatchString(login) {
+		login = oidcDefaultLogin + "-" + loginUnsafe.ReplaceAllString(fmt.Sprint(claims["sub"]), "")
+		if len(login) > 64 {
+			login = login[:64]
+		}
+	}
+	return login
+}
+
+// LinkOIDCIdentity связывает пользователя subject провайдера из настроек с учётной записью login
+func LinkOIDCIdentity(cfg *config.OIDCConfig, login, subject string) error {
+	if !cfg.Enabled() {
+		return ErrOIDCDisabled
+	}
+	user, _, err := database.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	return database.LinkIdentity(models.Identity{Issuer: cfg.Issuer, Subject: subject, User: user.ID})
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Параметры argon2id, рекомендованные RFC 9106 для ограниченной памяти
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 2
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// argon2Prefix — начало хэша argon2id в формате PHC
+const argon2Prefix = "$argon2id$"
+
+// HashPassword возвращает хэш пароля алгоритмом, выбранным в TODO_PASSWORD_ALGO
+func HashPassword(password string) (string, error) {
+	if config.LoadPasswordAlgo() == config.PasswordArgon2id {
+		return hashArgon2id(password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// hashArgon2id возвращает хэш argon2id со случайной солью в формате PHC
+func hashArgon2id(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// IsPasswordHash проверяет, что значение — хэш bcrypt или argon2id, а не пароль
+func IsPasswordHash(value string) bool {
+	return strings.HasPrefix(value, argon2Prefix) || strings.HasPrefix(value, "$2a$") ||
+		strings.HasPrefix(value, "$2b$") || strings.HasPrefix(value, "$2y$")
+}
+
+// VerifyPassword сравнивает пароль с хэшем bcrypt или argon2id за время, не зависящее
+// от совпадающей части. Значение, не являющееся хэшем, сравнивается как пароль
+func VerifyPassword(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, argon2Prefix):
+		return verifyArgon2id(hash, password)
+	case IsPasswordHash(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	default:
+		// Сравниваются дайджесты, чтобы время не выдавало и длину пароля
+		expected, actual := sha256.Sum256([]byte(hash)), sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(expected[:], actual[:]) == 1
+	}
+}
+
+// verifyArgon2id проверяет пароль по хэшу argon2id в формате PHC
+func verifyArgon2id(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, actual) == 1
+}
+
+// CheckSharedPassword проверяет общий пароль входа в учётную запись администратора.
+// Пароль, заданный командой passwd, заменяет TODO_PASSWORD, который может содержать
+// как сам пароль, так и его хэш
+func CheckSharedPassword(cfg *config.JWTConfig, password string) (bool, error) {
+	hash, err := sharedPasswordHash(cfg)
+	if err != nil || hash == "" {
+		return false, err
+	}
+	return VerifyPassword(hash, password), nil
+}
+
+// SharedPasswordSet сообщает, что общий пароль задан командой passwd или в TODO_PASSWORD
+func SharedPasswordSet(cfg *config.JWTConfig) (bool, error) {
+	hash, err := sharedPasswordHash(cfg)
+	return hash != "", err
+}
+
+// sharedPasswordHash возвращает хэш пароля администратора, заданного командой passwd,
+// а без него — TODO_PASSWORD
+func sharedPasswordHash(cfg *config.JWTConfig) (string, error) {
+	_, hash, err := database.GetUserByLogin(models.AdminLogin)
+	if err != nil && !errors.Is(err, database.ErrUserNotFound) {
+		return "", err
+	}
+	if hash == "" {
+		hash = cfg.Password
+	}
+	return hash, nil
+}
+
+// SetPassword задаёт или меняет пароль пользователя. Выданные ему токены перестают действовать
+func SetPassword(login, password string) error {
+	if len(password) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+
+	user, _, err := database.GetUserByLogin(login)
+	if err != nil {
+		return err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := database.SetPasswordHash(user.ID, hash); err != nil {
+		return err
+	}
+	return database.DeleteUserSessions(user.ID)
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Классы запросов с отдельными ограничениями частоты
+const (
+	RateRead     = "read"
+	RateWrite    = "write"
+	RateNextDate = "nextdate"
+)
+
+// RateLimit — состояние ограничения частоты запросов клиента после очередного запроса
+type RateLimit struct {
+	Allowed bool
+	// Limit — число запросов класса за окно, Remaining — сколько из них ещё доступно
+	Limit     int
+	Remaining int
+	// Reset — через сколько запросы восстановятся полностью, RetryAfter — через сколько
+	// станет доступен следующий запрос, если этот отклонён
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// bucket — корзина токенов клиента для одного класса запросов
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// limited отмечает, что корзина уже отклоняла запросы, чтобы не писать в журнал каждый из них
+	limited bool
+}
+
+// RateLimiter ограничивает частоту запросов клиентов по алгоритму token bucket:
+// корзина клиента вмещает лимит запросов класса и равномерно пополняется за окно.
+// Клиент определяется по ключу API, по пользователю сессии или по адресу
+type RateLimiter struct {
+	cfg     *config.RateLimitConfig
+	jwt     *config.JWTConfig
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewRateLimiter создаёт ограничитель частоты запросов
+func NewRateLimiter(cfg *config.RateLimitConfig, jwtCfg *config.JWTConfig) *RateLimiter {
+	return &RateLimiter{cfg: cfg, jwt: jwtCfg, buckets: make(map[string]*bucket)}
+}
+
+// limit возвращает число запросов класса за окно
+func (l *RateLimiter) limit(class string) int {
+	switch class {
+	case RateWrite:
+		return l.cfg.Write
+	case RateNextDate:
+		return l.cfg.NextDate
+	}
+	return l.cfg.Read
+}
+
+// Allow учитывает запрос класса class от клиента client в момент now
+func (l *RateLimiter) Allow(client, class string, now time.Time) RateLimit {
+	limit := l.limit(class)
+	rate := float64(limit) / l.cfg.Window.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	key := class + " " + client
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit), updated: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(limit), b.tokens+elapsed*rate)
+		b.updated = now
+	}
+
+	res := RateLimit{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		b.limited = false
+		res.Allowed = true
+	} else {
+		if !b.limited {
+			log.Printf("Rate limit of %d %s requests per %s exceeded by %s", limit, class, l.cfg.Window, client)
+		}
+		b.limited = true
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(limit) - b.tokens) / rate)
+	return res
+}
+
+// sweep не чаще раза за окно удаляет корзины, которые успели пополниться полностью:
+// новая корзина клиента будет такой же
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.cfg.Window {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.cfg.Window {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// Middleware отклоняет запросы сверх ограничений с кодом 429 и заголовком Retry-After.
+// Ответы содержат заголовки RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset и RateLimit-Policy
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if !l.cfg.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ctx := l.client(r)
+		res := l.Allow(client, RequestClass(r), time.Now())
+
+		h := w.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(int(res.Reset/time.Second)))
+		h.Set("RateLimit-Policy", strconv.Itoa(res.Limit)+";w="+strconv.Itoa(int(l.cfg.Window/time.Second)))
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(int(res.RetryAfter/time.Second)))
+			http.Error(w, "Too many requests, please retry later", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, rateClientKey{}, rateClient{limiter: l, client: client})))
+	})
+}
+
+// rateClientKey — ключ контекста для ограничителя частоты и ключа клиента запроса
+type rateClientKey struct{}
+
+type rateClient struct {
+	limiter *RateLimiter
+	client  string
+}
+
+// AllowOperation учитывает операцию класса class, выполняемую в рамках уже принятого
+// запроса, например сообщение WebSocket, в корзине того же клиента. Без ограничения
+// частоты операция всегда разрешена
+func AllowOperation(ctx context.Context, class string, now time.Time) RateLimit {
+	rc, ok := ctx.Value(rateClientKey{}).(rateClient)
+	if !ok {
+		return RateLimit{Allowed: true}
+	}
+	return rc.limiter.Allow(rc.client, class, now)
+}
+
+// RequestClass определяет класс запроса: расчёт даты, чтение или изменение данных
+func RequestClass(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/api/nextdate":
+		return RateNextDate
+	case safeMethod(r.Method):
+		return RateRead
+	}
+	return RateWrite
+}
+
+// client возвращает ключ клиента для учёта запросов: действующий ключ API, пользователя
+// действующей сессии или, для остальных запросов, адрес. Недействительные токены учитываются
+// по адресу, чтобы подбором токенов нельзя было получить новые корзины. Найденные ключ API
+// и сессия сохраняются в возвращаемом контексте, чтобы Auth не читал их повторно.
+// Статические файлы не требуют аутентификации и учитываются по адресу без чтения базы данных
+func (l *RateLimiter) client(r *http.Request) (string, context.Context) {
+	ctx := r.Context()
+	ip := "ip:" + ClientIP(r)
+	if !strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, "/caldav/") {
+		return ip, ctx
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		if _, password, ok := r.BasicAuth(); ok && strings.HasPrefix(password, APIKeyPrefix) {
+			token = password
+		}
+	}
+	if token == "" {
+		if cookie, err := r.Cookie("token"); err == nil {
+			token = cookie.Value
+		}
+	}
+
+	switch {
+	case token == "":
+	case strings.HasPrefix(token, APIKeyPrefix):
+		keyHash := HashToken(token)
+		if key, err := database.GetAPIKeyByHash(keyHash); err == nil {
+			return "key:" + key.ID, withFoundAPIKey(ctx, keyHash, key)
+		}
+	default:
+		if session, ok := l.session(token); ok {
+			return "user:" + strconv.FormatInt(session.User, 10), withFoundSession(ctx, session)
+		}
+	}
+	return ip, ctx
+}
+
+// session возвращает сессию, открытую токеном доступа
+func (l *RateLimiter) session(token string) (models.Session, bool) {
+	jwtToken, err := parseAccessToken(l.jwt, token)
+	if err != nil || !jwtToken.Valid {
+		return models.Session{}, false
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return models.Session{}, false
+	}
+	sid, _ := claims[ClaimSession].(string)
+	session, err := database.GetSession(sid, time.Now())
+	if err != nil {
+		return models.Session{}, false
+	}
+	return session, true
+}
+
+// seconds округляет число секунд вверх до целой секунды
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
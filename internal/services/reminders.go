@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// CheckReminders отправляет напоминания о задачах, время которых наступило к now.
+// Моментом задачи считается время cfg.At в день задачи, напоминание отправляется
+// с каждым опережением cfg.Leads, пока не закончился день задачи. Если наступило время
+// нескольких опережений, отправляется только последнее из них.
+// Возвращает число отправленных напоминаний
+func CheckReminders(ctx context.Context, cfg *config.ReminderConfig, notifiers []Notifier, now time.Time) (int, error) {
+	// Задачи читаются целиком до отправки, чтобы не держать открытым чтение
+	// во время записи отметок об отправке
+	var tasks []models.Task
+	err := database.EachTaskOfAllUsers(func(task models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, task := range tasks {
+		reminder, ok := dueReminder(cfg, task, now)
+		if !ok {
+			continue
+		}
+
+		for _, n := range notifiers {
+			if !n.Accepts(reminder) {
+				continue
+			}
+			claimed, err := database.ClaimReminder(reminder, n.Channel(), now)
+			if err != nil {
+				return sent, err
+			}
+			if !claimed {
+				continue
+			}
+
+			if err := n.Notify(ctx, reminder); err != nil {
+				log.Printf("Failed to send %s reminder for task %s: %v", n.Channel(), task.ID, err)
+				if err := database.ReleaseReminder(reminder, n.Channel()); err != nil {
+					return sent, err
+				}
+				continue
+			}
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// dueReminder возвращает напоминание о задаче, если время одного из опережений наступило
+func dueReminder(cfg *config.ReminderConfig, task models.Task, now time.Time) (models.Reminder, bool) {
+	date, err := time.ParseInLocation(config.DateFormat, task.Date, now.Location())
+	if err != nil {
+		return models.Reminder{}, false
+	}
+	if !now.Before(date.AddDate(0, 0, 1)) {
+		// День задачи закончился, напоминать поздно
+		return models.Reminder{}, false
+	}
+
+	dueAt := date.Add(cfg.At)
+	var lead time.Duration
+	found := false
+	for _, l := range cfg.Leads {
+		if !now.Before(dueAt.Add(-l)) && (!found || l < lead) {
+			lead = l
+			found = true
+		}
+	}
+	if !found {
+		return models.Reminder{}, false
+	}
+
+	return models.Reminder{
+		TaskID:  task.ID,
+		Title:   task.Title,
+		Comment: task.Comment,
+		Date:    task.Date,
+		DueAt:   dueAt.Format(time.RFC3339),
+		Lead:    lead.String(),
+		Owner:   task.Owner,
+	}, true
+}
+
+// RunReminders проверяет напоминания с периодом cfg.Interval до отмены контекста
+func RunReminders(ctx context.Context, cfg *config.ReminderConfig, notifiers []Notifier) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := CheckReminders(ctx, cfg, notifiers, time.Now()); err != nil {
+			log.Printf("Reminder check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
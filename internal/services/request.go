@@ -0,0 +1,20 @@
+package services
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP возвращает адрес, с которого установлено соединение, по RemoteAddr. По нему
+// ограничивается частота запросов и учитываются неудачные попытки входа. Заголовки
+// X-Forwarded-For и X-Real-IP не учитываются, так как их может подставить сам клиент:
+// за обратным прокси все запросы учитываются по адресу прокси. Заголовку X-Forwarded-Proto,
+// наоборот, доверяет проверка HTTPS для флага Secure кук: подделка там может лишь
+// добавить флаг, а не обойти ограничения
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Утверждения JWT: идентификатор сессии и имя пользователя для журнала изменений
+const (
+	ClaimSession = "sid"
+	ClaimSubject = "sub"
+)
+
+// tokenIDBytes — длина случайных идентификаторов сессий, токенов доступа и refresh-токенов
+const tokenIDBytes = 32
+
+// sessionKey — ключ контекста для сессии вызывающей стороны
+type sessionKey struct{}
+
+// WithSession сохраняет в контексте запроса сессию, по токену которой он выполняется
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionID возвращает сессию вызывающей стороны или пустую строку без аутентификации
+func SessionID(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// expiryKey и apiKeyKey — ключи контекста для срока действия токена доступа и хэша ключа API
+type expiryKey struct{}
+type apiKeyKey struct{}
+
+// credentialsCheckPeriod — период, с которым долгоживущие соединения проверяют,
+// что сессия не закрыта, а ключ API не отозван
+const credentialsCheckPeriod = 10 * time.Second
+
+// ErrCredentialsRevoked — сессия закрыта, срок действия токена доступа истёк или ключ API отозван
+var ErrCredentialsRevoked = errors.New("session has expired, please re-authenticate")
+
+// WithExpiry сохраняет в контексте запроса срок действия токена доступа
+func WithExpiry(ctx context.Context, expires time.Time) context.Context {
+	return context.WithValue(ctx, expiryKey{}, expires)
+}
+
+// withAPIKey сохраняет в контексте запроса хэш ключа API, которым он выполняется
+func withAPIKey(ctx context.Context, keyHash string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, keyHash)
+}
+
+// foundKey — ключ контекста для сессии или ключа API, уже прочитанных по токену запроса
+type foundKey struct{}
+
+type found struct {
+	session *models.Session
+	keyHash string
+	key     *models.APIKey
+}
+
+// withFoundSession сохраняет в контексте сессию, прочитанную по токену запроса
+func withFoundSession(ctx context.Context, session models.Session) context.Context {
+	return context.WithValue(ctx, foundKey{}, found{session: &session})
+}
+
+// withFoundAPIKey сохраняет в контексте ключ API, прочитанный по токену запроса
+func withFoundAPIKey(ctx context.Context, keyHash string, key models.APIKey) context.Context {
+	return context.WithValue(ctx, foundKey{}, found{keyHash: keyHash, key: &key})
+}
+
+// getSession возвращает сессию, уже прочитанную при обработке запроса, или читает её из базы данных
+func getSession(ctx context.Context, id string, now time.Time) (models.Session, error) {
+	if f, ok := ctx.Value(foundKey{}).(found); ok && f.session != nil && f.session.ID == id {
+		return *f.session, nil
+	}
+	return database.GetSession(id, now)
+}
+
+// getAPIKey возвращает ключ API, уже прочитанный при обработке запроса, или читает его из базы данных
+func getAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	if f, ok := ctx.Value(foundKey{}).(found); ok && f.key != nil && f.keyHash == keyHash {
+		return *f.key, nil
+	}
+	return database.GetAPIKeyByHash(keyHash)
+}
+
+// CheckCredentials проверяет, что учётные данные, с которыми установлено соединение,
+// всё ещё действуют: токен доступа не истёк, сессия не закрыта выходом, сменой пароля
+// или удалением учётной записи, а ключ API не отозван
+func CheckCredentials(ctx context.Context, now time.Time) error {
+	if expires, ok := ctx.Value(expiryKey{}).(time.Time); ok && !now.Before(expires) {
+		return ErrCredentialsRevoked
+	}
+	if session := SessionID(ctx); session != "" {
+		if _, err := database.GetSession(session, now); err != nil {
+			if errors.Is(err, database.ErrSessionNotFound) {
+				return ErrCredentialsRevoked
+			}
+			return err
+		}
+	}
+	if keyHash, ok := ctx.Value(apiKeyKey{}).(string); ok {
+		if _, err := database.GetAPIKeyByHash(keyHash); err != nil {
+			if errors.Is(err, database.ErrAPIKeyNotFound) {
+				return ErrCredentialsRevoked
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchCredentials возвращает канал, который закрывается, когда учётные данные запроса
+// перестают действовать: в момент истечения токена доступа или при периодической проверке
+// сессии и ключа API. Без аутентификации возвращается nil. Проверка прекращается с отменой контекста
+func WatchCredentials(ctx context.Context) <-chan struct{} {
+	expires, hasExpiry := ctx.Value(expiryKey{}).(time.Time)
+	_, hasKey := ctx.Value(apiKeyKey{}).(string)
+	if !hasExpiry && !hasKey && SessionID(ctx) == "" {
+		return nil
+	}
+
+	revoked := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(credentialsCheckPeriod)
+		defer ticker.Stop()
+		var expired <-chan time.Time
+		if hasExpiry {
+			timer := time.NewTimer(time.Until(expires))
+			defer timer.Stop()
+			expired = timer.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-expired:
+				close(revoked)
+				return
+			case <-ticker.C:
+				// Ошибку чтения базы данных не считаем отзывом: проверка повторится
+				if errors.Is(CheckCredentials(ctx, time.Now()), ErrCredentialsRevoked) {
+					close(revoked)
+					return
+				}
+			}
+		}
+	}()
+	return revoked
+}
+
+// IssueToken открывает сессию пользователя и возвращает токен доступа и refresh-токен.
+// Токен доступа содержит идентификатор сессии и действует cfg.AccessTTL,
+// subject записывается в историю изменений как автор, пустой subject означает вход по общему паролю
+func IssueToken(cfg *config.JWTConfig, user int64, subject string) (models.JWTTokenResponse, error) {
+	now := time.Now()
+	id, err := RandomToken(tokenIDBytes)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	refresh, err := RandomToken(tokenIDBytes)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+
+	session := models.Session{ID: id, User: user, Subject: subject}
+	if err := database.AddSession(session, HashToken(refresh), now.Add(cfg.RefreshTTL)); err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return signTokens(cfg, session, refresh, now)
+}
+
+// RefreshToken обменивает refresh-токен на новую пару токенов той же сессии.
+// Повторно предъявленный refresh-токен закрывает сессию
+func RefreshToken(cfg *config.JWTConfig, refresh string) (models.JWTTokenResponse, error) {
+	now := time.Now()
+	next, err := RandomToken(tokenIDBytes)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+
+	session, err := database.RotateRefreshToken(HashToken(refresh), HashToken(next), now, now.Add(cfg.RefreshTTL))
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return signTokens(cfg, session, next, now)
+}
+
+// signTokens подписывает токен доступа сессии со сроком действия и уникальным идентификатором
+func signTokens(cfg *config.JWTConfig, session models.Session, refresh string, now time.Time) (models.JWTTokenResponse, error) {
+	jti, err := RandomToken(tokenIDBytes)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+
+	claims := jwt.MapClaims{
+		ClaimSession: session.ID,
+		"jti":        jti,
+		"iat":        now.Unix(),
+		"exp":        now.Add(cfg.AccessTTL).Unix(),
+	}
+	if session.Subject != "" {
+		claims[ClaimSubject] = session.Subject
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Secret))
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+
+	return models.JWTTokenResponse{
+		Token:        signed,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(cfg.AccessTTL / time.Second),
+		CSRFToken:    CSRFToken(cfg, session.ID),
+	}, nil
+}
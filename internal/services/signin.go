@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// ErrSigninLocked возвращается, если вход временно запрещён после неудачных попыток
+var ErrSigninLocked = errors.New("Too many failed sign-in attempts, try again later")
+
+// maxDelayShift ограничивает удвоение задержки, чтобы сдвиг не переполнил time.Duration
+const maxDelayShift = 30
+
+// SigninAttempt — попытка входа, учтённая как неудачная до подтверждения пароля
+type SigninAttempt struct {
+	id    int64
+	login string
+	// RetryAt — время, до которого вход запрещён, если попытка отклонена
+	RetryAt time.Time
+}
+
+// StartSignin начинает попытку входа в учётную запись login с адреса ip. Если после неудачных
+// попыток с этой учётной записи или адреса вход запрещён, возвращается ErrSigninLocked,
+// а время, до которого ждать, записывается в RetryAt. Пока пароль не подтверждён вызовом
+// Succeed, попытка остаётся в журнале как неудачная
+func StartSignin(cfg *config.SigninConfig, login, ip string, now time.Time) (*SigninAttempt, error) {
+	attempt := &SigninAttempt{login: login}
+	if !cfg.Enabled {
+		return attempt, nil
+	}
+
+	id, retryAt, err := database.ReserveSigninAttempt(login, ip, now, now.Add(-cfg.Lockout),
+		func(account, addr models.SigninStats) time.Time {
+			retryAt := signinRetryAt(cfg, account, cfg.MaxAttempts)
+			if ipRetryAt := signinRetryAt(cfg, addr, cfg.IPMaxAttempts); ipRetryAt.After(retryAt) {
+				retryAt = ipRetryAt
+			}
+			if !retryAt.After(now) {
+				return time.Time{}
+			}
+			return retryAt
+		})
+	if err != nil {
+		return nil, err
+	}
+	if !retryAt.IsZero() {
+		attempt.RetryAt = retryAt
+		return attempt, ErrSigninLocked
+	}
+
+	attempt.id = id
+	return attempt, nil
+}
+
+// Succeed отмечает попытку успешной: она удаляется из журнала, а прежние неудачные
+// попытки входа в учётную запись перестают учитываться
+func (a *SigninAttempt) Succeed() error {
+	if a.id == 0 {
+		return nil
+	}
+	return database.ReleaseSigninAttempt(a.id, a.login)
+}
+
+// BeginSignin учитывает попытку входа в учётную запись account с адреса клиента запроса
+// с настройками из окружения. Если вход временно запрещён, возвращается ErrSigninLocked
+// и время до следующей попытки, округлённое до секунды
+func BeginSignin(r *http.Request, account string) (*SigninAttempt, time.Duration, error) {
+	now := time.Now()
+	ip := ClientIP(r)
+	attempt, err := StartSignin(config.LoadSigninConfig(), account, ip, now)
+	switch {
+	case errors.Is(err, ErrSigninLocked):
+		log.Printf("Sign-in to %q from %s rejected until %s", account, ip, attempt.RetryAt.Format(time.RFC3339))
+		return nil, seconds(attempt.RetryAt.Sub(now).Seconds()), err
+	case err != nil:
+		log.Printf("Failed to record sign-in attempt: %v", err)
+		return nil, 0, err
+	}
+	return attempt, 0, nil
+}
+
+// Complete снимает попытку входа с учёта после проверки пароля. Ошибка не мешает входу:
+// в худшем случае попытка останется в журнале неудачной
+func (a *SigninAttempt) Complete() {
+	if err := a.Succeed(); err != nil {
+		log.Printf("Failed to release sign-in attempt: %v", err)
+	}
+}
+
+// signinRetryAt возвращает время, до которого вход запрещён после stats неудачных попыток.
+// Первые cfg.FreeAttempts попыток не задерживаются, после каждой следующей задержка
+// удваивается, начиная с cfg.Delay, а после max попыток вход блокируется на cfg.Lockout
+func signinRetryAt(cfg *config.SigninConfig, stats models.SigninStats, max int) time.Time {
+	if stats.Count >= max {
+		return stats.Last.Add(cfg.Lockout)
+	}
+	if stats.Count <= cfg.FreeAttempts {
+		return time.Time{}
+	}
+	delay := cfg.Delay << min(stats.Count-cfg.FreeAttempts-1, maxDelayShift)
+	if delay > cfg.Lockout {
+		delay = cfg.Lockout
+	}
+	return stats.Last.Add(delay)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+)
+
+// ErrSnoozeFormat возвращается для неизвестного интервала откладывания
+var ErrSnoozeFormat = errors.New("Invalid snooze interval")
+
+// ErrPostponeDate возвращается, если задачу переносят на прошедшую дату
+var ErrPostponeDate = errors.New("Cannot postpone to a past date")
+
+// snoozeWeekdays — дни недели для интервалов вида next-monday
+var snoozeWeekdays = map[string]time.Weekday{
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+	"sunday":    time.Sunday,
+}
+
+// SnoozeDate вычисляет дату, на которую откладывается задача с датой date.
+// Интервал отсчитывается от даты задачи, а для просроченной задачи — от сегодняшнего дня:
+// +Nd — на N дней, +Nw — на N недель, next-week — на неделю,
+// next-monday ... next-sunday — до ближайшего следующего дня недели
+func SnoozeDate(now time.Time, date, interval string) (string, error) {
+	base, err := time.Parse(config.DateFormat, date)
+	if err != nil {
+		return "", ErrDateFormat
+	}
+	today, _ := time.Parse(config.DateFormat, now.Format(config.DateFormat))
+	if base.Before(today) {
+		base = today
+	}
+
+	interval = strings.ToLower(strings.TrimSpace(interval))
+	switch {
+	case interval == "next-week":
+		return base.AddDate(0, 0, 7).Format(config.DateFormat), nil
+	case strings.HasPrefix(interval, "next-"):
+		weekday, ok := snoozeWeekdays[strings.TrimPrefix(interval, "next-")]
+		if !ok {
+			return "", ErrSnoozeFormat
+		}
+		days := (int(weekday) - int(base.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return base.AddDate(0, 0, days).Format(config.DateFormat), nil
+	case strings.HasPrefix(interval, "+") && len(interval) > 2:
+		n, err := strconv.Atoi(interval[1 : len(interval)-1])
+		if err != nil || n < 1 || n > 400 {
+			return "", ErrSnoozeFormat
+		}
+		switch interval[len(interval)-1] {
+		case 'd':
+			return base.AddDate(0, 0, n).Format(config.DateFormat), nil
+		case 'w':
+			return base.AddDate(0, 0, 7*n).Format(config.DateFormat), nil
+		}
+	}
+
+	return "", ErrSnoozeFormat
+}
+
+// PostponeDate проверяет дату, на которую переносится задача: она должна быть
+// в формате 20060102 и не раньше сегодняшнего дня
+func PostponeDate(now time.Time, date string) (string, error) {
+	parsed, err := time.Parse(config.DateFormat, date)
+	if err != nil {
+		return "", ErrDateFormat
+	}
+	if parsed.Format(config.DateFormat) < now.Format(config.DateFormat) {
+		return "", ErrPostponeDate
+	}
+	return date, nil
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// ErrPreconditionFailed возвращается, если задача не удовлетворяет условию изменения
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition проверяет текущее состояние задачи перед её изменением,
+// например совпадение версии с заголовком If-Match
+type Precondition func(current models.Task) error
+
+// NewHistoryEntry формирует запись об изменении задачи от имени вызывающей стороны
+func NewHistoryEntry(ctx context.Context, taskID, action string, before, after *models.Task) models.TaskHistory {
+	return models.TaskHistory{
+		TaskID: taskID,
+		Action: action,
+		Actor:  Identity(ctx),
+		Before: before,
+		After:  after,
+	}
+}
+
+// SearchFilter формирует фильтр списка задач по строке поиска: дата в формате 02.01.2006
+// ищется точно, остальной текст — в заголовке и комментарии
+func SearchFilter(search string) models.TaskFilter {
+	var filter models.TaskFilter
+
+	searchParsed, err := time.Parse("02.01.2006", search)
+	if err == nil {
+		filter.SearchData = true
+		filter.Search = searchParsed.Format(config.DateFormat)
+	} else {
+		filter.Search = "%" + search + "%"
+	}
+
+	return filter
+}
+
+// CreateTask добавляет проверенную задачу вместе с записью в истории
+func CreateTask(ctx context.Context, task models.Task) (models.Task, error) {
+	task.Anchor = ""
+	err := RunInTx(ctx, func(tx *database.Tx) error {
+		id, err := tx.AddTask(task)
+		if err != nil {
+			return err
+		}
+		task.ID = strconv.Itoa(id)
+		task.Version = 1
+		return tx.AddHistory(NewHistoryEntry(ctx, task.ID, models.ActionCreate, nil, &task))
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
+
+// UpdateTask изменяет проверенную задачу. Чтение текущей версии, проверка условия
+// и изменение выполняются в одной транзакции. Явное изменение задачи задаёт новое
+// расписание, поэтому дата, с которой задача была отложена, сбрасывается
+func UpdateTask(ctx context.Context, task models.Task, check Precondition) (models.Task, error) {
+	task.Anchor = ""
+	err := RunInTx(ctx, func(tx *database.Tx) error {
+		before, err := tx.GetTask(task.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkPrecondition(check, before); err != nil {
+			return err
+		}
+
+		task.Version = before.Version
+		if task, err = tx.UpdateTask(task); err != nil {
+			return err
+		}
+		return tx.AddHistory(NewHistoryEntry(ctx, task.ID, models.ActionUpdate, &before, &task))
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
+
+// DeleteTask удаляет задачу вместе с записью в истории
+func DeleteTask(ctx context.Context, id string, check Precondition) error {
+	return RunInTx(ctx, func(tx *database.Tx) error {
+		before, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+		if err := checkPrecondition(check, before); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteTask(id, before.Version); err != nil {
+			return err
+		}
+		return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionDelete, &before, nil))
+	})
+}
+
+// CompleteTask отмечает задачу выполненной: повторяющаяся задача переносится на следующую дату,
+// остальные удаляются. Возвращает перенесённую задачу или nil, если задача удалена.
+// Чтение и изменение выполняются в одной транзакции, поэтому одновременные отметки
+// выполнения не теряются и не пропускают повторения
+func CompleteTask(ctx context.Context, id string, now time.Time, check Precondition) (*models.Task, error) {
+	var task *models.Task
+	err := RunInTx(ctx, func(tx *database.Tx) error {
+		before, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+		if err := checkPrecondition(check, before); err != nil {
+			return err
+		}
+
+		if before.Repeat == "" {
+			if err := tx.DeleteTask(id, before.Version); err != nil {
+				return err
+			}
+			return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionDone, &before, nil))
+		}
+
+		next := before
+		if next.Date, err = nextAfterDone(now, before); err != nil {
+			return ErrRepeatFormat
+		}
+		next.Anchor = ""
+		if next, err = tx.UpdateTask(next); err != nil {
+			return err
+		}
+		task = &next
+		return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionDone, &before, &next))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// nextAfterDone вычисляет следующую дату повторяющейся задачи после выполнения.
+// Для отложенной задачи дата отсчитывается от исходной даты по расписанию
+// и следует за датой, на которую задача была отложена
+func nextAfterDone(now time.Time, task models.Task) (string, error) {
+	if task.Anchor == "" {
+		return NextDate(now, task.Date, task.Repeat)
+	}
+
+	postponed, err := time.Parse(config.DateFormat, task.Date)
+	if err != nil {
+		return "", err
+	}
+	if postponed.After(now) {
+		now = postponed
+	}
+	return NextDate(now, task.Anchor, task.Repeat)
+}
+
+// PostponeTask переносит задачу на дату, которую возвращает date по текущему состоянию задачи,
+// и записывает перенос в историю. Для повторяющейся задачи сохраняется исходная дата
+// по расписанию, чтобы перенос не сдвигал следующие повторения
+func PostponeTask(ctx context.Context, id string, date func(current models.Task) (string, error), check Precondition) (models.Task, error) {
+	var task models.Task
+	err := RunInTx(ctx, func(tx *database.Tx) error {
+		before, err := tx.GetTask(id)
+		if err != nil {
+			return err
+		}
+		if err := checkPrecondition(check, before); err != nil {
+			return err
+		}
+
+		task = before
+		if task.Date, err = date(before); err != nil {
+			return err
+		}
+		if task.Repeat != "" && task.Anchor == "" {
+			task.Anchor = before.Date
+		}
+		if task, err = tx.UpdateTask(task); err != nil {
+			return err
+		}
+		return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionSnooze, &before, &task))
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
+
+// RevertTask возвращает задачу к версии из записи истории. Если задача была удалена,
+// она восстанавливается с прежним идентификатором
+func RevertTask(ctx context.Context, id string, entry models.TaskHistory, check Precondition) (models.Task, error) {
+	// Версия задачи после изменения, а для удаления — версия до него
+	target := entry.After
+	if target == nil {
+		target = entry.Before
+	}
+	if target == nil {
+		return models.Task{}, errors.New("nothing to revert to")
+	}
+	task := *target
+	task.ID = id
+
+	err := RunInTx(ctx, func(tx *database.Tx) error {
+		current, err := tx.GetTask(id)
+		if errors.Is(err, database.ErrTaskNotFound) {
+			task.Version = 1
+			if err := tx.RestoreTask(task); err != nil {
+				return err
+			}
+			return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionRevert, nil, &task))
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkPrecondition(check, current); err != nil {
+			return err
+		}
+
+		task.Version = current.Version
+		if task, err = tx.UpdateTask(task); err != nil {
+			return err
+		}
+		return tx.AddHistory(NewHistoryEntry(ctx, id, models.ActionRevert, &current, &task))
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return task, nil
+}
+
+// checkPrecondition проверяет условие изменения, если оно задано
+func checkPrecondition(check Precondition, current models.Task) error {
+	if check == nil {
+		return nil
+	}
+	return check(current)
+}
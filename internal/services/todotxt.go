@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+)
+
+// todoTxtDate — формат дат в todo.txt
+const todoTxtDate = "2006-01-02"
+
+// ErrTodoTxtCompleted возвращается для выполненных задач todo.txt, которые не загружаются
+var ErrTodoTxtCompleted = errors.New("Task is already completed")
+
+var (
+	todoTxtCompleted = regexp.MustCompile(`^x `)
+	todoTxtPriority  = regexp.MustCompile(`^\([A-Z]\) `)
+	todoTxtCreated   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+	todoTxtRec       = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+)
+
+// FormatTodoTxt переводит задачу в строку todo.txt. Приоритет хранится в начале заголовка задачи,
+// правило повторения записывается расширением rec:, а если его нельзя так выразить — расширением repeat:
+func FormatTodoTxt(task models.Task) string {
+	parts := []string{task.Title}
+
+	if date, err := time.Parse(config.DateFormat, task.Date); err == nil {
+		parts = append(parts, "due:"+date.Format(todoTxtDate))
+	}
+
+	if task.Repeat != "" {
+		if rec, ok := repeatToRec(task.Repeat, task.Date); ok {
+			parts = append(parts, "rec:"+rec)
+		} else {
+			parts = append(parts, "repeat:"+strings.ReplaceAll(task.Repeat, " ", "_"))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ParseTodoTxt разбирает строку todo.txt в задачу. Приоритет остаётся частью заголовка,
+// дата создания отбрасывается, расширения due:, rec: и repeat: переводятся в поля задачи
+func ParseTodoTxt(line string) (models.ExportTask, error) {
+	line = strings.TrimSpace(line)
+	if todoTxtCompleted.MatchString(line) {
+		return models.ExportTask{}, ErrTodoTxtCompleted
+	}
+
+	var priority string
+	if todoTxtPriority.MatchString(line) {
+		priority, line = line[:4], line[4:]
+	}
+	line = todoTxtCreated.ReplaceAllString(line, "")
+
+	var task models.ExportTask
+	var rec string
+	var words []string
+	for _, word := range strings.Fields(line) {
+		key, value, ok := strings.Cut(word, ":")
+		switch {
+		case ok && key == "due":
+			task.Date = NormalizeDate(value)
+		case ok && key == "rec":
+			rec = value
+		case ok && key == "repeat":
+			task.Repeat = strings.ReplaceAll(value, "_", " ")
+		default:
+			words = append(words, word)
+		}
+	}
+	task.Title = priority + strings.Join(words, " ")
+
+	if rec != "" && task.Repeat == "" {
+		repeat, err := recToRepeat(rec, task.Date)
+		if err != nil {
+			return models.ExportTask{}, err
+		}
+		task.Repeat = repeat
+	}
+
+	return task, nil
+}
+
+// repeatToRec переводит правило повторения в расширение rec: из todo.txt, если это возможно
+func repeatToRec(repeat, date string) (string, bool) {
+	parts := strings.Fields(repeat)
+	switch {
+	case len(parts) == 2 && parts[0] == "d":
+		return parts[1] + "d", true
+	case len(parts) == 1 && parts[0] == "y":
+		return "1y", true
+	case len(parts) == 2 && parts[0] == "m":
+		// Ежемесячное повторение в тот же день месяца, что и срок задачи
+		due, err := time.Parse(config.DateFormat, date)
+		if err == nil && parts[1] == strconv.Itoa(due.Day()) {
+			return "1m", true
+		}
+	}
+	return "", false
+}
+
+// recToRepeat переводит расширение rec: из todo.txt в правило повторения задачи
+func recToRepeat(rec, date string) (string, error) {
+	m := todoTxtRec.FindStringSubmatch(rec)
+	if m == nil {
+		return "", fmt.Errorf("Unsupported rec: %s", rec)
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n < 1 {
+		return "", fmt.Errorf("Unsupported rec: %s", rec)
+	}
+
+	switch m[2] {
+	case "d":
+		return fmt.Sprintf("d %d", n), nil
+	case "w":
+		return fmt.Sprintf("d %d", 7*n), nil
+	case "m":
+		due, err := time.Parse(config.DateFormat, date)
+		if n != 1 || err != nil {
+			return "", fmt.Errorf("Unsupported rec: %s", rec)
+		}
+		return fmt.Sprintf("m %d", due.Day()), nil
+	case "y":
+		if n != 1 {
+			return "", fmt.Errorf("Unsupported rec: %s", rec)
+		}
+		return "y", nil
+	}
+	return "", fmt.Errorf("Unsupported rec: %s", rec)
+}
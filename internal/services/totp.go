@@ -0,0 +1,294 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// Ошибки второго фактора
+var (
+	ErrTOTPEnabled     = errors.New("Two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled = errors.New("Two-factor authentication is not enrolled")
+	ErrInvalidCode     = errors.New("Invalid authentication code")
+	ErrChallenge       = errors.New("Sign-in challenge has expired, please sign in again")
+)
+
+// Параметры TOTP по RFC 6238, которые понимают все приложения-аутентификаторы
+const (
+	totpDigits      = 6
+	totpModulo      = 1000000 // 10^totpDigits
+	totpPeriod      = 30
+	totpSecretBytes = 20
+	// totpSkew — число соседних интервалов, коды которых принимаются из-за расхождения часов
+	totpSkew = 1
+	// TOTPIssuer — название приложения в приложении-аутентификаторе
+	TOTPIssuer = "todolist"
+)
+
+// Параметры кодов восстановления и незавершённого входа
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeBytes    = 5
+	ChallengeTTL         = 5 * time.Minute
+	challengeMaxAttempts = 5
+	challengeIDBytes     = tokenIDBytes
+)
+
+// totpEncoding — кодировка секрета TOTP для URI otpauth://
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPCode возвращает код TOTP секрета secret для момента t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, t.Unix()/totpPeriod), nil
+}
+
+// hotp вычисляет одноразовый код по RFC 4226 для счётчика counter
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo)
+}
+
+// TOTPEnabled сообщает, включён ли у пользователя второй фактор
+func TOTPEnabled(user int64) (bool, error) {
+	secret, err := database.GetTOTPSecret(user)
+	if errors.Is(err, database.ErrTOTPNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return secret.Enabled, nil
+}
+
+// EnrollTOTP создаёт новый секрет TOTP пользователя и возвращает URI otpauth:// для QR-кода.
+// Второй фактор включается после подтверждения кодом в ConfirmTOTP
+func EnrollTOTP(user models.User, now time.Time) (models.TOTPEnrollment, error) {
+	enabled, err := TOTPEnabled(user.ID)
+	if err != nil {
+		return models.TOTPEnrollment{}, err
+	}
+	if enabled {
+		return models.TOTPEnrollment{}, ErrTOTPEnabled
+	}
+
+	key := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(key); err != nil {
+		return models.TOTPEnrollment{}, err
+	}
+	secret := totpEncoding.EncodeToString(key)
+	if err := database.SetTOTPSecret(user.ID, secret, now); err != nil {
+		return models.TOTPEnrollment{}, err
+	}
+
+	return models.TOTPEnrollment{Secret: secret, URI: provisioningURI(user.Login, secret)}, nil
+}
+
+// provisioningURI формирует URI otpauth:// в формате Key Uri Format, который кодируется в QR-код
+func provisioningURI(login, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", TOTPIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(TOTPIssuer + ":" + login)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// ConfirmTOTP включает второй фактор, если код из приложения-аутентификатора верен,
+// и возвращает коды восстановления
+func ConfirmTOTP(user int64, code string, now time.Time) ([]string, error) {
+	secret, err := database.GetTOTPSecret(user)
+	if errors.Is(err, database.ErrTOTPNotFound) {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if secret.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+	if err := verifyTOTP(secret, code, now); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.EnableTOTP(user, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP отключает второй фактор после проверки кода или кода восстановления
+func DisableTOTP(user int64, req models.TOTPRequest, now time.Time) error {
+	if err := VerifySecondFactor(user, req, now); err != nil {
+		return err
+	}
+	return database.DeleteTOTP(user)
+}
+
+// RegenerateRecoveryCodes заменяет коды восстановления после проверки кода
+// или кода восстановления. Прежние коды перестают действовать
+func RegenerateRecoveryCodes(user int64, req models.TOTPRequest, now time.Time) ([]string, error) {
+	if err := VerifySecondFactor(user, req, now); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.ReplaceRecoveryCodes(user, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor проверяет код TOTP или, если он не передан, код восстановления
+// включённого второго фактора пользователя
+func VerifySecondFactor(user int64, req models.TOTPRequest, now time.Time) error {
+	secret, err := database.GetTOTPSecret(user)
+	if errors.Is(err, database.ErrTOTPNotFound) || (err == nil && !secret.Enabled) {
+		return ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	if req.Code == "" && req.RecoveryCode != "" {
+		err := database.UseRecoveryCode(user, HashToken(normalizeRecoveryCode(req.RecoveryCode)), now)
+		if errors.Is(err, database.ErrRecoveryCodeNotFound) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+	return verifyTOTP(secret, req.Code, now)
+}
+
+// verifyTOTP проверяет код текущего или соседнего интервала. Принятый код
+// и коды предыдущих интервалов повторно не принимаются
+func verifyTOTP(secret models.TOTPSecret, code string, now time.Time) error {
+	key, err := totpEncoding.DecodeString(secret.Secret)
+	if err != nil {
+		return err
+	}
+
+	code = strings.TrimSpace(code)
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step)), []byte(code)) != 1 {
+			continue
+		}
+		err := database.UseTOTPStep(secret.User, step)
+		if errors.Is(err, database.ErrTOTPStepUsed) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+	return ErrInvalidCode
+}
+
+// newRecoveryCodes возвращает новые коды восстановления и их хэши для хранения
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		token, err := RandomToken(recoveryCodeBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = token[:len(token)/2] + "-" + token[len(token)/2:]
+		hashes[i] = HashToken(token)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode приводит введённый код восстановления к хранимому виду
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// StartChallenge начинает второй шаг входа пользователя, пароль которого проверен.
+// Попытка входа attempt остаётся неудачной, пока не будет введён код второго фактора
+func StartChallenge(user int64, subject string, attempt *SigninAttempt, now time.Time) (models.JWTTokenResponse, error) {
+	id, err := RandomToken(challengeIDBytes)
+	if err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+
+	challenge := models.SigninChallenge{User: user, Subject: subject, Attempt: attempt.id}
+	if err := database.AddChallenge(HashToken(id), challenge, now, now.Add(ChallengeTTL)); err != nil {
+		return models.JWTTokenResponse{}, err
+	}
+	return models.JWTTokenResponse{
+		TOTPRequired: true,
+		Challenge:    id,
+		ExpiresIn:    int64(ChallengeTTL / time.Second),
+	}, nil
+}
+
+// CompleteChallenge завершает вход кодом второго фактора и возвращает пользователя
+// и имя для журнала изменений, от имени которых открывается сессия. После нескольких
+// неверных кодов незавершённый вход отменяется
+func CompleteChallenge(req models.TOTPRequest, now time.Time) (models.SigninChallenge, error) {
+	hash := HashToken(req.Challenge)
+	challenge, err := database.GetChallenge(hash, now)
+	if errors.Is(err, database.ErrChallengeNotFound) {
+		return models.SigninChallenge{}, ErrChallenge
+	}
+	if err != nil {
+		return models.SigninChallenge{}, err
+	}
+
+	if err := VerifySecondFactor(challenge.User, req, now); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := database.FailChallenge(hash, challengeMaxAttempts); err != nil {
+				return models.SigninChallenge{}, err
+			}
+		}
+		return models.SigninChallenge{}, err
+	}
+
+	if err := database.DeleteChallenge(hash); err != nil {
+		if errors.Is(err, database.ErrChallengeNotFound) {
+			return models.SigninChallenge{}, ErrChallenge
+		}
+		return models.SigninChallenge{}, err
+	}
+
+	user, err := database.GetUser(challenge.User)
+	if err != nil {
+		return models.SigninChallenge{}, err
+	}
+	attempt := &SigninAttempt{id: challenge.Attempt, login: user.Login}
+	if err := attempt.Succeed(); err != nil {
+		return models.SigninChallenge{}, err
+	}
+	return challenge, nil
+}
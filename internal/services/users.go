@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Ошибки регистрации и входа пользователей
+var (
+	ErrLoginFormat        = errors.New("Login must be 3-64 characters: letters, digits, '.', '_' or '-'")
+	ErrPasswordTooShort   = errors.New("Password must be at least 8 characters")
+	ErrRole               = errors.New("Invalid role")
+	ErrInvalidCredentials = errors.New("Wrong login or password")
+)
+
+// minPasswordLength — наименьшая длина пароля пользователя
+const minPasswordLength = 8
+
+// loginPattern — допустимое имя пользователя
+var loginPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,64}$`)
+
+// dummyHash сравнивается с паролем, если пользователя нет, чтобы время ответа
+// не выдавало существование учётной записи
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// RegisterUser проверяет имя пользователя и пароль и создаёт учётную запись с ролью role
+func RegisterUser(login, password, role string) (models.User, error) {
+	if !loginPattern.MatchString(login) {
+		return models.User{}, ErrLoginFormat
+	}
+	if len(password) < minPasswordLength {
+		return models.User{}, ErrPasswordTooShort
+	}
+	if role == "" {
+		role = models.RoleUser
+	}
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return models.User{}, ErrRole
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{Login: login, Role: role}
+	if user.ID, err = database.AddUser(user, hash); err != nil {
+		return models.User{}, err
+	}
+	return database.GetUser(user.ID)
+}
+
+// Authenticate проверяет имя пользователя и пароль. Администратор, у которого
+// нет собственного пароля, входит только по общему паролю TODO_PASSWORD
+func Authenticate(login, password string) (models.User, error) {
+	user, hash, err := database.GetUserByLogin(login)
+	if errors.Is(err, database.ErrUserNotFound) || (err == nil && hash == "") {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if !VerifyPassword(hash, password) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
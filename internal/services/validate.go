@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+)
+
+// Ошибки проверки параметров новой задачи
+var (
+	ErrTitleRequired = errors.New("Task title not specified")
+	ErrDateFormat    = errors.New("Date is in the wrong format")
+	ErrRepeatFormat  = errors.New("Invalid format of repeat rule")
+	ErrOverduePolicy = errors.New("Invalid overdue policy")
+)
+
+// OverduePolicy возвращает правило обработки прошедших дат, запрошенное клиентом,
+// или правило сервера, если клиент его не указал
+func OverduePolicy(requested string) (string, error) {
+	switch requested {
+	case "":
+		return config.LoadOverduePolicy(), nil
+	case config.OverdueClamp, config.OverdueKeep:
+		return requested, nil
+	default:
+		return "", ErrOverduePolicy
+	}
+}
+
+// ValidateTask проверяет параметры новой задачи и приводит дату к допустимому значению:
+// пустая дата заменяется на сегодняшнюю, а прошедшая — в зависимости от правила policy
+// переносится на сегодня или сохраняется
+func ValidateTask(task *models.Task, now time.Time, policy string) error {
+	// Проверяем наличие заголовка
+	if task.Title == "" {
+		return ErrTitleRequired
+	}
+
+	// Проверяем формат даты и устанавливаем текущую дату
+	if task.Date == "" {
+		task.Date = now.Format(config.DateFormat)
+	} else {
+		date, err := time.Parse(config.DateFormat, task.Date)
+		if err != nil {
+			return ErrDateFormat
+		}
+		if date.Before(now) && policy != config.OverdueKeep {
+			task.Date = now.Format(config.DateFormat)
+		}
+	}
+
+	// Проверяем правило повторения
+	if task.Repeat != "" {
+		if _, err := NextDate(now, task.Date, task.Repeat); err != nil {
+			return ErrRepeatFormat
+		}
+	}
+
+	return nil
+}
+
+// IsOverdue проверяет, что дата задачи прошла
+func IsOverdue(task models.Task, now time.Time) bool {
+	return task.Date < now.Format(config.DateFormat)
+}
+
+// MarkOverdue отмечает просроченные задачи в списке
+func MarkOverdue(tasks []models.Task, now time.Time) {
+	for i := range tasks {
+		tasks[i].Overdue = IsOverdue(tasks[i], now)
+	}
+}
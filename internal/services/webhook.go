@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// WebhookEvents — события, на которые можно подписать webhook
+var WebhookEvents = []string{models.EventCreated, models.EventUpdated, models.EventDeleted, models.EventDone}
+
+// Заголовки запроса webhook
+const (
+	HeaderWebhookEvent     = "X-Todo-Event"
+	HeaderWebhookDelivery  = "X-Todo-Delivery"
+	HeaderWebhookSignature = "X-Todo-Signature"
+)
+
+// webhookBatchSize — количество доставок, отправляемых за один проход очереди
+const webhookBatchSize = 20
+
+// maxRetryDelay ограничивает задержку между повторными попытками доставки
+const maxRetryDelay = 6 * time.Hour
+
+// ErrWebhookAddress — адрес webhook относится к локальной или внутренней сети
+var ErrWebhookAddress = errors.New("webhook address is in a private network")
+
+// sharedAddressSpace — адреса операторов связи (RFC 6598), недоступные из интернета
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// SignWebhook возвращает подпись тела запроса HMAC-SHA256 с секретом webhook
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueWebhooks ставит события по записям истории в очередь доставки webhook
+func enqueueWebhooks(tx *database.Tx, history []models.TaskHistory, now time.Time) error {
+	for _, entry := range history {
+		event := historyEvent(entry)
+		payload, err := json.Marshal(models.WebhookPayload{
+			Event:  event.Type,
+			TaskID: event.TaskID,
+			Task:   event.Task,
+			Actor:  event.Actor,
+			Time:   now.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.AddDeliveries(event.Type, string(payload), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverWebhooks отправляет доставки из очереди, время которых наступило к now.
+// Неудачная доставка повторяется с экспоненциально растущей задержкой, а после
+// исчерпания попыток отмечается как неудавшаяся. Возвращает число успешных доставок
+func DeliverWebhooks(ctx context.Context, cfg *config.WebhookConfig, now time.Time) (int, error) {
+	deliveries, err := database.GetDueDeliveries(now, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	client := webhookClient(cfg)
+	defer client.CloseIdleConnections()
+	delivered := 0
+	for _, d := range deliveries {
+		d.Attempts++
+		d.LastStatus, err = sendWebhook(ctx, client, d)
+		switch {
+		case err == nil:
+			d.Status = models.DeliveryDelivered
+			d.LastError = ""
+			d.NextAttemptAt = ""
+			d.DeliveredAt = now.UTC().Format(time.RFC3339)
+			delivered++
+		case d.Attempts >= cfg.MaxAttempts:
+			d.Status = models.DeliveryFailed
+			d.LastError = err.Error()
+			d.NextAttemptAt = ""
+		default:
+			d.LastError = err.Error()
+			d.NextAttemptAt = now.Add(retryDelay(cfg.RetryBase, d.Attempts)).UTC().Format(time.RFC3339)
+		}
+
+		if err := database.UpdateDelivery(d); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// CheckWebhookHost отклоняет при регистрации webhook имя localhost и адреса внутренних сетей.
+// Имена не разрешаются: адрес, на который указывает имя, проверяется при каждой доставке
+func CheckWebhookHost(cfg *config.WebhookConfig, host string) error {
+	if cfg.AllowPrivate {
+		return nil
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookAddress
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && privateIP(ip) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// webhookClient возвращает HTTP-клиент для доставки webhook. Адрес проверяется при установке
+// соединения, после разрешения имени, поэтому его нельзя подменить записью DNS,
+// изменённой после регистрации, или перенаправлением. Прокси не используется
+func webhookClient(cfg *config.WebhookConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if privateIP(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddress, addr.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: cfg.Timeout, Transport: transport}
+}
+
+// privateIP сообщает, что адрес относится к локальной, внутренней или служебной сети,
+// в том числе к адресам метаданных облачных платформ (169.254.169.254)
+func privateIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// retryDelay возвращает задержку перед попыткой, следующей за attempts неудачными
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// sendWebhook отправляет подписанный запрос и возвращает код ответа.
+// Ответ с кодом вне диапазона 2xx считается ошибкой
+func sendWebhook(ctx context.Context, client *http.Client, d models.WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-rest-webhook")
+	req.Header.Set(HeaderWebhookEvent, d.Event)
+	req.Header.Set(HeaderWebhookDelivery, d.ID)
+	req.Header.Set(HeaderWebhookSignature, SignWebhook(d.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// RunWebhooks отправляет доставки из очереди до отмены контекста. Очередь проверяется
+// с периодом interval и сразу после изменения задач
+func RunWebhooks(ctx context.Context, cfg *config.WebhookConfig, interval time.Duration) {
+	_, events, cancel := Events.Subscribe(AllUsers, 0, false)
+	defer func() { cancel() }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := DeliverWebhooks(ctx, cfg, time.Now()); err != nil {
+			log.Printf("Webhook delivery failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-events:
+			if !ok {
+				// Подписка отключена из-за отставания, подписываемся заново
+				_, events, cancel = Events.Subscribe(AllUsers, 0, false)
+			}
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Package caldav реализует сервер CalDAV, который представляет задачи
+// как календарь VTODO для приложений-планировщиков на телефонах и компьютерах
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Адреса ресурсов сервера: принципал совпадает с корнем, в нём одна коллекция задач
+const (
+	rootPath       = "/caldav/"
+	collectionPath = "/caldav/tasks/"
+)
+
+// syncTokenPrefix — префикс токенов синхронизации, за ним следует номер записи истории
+const syncTokenPrefix = "http://todo-rest/ns/sync/"
+
+// calendarName — отображаемое имя коллекции задач
+const calendarName = "TODOlist"
+
+// methods — методы WebDAV, которые обрабатывает сервер помимо стандартных методов HTTP
+var methods = []string{"PROPFIND", "PROPPATCH", "REPORT"}
+
+// taskName — имя ресурса задачи, созданной не через CalDAV
+var taskName = regexp.MustCompile(`^([0-9]+)\.ics$`)
+
+// Register подключает сервер CalDAV к маршрутизатору по адресу /caldav/
+// и перенаправляет на него запросы к /.well-known/caldav
+func Register(r chi.Router, cfg *config.JWTConfig) {
+	for _, method := range methods {
+		chi.RegisterMethod(method)
+	}
+
+	r.Handle("/.well-known/caldav", http.RedirectHandler(rootPath, http.StatusMovedPermanently))
+	r.Mount("/caldav", basicAuth(cfg, http.HandlerFunc(serveHTTP)))
+}
+
+// basicAuth проверяет пароль из заголовка Authorization. Приложения CalDAV
+// не умеют получать токен через /api/signin, поэтому используется Basic-аутентификация:
+// общий пароль TODO_PASSWORD даёт доступ к задачам администратора,
+// а имя и пароль пользователя — к его задачам. Пароль не заменяет второй фактор:
+// пользователи, у которых он включён, передают вместо пароля ключ API. Неудачные попытки
+// учитываются вместе с попытками входа через /api/signin и так же задерживают и блокируют вход
+func basicAuth(cfg *config.JWTConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enabled, err := services.AuthEnabled(cfg)
+		if err != nil {
+			http.Error(w, "Failed to check credentials", http.StatusInternalServerError)
+			return
+		}
+		if !enabled {
+			next.ServeHTTP(w, r.WithContext(services.AnonymousContext(r.Context())))
+			return
+		}
+
+		user, pass, ok := r.BasicAuth()
+		if ok && strings.HasPrefix(pass, services.APIKeyPrefix) {
+			ctx, err := services.APIKeyContext(r.Context(), pass, r.Method)
+			switch {
+			case errors.Is(err, services.ErrScopeDenied):
+				http.Error(w, "API key scope does not allow this request", http.StatusForbidden)
+				return
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+			ok = false
+		}
+		if !ok {
+			unauthorized(w)
+			return
+		}
+
+		// Пароли проверяются с тем же учётом неудачных попыток, что и вход через /api/signin
+		login := user
+		if login == "" {
+			login = models.AdminLogin
+		}
+		attempt, ok := startSignin(w, r, login)
+		if !ok {
+			return
+		}
+
+		// Общий пароль — пароль администратора, под другими именами он не принимается
+		shared := false
+		if user == "" || user == models.AdminLogin {
+			var err error
+			shared, err = services.CheckSharedPassword(cfg, pass)
+			if err == nil && shared {
+				shared, err = withoutTOTP(models.AdminID)
+			}
+			if err != nil {
+				http.Error(w, "Failed to check password", http.StatusInternalServerError)
+				return
+			}
+		}
+		if !shared && user != "" {
+			account, err := services.Authenticate(user, pass)
+			if err == nil {
+				if ok, err = withoutTOTP(account.ID); err != nil {
+					http.Error(w, "Failed to check password", http.StatusInternalServerError)
+					return
+				}
+				if ok {
+					attempt.Complete()
+					ctx := services.WithIdentity(services.WithUser(r.Context(), account), account.Login)
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				}
+			}
+		}
+		if !shared {
+			unauthorized(w)
+			return
+		}
+
+		// В истории изменений автором записывается владелец общего пароля, а не имя из заголовка
+		attempt.Complete()
+		next.ServeHTTP(w, r.WithContext(services.WithIdentity(r.Context(), services.IdentityUser)))
+	})
+}
+
+// unauthorized запрашивает у клиента имя и пароль
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+calendarName+`", charset="UTF-8"`)
+	http.Error(w, "Authentication required", http.StatusUnauthorized)
+}
+
+// startSignin учитывает попытку входа в учётную запись account с адреса клиента.
+// Если вход временно запрещён, отвечает 429 с заголовком Retry-After и возвращает false
+func startSignin(w http.ResponseWriter, r *http.Request, account string) (*services.SigninAttempt, bool) {
+	attempt, retryAfter, err := services.BeginSignin(r, account)
+	switch {
+	case errors.Is(err, services.ErrSigninLocked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return nil, false
+	case err != nil:
+		http.Error(w, "Failed to check password", http.StatusInternalServerError)
+		return nil, false
+	}
+	return attempt, true
+}
+
+// withoutTOTP сообщает, что у пользователя не включён второй фактор и ему достаточно пароля
+func withoutTOTP(user int64) (bool, error) {
+	enabled, err := services.TOTPEnabled(user)
+	return !enabled, err
+}
+
+// serveHTTP распределяет запросы по методам
+func serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		propfind(w, r)
+	case "PROPPATCH":
+		// Свойства коллекции задаются сервером и не изменяются клиентами
+		http.Error(w, "Properties are read-only", http.StatusForbidden)
+	case "REPORT":
+		report(w, r)
+	case http.MethodGet, http.MethodHead:
+		getObject(w, r)
+	case http.MethodPut:
+		putObject(w, r)
+	case http.MethodDelete:
+		deleteObject(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// objectName возвращает имя ресурса задачи из адреса запроса.
+// Второе значение ложно, если адрес не указывает на ресурс внутри коллекции
+func objectName(path string) (string, bool) {
+	if !strings.HasPrefix(path, collectionPath) {
+		return "", false
+	}
+	name := strings.TrimPrefix(path, collectionPath)
+	if name == "" || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// object — задача вместе с именем ресурса и UID компонента
+type object struct {
+	task models.Task
+	name string
+	uid  string
+}
+
+// href возвращает адрес ресурса задачи
+func (o object) href() string {
+	return collectionPath + o.name
+}
+
+// newObject формирует ресурс задачи с учётом имени и UID, сохранённых при загрузке через CalDAV
+func newObject(task models.Task, objects map[string]models.CalDAVObject) object {
+	if obj, ok := objects[task.ID]; ok {
+		return object{task: task, name: obj.Name, uid: obj.UID}
+	}
+	return object{task: task, name: task.ID + ".ics", uid: services.TaskUID(task.ID)}
+}
+
+// findObject находит задачу по имени ресурса: сначала среди имён, сохранённых
+// при загрузке через CalDAV, затем среди имён вида <id>.ics.
+// Ошибка ErrTaskNotFound означает, что задачи нет, она удалена или принадлежит другому пользователю
+func findObject(owner int64, name string) (object, error) {
+	obj, err := database.GetCalDAVObject(name)
+	switch {
+	case err == nil:
+		task, err := database.GetTask(models.Scope{Owner: owner}, obj.TaskID)
+		if err != nil {
+			return object{name: name, uid: obj.UID}, err
+		}
+		return object{task: task, name: name, uid: obj.UID}, nil
+	case !errors.Is(err, database.ErrObjectNotFound):
+		return object{}, err
+	}
+
+	m := taskName.FindStringSubmatch(name)
+	if m == nil {
+		return object{name: name}, database.ErrTaskNotFound
+	}
+	task, err := database.GetTask(models.Scope{Owner: owner}, m[1])
+	if err != nil {
+		return object{name: name}, err
+	}
+	return object{task: task, name: name, uid: services.TaskUID(task.ID)}, nil
+}
+
+// calendarData возвращает задачу в формате iCalendar
+func calendarData(o object) (string, error) {
+	var buf bytes.Buffer
+	uid := func(models.Task) string { return o.uid }
+	if err := services.WriteCalendar(&buf, "", models.ComponentTodo, []models.Task{o.task}, time.Now(), uid); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// getObject обрабатывает GET и HEAD запросы к ресурсу задачи
+func getObject(w http.ResponseWriter, r *http.Request) {
+	name, ok := objectName(r.URL.Path)
+	if !ok {
+		http.Error(w, "Not a calendar object", http.StatusMethodNotAllowed)
+		return
+	}
+
+	o, err := findObject(services.UserID(r.Context()), name)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	data, err := calendarData(o)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("ETag", services.ETag(o.task.Version))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// putObject обрабатывает PUT запрос: создаёт задачу или изменяет существующую.
+// Компонент со статусом COMPLETED отмечает задачу выполненной
+func putObject(w http.ResponseWriter, r *http.Request) {
+	name, ok := objectName(r.URL.Path)
+	if !ok {
+		http.Error(w, "Not a calendar object", http.StatusMethodNotAllowed)
+		return
+	}
+
+	items, err := services.ParseCalendar(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid iCalendar data", http.StatusBadRequest)
+		return
+	}
+	if len(items) != 1 || items[0].Component != models.ComponentTodo {
+		http.Error(w, "Exactly one VTODO component expected", http.StatusForbidden)
+		return
+	}
+	item := items[0]
+
+	current, err := findObject(services.UserID(r.Context()), name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, database.ErrTaskNotFound) {
+		respondError(w, err)
+		return
+	}
+
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		http.Error(w, "Resource already exists", http.StatusPreconditionFailed)
+		return
+	}
+	if !exists && r.Header.Get("If-Match") != "" {
+		http.Error(w, "Resource does not exist", http.StatusPreconditionFailed)
+		return
+	}
+
+	now := time.Now()
+	completed := item.Status == "COMPLETED" || item.Status == "CANCELLED"
+
+	if exists && completed {
+		// Повторяющаяся задача переносится на следующую дату, поэтому клиент
+		// должен перечитать ресурс, и ETag в ответе не передаётся
+		if _, err := services.CompleteTask(r.Context(), current.task.ID, now, services.IfMatch(r.Header.Get("If-Match"))); err != nil {
+			respondError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if completed {
+		// Выполненную задачу, которой ещё нет, добавлять не нужно
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	task, err := services.CalendarItemTask(item)
+	if err == nil {
+		err = services.ValidateTask(&task, now, config.LoadOverduePolicy())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if exists {
+		task.ID = current.task.ID
+		if task, err = services.UpdateTask(r.Context(), task, services.IfMatch(r.Header.Get("If-Match"))); err != nil {
+			respondError(w, err)
+			return
+		}
+		w.Header().Set("ETag", services.ETag(task.Version))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Имя ресурса может быть занято задачей другого пользователя
+	if obj, err := database.GetCalDAVObject(name); err == nil {
+		used, err := database.TaskIDUsed(obj.TaskID)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		if used {
+			http.Error(w, "Resource name is taken", http.StatusConflict)
+			return
+		}
+	}
+
+	if task, err = services.CreateTask(r.Context(), task); err != nil {
+		respondError(w, err)
+		return
+	}
+	uid := item.UID
+	if uid == "" {
+		uid = services.TaskUID(task.ID)
+	}
+	if err := database.SaveCalDAVObject(models.CalDAVObject{Name: name, TaskID: task.ID, UID: uid}); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", services.ETag(task.Version))
+	w.Header().Set("Location", collectionPath+name)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// deleteObject обрабатывает DELETE запрос к ресурсу задачи
+func deleteObject(w http.ResponseWriter, r *http.Request) {
+	name, ok := objectName(r.URL.Path)
+	if !ok {
+		http.Error(w, "Collection cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	o, err := findObject(services.UserID(r.Context()), name)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if err := services.DeleteTask(r.Context(), o.task.ID, services.IfMatch(r.Header.Get("If-Match"))); err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondError отправляет ответ на ошибку чтения или изменения задачи
+func respondError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrPreconditionFailed), errors.Is(err, database.ErrVersionConflict):
+		http.Error(w, "Task has been modified", http.StatusPreconditionFailed)
+	case errors.Is(err, services.ErrRepeatFormat):
+		http.Error(w, "Invalid format of repeat rule", http.StatusForbidden)
+	default:
+		log.Printf("CalDAV request failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// syncToken возвращает текущий токен синхронизации коллекции пользователя
+func syncToken(owner int64) (string, error) {
+	token, err := database.SyncToken(owner)
+	if err != nil {
+		return "", err
+	}
+	return syncTokenPrefix + strconv.FormatInt(token, 10), nil
+}
+
+// parseSyncToken возвращает номер записи истории из токена синхронизации
+func parseSyncToken(token string) (int64, bool) {
+	if !strings.HasPrefix(token, syncTokenPrefix) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimPrefix(token, syncTokenPrefix), 10, 64)
+	return n, err == nil && n >= 0
+}
+
+// hasName проверяет, есть ли среди запрошенных свойств указанное
+func hasName(names []xml.Name, name xml.Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
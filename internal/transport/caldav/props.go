@@ -0,0 +1,327 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strings"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// propfind обрабатывает PROPFIND запрос к корню, коллекции задач или отдельной задаче.
+// Глубина 1 добавляет в ответ вложенные ресурсы, большая глубина не поддерживается
+func propfind(w http.ResponseWriter, r *http.Request) {
+	var req propfindRequest
+	ok, err := decodeBody(r, &req)
+	if err != nil {
+		http.Error(w, "Invalid XML body", http.StatusBadRequest)
+		return
+	}
+	var names = req.Prop.names()
+	if !ok || req.AllProp != nil {
+		names = nil
+	}
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+	if depth == "infinity" {
+		http.Error(w, "Depth infinity is not supported", http.StatusForbidden)
+		return
+	}
+
+	path := r.URL.Path
+	if path == "/caldav" {
+		path = rootPath
+	}
+	if path == strings.TrimSuffix(collectionPath, "/") {
+		path = collectionPath
+	}
+
+	owner := services.UserID(r.Context())
+	var resources []resource
+	switch path {
+	case rootPath:
+		resources = append(resources, resource{href: rootPath, props: rootProps()})
+		if depth == "1" {
+			props, err := collectionProps(owner)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+			resources = append(resources, resource{href: collectionPath, props: props})
+		}
+	case collectionPath:
+		props, err := collectionProps(owner)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		resources = append(resources, resource{href: collectionPath, props: props})
+		if depth == "1" {
+			objects, err := allObjects(owner)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+			for _, o := range objects {
+				res, err := objectResource(o, names)
+				if err != nil {
+					respondError(w, err)
+					return
+				}
+				resources = append(resources, res)
+			}
+		}
+	default:
+		name, ok := objectName(path)
+		if !ok {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		o, err := findObject(owner, name)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		res, err := objectResource(o, names)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		resources = append(resources, res)
+	}
+
+	writeMultistatus(w, resources, names, "")
+}
+
+// rootProps возвращает свойства корня, который одновременно является принципалом пользователя
+func rootProps() props {
+	return props{
+		propResourceType:      "<d:collection/><d:principal/>",
+		propDisplayName:       escape(calendarName),
+		propPrincipal:         hrefElement(rootPath),
+		propPrincipalURL:      hrefElement(rootPath),
+		propCalendarHome:      hrefElement(rootPath),
+		propCalendarUserAddrs: "",
+	}
+}
+
+// collectionProps возвращает свойства коллекции задач пользователя
+func collectionProps(owner int64) (props, error) {
+	token, err := syncToken(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return props{
+		propResourceType:     "<d:collection/><c:calendar/>",
+		propDisplayName:      escape(calendarName),
+		propPrincipal:        hrefElement(rootPath),
+		propSupportedCompSet: `<c:comp name="` + models.ComponentTodo + `"/>`,
+		propSupportedReports: "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><d:sync-collection/></d:report></d:supported-report>",
+		propSyncToken: escape(token),
+		propCTag:      escape(token),
+	}, nil
+}
+
+// objectResource возвращает ресурс задачи. Содержимое календаря формируется,
+// только если оно запрошено
+func objectResource(o object, names []xml.Name) (resource, error) {
+	p := props{
+		propResourceType: "",
+		propETag:         escape(services.ETag(o.task.Version)),
+		propContentType:  "text/calendar; charset=utf-8; component=vtodo",
+	}
+	if hasName(names, propCalendarData) {
+		data, err := calendarData(o)
+		if err != nil {
+			return resource{}, err
+		}
+		p[propCalendarData] = escape(data)
+	}
+	return resource{href: o.href(), props: p}, nil
+}
+
+// allObjects возвращает ресурсы всех задач пользователя
+func allObjects(owner int64) ([]object, error) {
+	mapping, err := database.GetCalDAVObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []object
+	err = database.EachTask(models.Scope{Owner: owner}, func(task models.Task) error {
+		objects = append(objects, newObject(task, mapping))
+		return nil
+	})
+	return objects, err
+}
+
+// report обрабатывает REPORT запросы calendar-query, calendar-multiget и sync-collection
+func report(w http.ResponseWriter, r *http.Request) {
+	var req reportRequest
+	if ok, err := decodeBody(r, &req); err != nil || !ok {
+		http.Error(w, "Invalid XML body", http.StatusBadRequest)
+		return
+	}
+	names := req.Prop.names()
+	owner := services.UserID(r.Context())
+
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		calendarQuery(w, owner, req, names)
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		calendarMultiget(w, owner, req, names)
+	case xml.Name{Space: nsDAV, Local: "sync-collection"}:
+		syncCollection(w, owner, req, names)
+	default:
+		http.Error(w, "Unsupported report", http.StatusForbidden)
+	}
+}
+
+// calendarQuery возвращает все задачи, если фильтр допускает компоненты VTODO.
+// Ограничения по времени и свойствам не применяются
+func calendarQuery(w http.ResponseWriter, owner int64, req reportRequest, names []xml.Name) {
+	var resources []resource
+	if req.Filter == nil || acceptsTodo(*req.Filter) {
+		objects, err := allObjects(owner)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		for _, o := range objects {
+			res, err := objectResource(o, names)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+			resources = append(resources, res)
+		}
+	}
+
+	writeMultistatus(w, resources, names, "")
+}
+
+// acceptsTodo проверяет, что фильтр VCALENDAR не исключает компоненты VTODO
+func acceptsTodo(filter compFilter) bool {
+	if !strings.EqualFold(filter.Name, "VCALENDAR") {
+		return false
+	}
+	for _, f := range filter.Filters {
+		if !strings.EqualFold(f.Name, models.ComponentTodo) {
+			return false
+		}
+	}
+	return true
+}
+
+// calendarMultiget возвращает задачи по списку адресов
+func calendarMultiget(w http.ResponseWriter, owner int64, req reportRequest, names []xml.Name) {
+	var resources []resource
+	for _, href := range req.Hrefs {
+		href = strings.TrimSpace(href)
+		name, ok := objectName(href)
+		if !ok {
+			resources = append(resources, resource{href: href, status: http.StatusNotFound})
+			continue
+		}
+
+		o, err := findObject(owner, name)
+		if errors.Is(err, database.ErrTaskNotFound) {
+			resources = append(resources, resource{href: href, status: http.StatusNotFound})
+			continue
+		}
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+
+		res, err := objectResource(o, names)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		resources = append(resources, res)
+	}
+
+	writeMultistatus(w, resources, names, "")
+}
+
+// syncCollection возвращает задачи, изменённые после указанного токена, и удалённые
+// задачи с кодом 404. Без токена возвращаются все задачи
+func syncCollection(w http.ResponseWriter, owner int64, req reportRequest, names []xml.Name) {
+	token, err := syncToken(owner)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	if req.SyncToken == "" {
+		objects, err := allObjects(owner)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		var resources []resource
+		for _, o := range objects {
+			res, err := objectResource(o, names)
+			if err != nil {
+				respondError(w, err)
+				return
+			}
+			resources = append(resources, res)
+		}
+		writeMultistatus(w, resources, names, token)
+		return
+	}
+
+	since, ok := parseSyncToken(req.SyncToken)
+	current, _ := parseSyncToken(token)
+	if !ok || since > current {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+			`<d:error xmlns:d="DAV:"><d:valid-sync-token/></d:error>`))
+		return
+	}
+
+	ids, err := database.ChangedTaskIDs(owner, since)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	mapping, err := database.GetCalDAVObjects()
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	var resources []resource
+	for _, id := range ids {
+		task, err := database.GetTask(models.Scope{Owner: owner}, id)
+		if errors.Is(err, database.ErrTaskNotFound) {
+			o := newObject(models.Task{ID: id}, mapping)
+			resources = append(resources, resource{href: o.href(), status: http.StatusNotFound})
+			continue
+		}
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+
+		res, err := objectResource(newObject(task, mapping), names)
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+		resources = append(resources, res)
+	}
+
+	writeMultistatus(w, resources, names, token)
+}
@@ -0,0 +1,215 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Пространства имён XML, используемые в ответах WebDAV и CalDAV
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// prefixes — префиксы пространств имён в ответах сервера
+var prefixes = map[string]string{
+	nsDAV:    "d",
+	nsCalDAV: "c",
+	nsCS:     "cs",
+}
+
+// Свойства ресурсов, которые поддерживает сервер
+var (
+	propResourceType      = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName       = xml.Name{Space: nsDAV, Local: "displayname"}
+	propPrincipal         = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL      = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propETag              = xml.Name{Space: nsDAV, Local: "getetag"}
+	propContentType       = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propSyncToken         = xml.Name{Space: nsDAV, Local: "sync-token"}
+	propSupportedReports  = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propCalendarHome      = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propCalendarData      = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propSupportedCompSet  = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarUserAddrs = xml.Name{Space: nsCalDAV, Local: "calendar-user-address-set"}
+	propCTag              = xml.Name{Space: nsCS, Local: "getctag"}
+)
+
+// propList — список запрошенных свойств из элемента <prop>
+type propList struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// names возвращает имена запрошенных свойств
+func (p *propList) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]xml.Name, len(p.Names))
+	for i, n := range p.Names {
+		names[i] = n.XMLName
+	}
+	return names
+}
+
+// propfindRequest — тело запроса PROPFIND
+type propfindRequest struct {
+	XMLName xml.Name  `xml:"DAV: propfind"`
+	AllProp *struct{} `xml:"DAV: allprop"`
+	Prop    *propList `xml:"DAV: prop"`
+}
+
+// compFilter — фильтр компонентов из запроса calendar-query
+type compFilter struct {
+	Name    string       `xml:"name,attr"`
+	Filters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// reportRequest — тело запросов REPORT calendar-query, calendar-multiget и sync-collection
+type reportRequest struct {
+	XMLName   xml.Name
+	Prop      *propList   `xml:"DAV: prop"`
+	Hrefs     []string    `xml:"DAV: href"`
+	SyncToken string      `xml:"DAV: sync-token"`
+	Filter    *compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+// props — значения свойств ресурса в виде готового XML содержимого элементов
+type props map[xml.Name]string
+
+// resource описывает ресурс в ответе multistatus
+type resource struct {
+	href  string
+	props props
+	// status задаётся для ресурсов без свойств, например удалённых при синхронизации
+	status int
+}
+
+// decodeBody разбирает XML тело запроса. Пустое тело не считается ошибкой
+func decodeBody(r *http.Request, v any) (bool, error) {
+	err := xml.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(v)
+	if err == io.EOF {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// writeMultistatus отправляет ответ 207 с запрошенными свойствами ресурсов.
+// Если names пуст, возвращаются все свойства, кроме содержимого календаря
+func writeMultistatus(w http.ResponseWriter, resources []resource, names []xml.Name, syncToken string) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+
+	for _, res := range resources {
+		b.WriteString("<d:response><d:href>")
+		xml.EscapeText(&b, []byte(res.href))
+		b.WriteString("</d:href>")
+
+		if res.status != 0 {
+			writeStatus(&b, res.status)
+			b.WriteString("</d:response>")
+			continue
+		}
+
+		var found, missing []xml.Name
+		if len(names) == 0 {
+			for name := range res.props {
+				if name != propCalendarData {
+					found = append(found, name)
+				}
+			}
+			sort.Slice(found, func(i, j int) bool {
+				return found[i].Space+found[i].Local < found[j].Space+found[j].Local
+			})
+		} else {
+			for _, name := range names {
+				if _, ok := res.props[name]; ok {
+					found = append(found, name)
+				} else {
+					missing = append(missing, name)
+				}
+			}
+		}
+
+		if len(found) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range found {
+				writeElement(&b, name, res.props[name])
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusOK)
+			b.WriteString("</d:propstat>")
+		}
+		if len(missing) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range missing {
+				writeElement(&b, name, "")
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusNotFound)
+			b.WriteString("</d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+
+	if syncToken != "" {
+		b.WriteString("<d:sync-token>")
+		xml.EscapeText(&b, []byte(syncToken))
+		b.WriteString("</d:sync-token>")
+	}
+	b.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, b.String())
+}
+
+// writeElement записывает элемент свойства с уже подготовленным содержимым
+func writeElement(b *strings.Builder, name xml.Name, inner string) {
+	prefix, ok := prefixes[name.Space]
+	if !ok {
+		// Неизвестное пространство имён объявляется на самом элементе
+		b.WriteString(`<x:` + name.Local + ` xmlns:x="`)
+		xml.EscapeText(b, []byte(name.Space))
+		b.WriteString(`"/>`)
+		return
+	}
+
+	tag := prefix + ":" + name.Local
+	if inner == "" {
+		b.WriteString("<" + tag + "/>")
+		return
+	}
+	b.WriteString("<" + tag + ">" + inner + "</" + tag + ">")
+}
+
+// writeStatus записывает элемент status для кода ответа
+func writeStatus(b *strings.Builder, code int) {
+	b.WriteString("<d:status>HTTP/1.1 ")
+	b.WriteString(strconv.Itoa(code) + " " + http.StatusText(code))
+	b.WriteString("</d:status>")
+}
+
+// hrefElement возвращает элемент href с экранированным адресом
+func hrefElement(href string) string {
+	var b strings.Builder
+	b.WriteString("<d:href>")
+	xml.EscapeText(&b, []byte(href))
+	b.WriteString("</d:href>")
+	return b.String()
+}
+
+// escape экранирует текстовое содержимое элемента
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
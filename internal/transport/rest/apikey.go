@@ -0,0 +1,71 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// CreateAPIKeyHandler обрабатывает POST запрос для выпуска ключа API.
+// Сам ключ возвращается только в ответе на этот запрос
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var key models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		response(w, http.StatusBadRequest, models.APIKeyResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	key, err := services.CreateAPIKey(r.Context(), key)
+	switch {
+	case errors.Is(err, services.ErrAPIKeyName), errors.Is(err, services.ErrAPIKeyScope):
+		response(w, http.StatusBadRequest, models.APIKeyResponse{Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrScopeDenied):
+		response(w, http.StatusForbidden, models.APIKeyResponse{Error: err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to create API key: %v", err)
+		response(w, http.StatusInternalServerError, models.APIKeyResponse{Error: "Failed to create API key"})
+		return
+	}
+
+	response(w, http.StatusOK, models.APIKeyResponse{APIKey: key})
+}
+
+// GetAPIKeysHandler обрабатывает GET запрос для вывода списка ключей API
+func GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := database.GetAPIKeys(services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.APIKeyResponse{Error: "error getting api key list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// DeleteAPIKeyHandler обрабатывает DELETE запрос для отзыва ключа API
+func DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.APIKeyResponse{Error: "Invalid ID"})
+		return
+	}
+
+	err := database.DeleteAPIKey(services.UserID(r.Context()), id)
+	switch {
+	case errors.Is(err, database.ErrAPIKeyNotFound):
+		response(w, http.StatusNotFound, models.APIKeyResponse{Error: "API key not found"})
+		return
+	case err != nil:
+		response(w, http.StatusBadRequest, models.APIKeyResponse{Error: "Failed to revoke API key"})
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// BackupHandler обрабатывает GET запрос для скачивания согласованной копии базы данных
+func BackupHandler(w http.ResponseWriter, r *http.Request) {
+	dir, err := os.MkdirTemp("", "todo-backup-")
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Failed to create backup"})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scheduler.db")
+	if err := database.Backup(path); err != nil {
+		log.Printf("Backup failed: %v", err)
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Failed to create backup"})
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Failed to create backup"})
+		return
+	}
+	defer file.Close()
+
+	name := "scheduler-" + time.Now().Format("20060102-150405") + ".db"
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// RestoreHandler обрабатывает POST запрос для восстановления базы данных из загруженной копии.
+// Копия проверяется до того, как заменит текущие данные
+func RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := uploadBody(w, r)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp("", "todo-restore-*.db")
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Failed to restore backup"})
+		return
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to read file"})
+		return
+	}
+
+	if err := database.VerifyBackup(file.Name()); err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Invalid backup: " + err.Error()})
+		return
+	}
+
+	if err := database.Restore(file.Name()); err != nil {
+		log.Printf("Restore failed: %v", err)
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Failed to restore backup"})
+		return
+	}
+
+	// Задачи заменены целиком, поэтому клиенты должны перечитать список
+	services.Events.Publish(models.Event{Type: models.EventReload})
+
+	response(w, http.StatusOK, struct{}{})
+}
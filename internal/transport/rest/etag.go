@@ -0,0 +1,41 @@
+package rest
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// setETag добавляет в ответ заголовок ETag с версией задачи
+func setETag(w http.ResponseWriter, task models.Task) {
+	w.Header().Set("ETag", services.ETag(task.Version))
+}
+
+// ifMatch возвращает условие изменения задачи по заголовку If-Match
+func ifMatch(r *http.Request) services.Precondition {
+	return services.IfMatch(r.Header.Get("If-Match"))
+}
+
+// preconditionFailed отправляет ответ 412 о конфликте версий
+func preconditionFailed(w http.ResponseWriter) {
+	response(w, http.StatusPreconditionFailed, models.TaskResponse{Error: "Task has been modified"})
+}
+
+// respondTxError отправляет ответ на ошибку, возникшую при изменении задачи в транзакции
+func respondTxError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Task not found"})
+	case errors.Is(err, services.ErrPreconditionFailed), errors.Is(err, database.ErrVersionConflict):
+		preconditionFailed(w)
+	case errors.Is(err, services.ErrRepeatFormat):
+		response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Invalid format of repeat rule"})
+	default:
+		log.Printf("Task transaction failed: %v", err)
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: message})
+	}
+}
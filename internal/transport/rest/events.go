@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// heartbeatInterval — период комментариев, которые не дают прокси закрыть неактивное соединение
+const heartbeatInterval = 15 * time.Second
+
+// EventsHandler обрабатывает GET запрос и передаёт события об изменении задач
+// в формате Server-Sent Events. После переподключения по заголовку Last-Event-ID
+// повторяются пропущенные события
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	replay, events, cancel := services.Events.Subscribe(services.UserID(r.Context()), lastID, err == nil)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := fmt.Fprintf(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	// Поток завершается, когда сессия закрыта, токен доступа истёк или ключ API отозван
+	revoked := services.WatchCredentials(r.Context())
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-revoked:
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Клиент отстал и отключён, он переподключится и получит пропущенные события
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent записывает событие в формате Server-Sent Events
+func writeEvent(w http.ResponseWriter, event models.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,281 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// ExportHandler обрабатывает GET запрос для выгрузки всех задач в JSON.
+// Задачи передаются клиенту по мере чтения из базы данных
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportedAt, _ := json.Marshal(time.Now().UTC().Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.json"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprintf(w, `{"version":%d,"exported_at":%s,"tasks":[`, models.ExportVersion, exportedAt); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	first := true
+	err := database.EachTask(services.TaskScope(r.Context()), func(task models.Task) error {
+		if !first {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(models.ExportTask{
+			ID:      task.ID,
+			Date:    task.Date,
+			Title:   task.Title,
+			Comment: task.Comment,
+			Repeat:  task.Repeat,
+			Version: task.Version,
+			Anchor:  task.Anchor,
+		})
+	})
+	if err != nil {
+		// Заголовки уже отправлены, поэтому оставляем выгрузку незавершённой
+		log.Printf("Failed to export tasks: %v", err)
+		return
+	}
+
+	if _, err := w.Write([]byte("]}\n")); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// ImportHandler обрабатывает POST запрос для загрузки задач из JSON.
+// В режиме merge задачи с существующими ID обновляются, остальные добавляются как новые.
+// В режиме replace все задачи удаляются, а загруженные сохраняют свои ID
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mode := r.FormValue("mode")
+	if mode == "" {
+		mode = models.ImportMerge
+	}
+	if mode != models.ImportMerge && mode != models.ImportReplace {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Invalid import mode"})
+		return
+	}
+
+	var doc models.ExportDocument
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response(w, http.StatusRequestEntityTooLarge, models.ImportResult{Error: "Request body too large"})
+			return
+		}
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "JSON deserialization error"})
+		return
+	}
+	if doc.Version != models.ExportVersion {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Unsupported export version"})
+		return
+	}
+
+	rows := make([]importRow, len(doc.Tasks))
+	for i, item := range doc.Tasks {
+		rows[i] = importRow{Item: item}
+	}
+
+	importRows(w, r, mode, rows)
+}
+
+// importRow — задача из загружаемого файла или причина, по которой её не удалось прочитать.
+// Ref идентифицирует строку в отчёте об ошибках, если у задачи ещё нет ID, а Line — номер
+// строки текстового файла; без него в отчёте указывается порядковый номер задачи
+type importRow struct {
+	Item  models.ExportTask
+	Ref   string
+	Line  int
+	Error string
+}
+
+// importRows загружает задачи в одной транзакции и отправляет клиенту итог загрузки.
+// Прошедшие даты по умолчанию сохраняются, чтобы перенос задач между серверами не менял
+// историю, и переносятся на сегодня только с параметром overdue=clamp
+func importRows(w http.ResponseWriter, r *http.Request, mode string, rows []importRow) {
+	var result models.ImportResult
+	policy := config.OverdueKeep
+	var err error
+	if r.URL.Query().Get("overdue") != "" {
+		policy, err = overduePolicy(r)
+	}
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: err.Error()})
+		return
+	}
+
+	now := time.Now()
+	err = services.RunInTx(r.Context(), func(tx *database.Tx) error {
+		result = models.ImportResult{Errors: []models.ImportError{}}
+
+		if mode == models.ImportReplace {
+			tasks, err := tx.GetAllTasks()
+			if err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				if err := tx.DeleteTask(task.ID, task.Version); err != nil {
+					return err
+				}
+				if err := tx.AddHistory(services.NewHistoryEntry(r.Context(), task.ID, models.ActionDelete, &task, nil)); err != nil {
+					return err
+				}
+				result.Deleted++
+			}
+		}
+
+		seen := make(map[string]bool)
+		for i, row := range rows {
+			rowErr := row.Error
+			if rowErr == "" {
+				var err error
+				if rowErr, err = importTask(r, tx, mode, row.Item, now, policy, seen, &result); err != nil {
+					return err
+				}
+			}
+			if rowErr != "" {
+				ref := row.Ref
+				if ref == "" {
+					ref = row.Item.ID
+				}
+				line := row.Line
+				if line == 0 {
+					line = i + 1
+				}
+				result.Errors = append(result.Errors, models.ImportError{Row: line, ID: ref, Title: row.Item.Title, Error: rowErr})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to import tasks: %v", err)
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Failed to import tasks"})
+		return
+	}
+
+	response(w, http.StatusOK, result)
+}
+
+// allTasks читает все задачи области видимости без ограничения количества
+func allTasks(scope models.Scope) ([]models.Task, error) {
+	tasks := []models.Task{}
+	err := database.EachTask(scope, func(task models.Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	return tasks, err
+}
+
+// importTask загружает одну задачу из файла. Первое значение содержит причину,
+// по которой задача пропущена, а ошибка прерывает всю загрузку
+func importTask(r *http.Request, tx *database.Tx, mode string, item models.ExportTask, now time.Time,
+	policy string, seen map[string]bool, result *models.ImportResult) (string, error) {
+	task := models.Task{
+		ID:      item.ID,
+		Date:    item.Date,
+		Title:   item.Title,
+		Comment: item.Comment,
+		Repeat:  item.Repeat,
+	}
+
+	// Проверяем задачу по тем же правилам, что и при создании
+	if err := services.ValidateTask(&task, now, policy); err != nil {
+		return err.Error(), nil
+	}
+	// Дата, с которой отложена задача, нужна только повторяющейся задаче
+	if task.Repeat != "" && item.Anchor != "" {
+		if _, err := time.Parse(config.DateFormat, item.Anchor); err != nil {
+			return "Invalid anchor date", nil
+		}
+		task.Anchor = item.Anchor
+	}
+
+	if task.ID != "" {
+		if _, err := strconv.Atoi(task.ID); err != nil {
+			return "Invalid ID", nil
+		}
+		if seen[task.ID] {
+			return "Duplicate ID", nil
+		}
+		seen[task.ID] = true
+	}
+
+	// Идентификатор, занятый задачей другого пользователя, не сохраняется
+	if mode == models.ImportReplace && task.ID != "" {
+		used, err := tx.TaskIDUsed(task.ID)
+		if err != nil {
+			return "", err
+		}
+		if used {
+			task.ID = ""
+		}
+	}
+
+	if mode == models.ImportReplace && task.ID != "" {
+		task.Version = item.Version
+		if err := tx.RestoreTask(task); err != nil {
+			return "", err
+		}
+		result.Created++
+		return "", tx.AddHistory(services.NewHistoryEntry(r.Context(), task.ID, models.ActionCreate, nil, &task))
+	}
+
+	if mode == models.ImportMerge && task.ID != "" {
+		current, err := tx.GetTask(task.ID)
+		if err == nil {
+			task.Version = current.Version
+			if task, err = tx.UpdateTask(task); err != nil {
+				return "", err
+			}
+			result.Updated++
+			return "", tx.AddHistory(services.NewHistoryEntry(r.Context(), task.ID, models.ActionUpdate, &current, &task))
+		}
+		if !errors.Is(err, database.ErrTaskNotFound) {
+			return "", err
+		}
+	}
+
+	id, err := tx.AddTask(task)
+	if err != nil {
+		return "", err
+	}
+	task.ID = strconv.Itoa(id)
+	result.Created++
+	return "", tx.AddHistory(services.NewHistoryEntry(r.Context(), task.ID, models.ActionCreate, nil, &task))
+}
+
+// maxUploadSize ограничивает размер загружаемого файла
+const maxUploadSize = 10 << 20
+
+// uploadBody возвращает содержимое загружаемого файла: поле file формы multipart/form-data
+// или тело запроса целиком
+func uploadBody(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// CreateFeedHandler обрабатывает POST запрос для создания подписки календаря.
+// Секретная ссылка возвращается только в ответе на этот запрос
+func CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	var feed models.Feed
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	if feed.Component == "" {
+		feed.Component = models.ComponentTodo
+	}
+	if feed.Component != models.ComponentTodo && feed.Component != models.ComponentEvent {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "Component must be VTODO or VEVENT"})
+		return
+	}
+
+	token, err := services.RandomToken(32)
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.FeedResponse{Error: "Failed to generate feed token"})
+		return
+	}
+
+	feed.Owner = services.UserID(r.Context())
+	id, err := database.AddFeed(feed, services.HashToken(token))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "Failed to create feed"})
+		return
+	}
+
+	feed.ID = strconv.Itoa(id)
+	feed.Token = token
+	feed.URL = feedURL(r, token)
+	response(w, http.StatusOK, models.FeedResponse{Feed: feed})
+}
+
+// GetFeedsHandler обрабатывает GET запрос для вывода списка подписок календаря
+func GetFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	feeds, err := database.GetFeeds(services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "error getting feed list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"feeds": feeds})
+}
+
+// DeleteFeedHandler обрабатывает DELETE запрос для отзыва подписки календаря
+func DeleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "Invalid ID"})
+		return
+	}
+
+	if err := database.DeleteFeed(services.UserID(r.Context()), id); err != nil {
+		response(w, http.StatusBadRequest, models.FeedResponse{Error: "Failed to delete feed"})
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// CalendarFeedHandler обрабатывает GET запрос календаря к /api/feed.ics.
+// Доступ проверяется по секретному токену подписки, так как календари не передают куку token
+func CalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	feed, err := database.GetFeedByToken(services.HashToken(r.FormValue("token")))
+	if errors.Is(err, database.ErrFeedNotFound) {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load feed", http.StatusInternalServerError)
+		return
+	}
+
+	tasks, err := allTasks(models.Scope{Owner: feed.Owner})
+	if err != nil {
+		http.Error(w, "error getting task list", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	if err := services.WriteCalendar(w, feed.Name, feed.Component, tasks, time.Now(), nil); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// feedURL формирует секретную ссылку подписки относительно адреса запроса
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/feed.ics?token=%s", scheme, r.Host, url.QueryEscape(token))
+}
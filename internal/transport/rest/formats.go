@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// ExportCSVHandler обрабатывает GET запрос для выгрузки задач в CSV.
+// Параметр columns задаёт соответствие полей и столбцов, например "title:Задача,date:Срок"
+func ExportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	columns, err := services.ParseCSVColumns(r.FormValue("columns"))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: err.Error()})
+		return
+	}
+
+	tasks, err := allTasks(services.TaskScope(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "error getting task list"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	if err := services.WriteCSV(w, columns, tasks); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// ImportCSVHandler обрабатывает POST запрос для загрузки задач из CSV.
+// Задачи с существующими ID обновляются, остальные добавляются как новые
+func ImportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	columns, err := services.ParseCSVColumns(r.FormValue("columns"))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: err.Error()})
+		return
+	}
+
+	body, err := uploadBody(w, r)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	items, err := services.ReadCSV(body, columns)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: err.Error()})
+		return
+	}
+
+	rows := make([]importRow, len(items))
+	for i, item := range items {
+		rows[i] = importRow{Item: item.Task, Line: item.Line}
+	}
+	importRows(w, r, models.ImportMerge, rows)
+}
+
+// ExportTodoTxtHandler обрабатывает GET запрос для выгрузки задач в формате todo.txt
+func ExportTodoTxtHandler(w http.ResponseWriter, r *http.Request) {
+	tasks, err := allTasks(services.TaskScope(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "error getting task list"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="todo.txt"`)
+	bw := bufio.NewWriter(w)
+	for _, task := range tasks {
+		bw.WriteString(services.FormatTodoTxt(task))
+		bw.WriteString("\n")
+	}
+	if err := bw.Flush(); err != nil {
+		log.Printf("Error writing response: %v\n", err)
+	}
+}
+
+// ImportTodoTxtHandler обрабатывает POST запрос для загрузки задач из файла todo.txt.
+// Выполненные задачи и строки с непереводимым rec: возвращаются в списке ошибок
+func ImportTodoTxtHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := uploadBody(w, r)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	var rows []importRow
+	scanner := bufio.NewScanner(body)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		item, err := services.ParseTodoTxt(line)
+		if errors.Is(err, services.ErrTodoTxtCompleted) {
+			item.Title = line
+		}
+		row := importRow{Item: item, Line: number}
+		if err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Failed to read file"})
+		return
+	}
+
+	importRows(w, r, models.ImportMerge, rows)
+}
@@ -2,7 +2,6 @@ package rest
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -59,71 +58,45 @@ func CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверяем наличие заголовка
-	if task.Title == "" {
-		res.Error = "Task title not specified"
-		response(w, http.StatusInternalServerError, res)
+	// Прошедшая дата переносится на сегодня или сохраняется по правилу запроса
+	policy, err := overduePolicy(r)
+	if err != nil {
+		res.Error = err.Error()
+		response(w, http.StatusBadRequest, res)
 		return
 	}
 
-	// Получаем сегодняшнюю дату
-	now := time.Now()
-
-	// Проверяем формат даты и устанавливаем текущую дату
-	if task.Date == "" {
-		task.Date = now.Format(config.DateFormat)
-	} else {
-		date, err := time.Parse(config.DateFormat, task.Date)
-		if err != nil {
-			res.Error = "Date is in the wrong format"
-			response(w, http.StatusInternalServerError, res)
-			return
-		}
-		if date.Before(now) {
-			task.Date = now.Format(config.DateFormat)
-		}
-	}
-
-	// Проверяем правило повторения
-	if task.Repeat != "" {
-		if _, err := services.NextDate(now, task.Date, task.Repeat); err != nil {
-			res.Error = "Invalid format of repeat rule"
-			response(w, http.StatusInternalServerError, res)
-			return
-		}
+	// Проверяем заголовок, дату и правило повторения
+	if err := services.ValidateTask(&task, time.Now(), policy); err != nil {
+		res.Error = err.Error()
+		response(w, http.StatusBadRequest, res)
+		return
 	}
 
-	// Добавляем задачу в базу данных
-	taskId, err := database.AddTask(task)
+	// Добавляем задачу в базу данных вместе с записью в истории
+	task, err = services.CreateTask(r.Context(), task)
 	if err != nil {
 		res.Error = "Failed to create task"
 		response(w, http.StatusBadRequest, res)
 		return
 	}
-	res.ID = fmt.Sprintf("%d", taskId)
+	res.ID = task.ID
+
 	response(w, http.StatusOK, res)
 }
 
 // GetTasksListHandler обрабатывает GET запрос для вывода задач
 func GetTasksListHandler(w http.ResponseWriter, r *http.Request) {
-	var filter models.TaskFilter
-
-	search := r.FormValue("search")
-	searchParsed, err := time.Parse("02.01.2006", search)
-	if err == nil {
-		filter.SearchData = true
-		filter.Search = searchParsed.Format("20060102")
-	} else {
-		filter.Search = "%" + search + "%"
-	}
+	filter := services.SearchFilter(r.FormValue("search"))
 
-	tasks, err := database.GetTasks(filter)
+	tasks, err := database.GetTasks(services.TaskScope(r.Context()), filter)
 	if err != nil {
 		res := models.TaskResponse{Error: "error getting task list"}
 		response(w, http.StatusBadRequest, res)
 		return
 	}
 
+	services.MarkOverdue(tasks, time.Now())
 	res := map[string]interface{}{"tasks": tasks}
 	response(w, http.StatusOK, res)
 
@@ -133,13 +106,15 @@ func GetTasksListHandler(w http.ResponseWriter, r *http.Request) {
 func GetTaskIdHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
 
-	task, err := database.GetTask(id)
+	task, err := database.GetTask(services.TaskScope(r.Context()), id)
 	if err != nil {
 		res := models.TaskResponse{Error: "failed to encode response"}
 		response(w, http.StatusBadRequest, res)
 		return
 	}
+	task.Overdue = services.IsOverdue(task, time.Now())
 
+	setETag(w, task)
 	response(w, http.StatusOK, task)
 }
 
@@ -157,60 +132,47 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	// Проверяем наличие ID
 	if task.ID == "" {
 		res.Error = "Missing task ID"
-		response(w, http.StatusInternalServerError, res)
+		response(w, http.StatusBadRequest, res)
 		return
 	}
 
 	// Проверяем валидность ID
 	if _, err := strconv.Atoi(task.ID); err != nil {
 		res.Error = "Invalid ID"
-		response(w, http.StatusInternalServerError, res)
-		return
-	}
-
-	// Проверяем наличие заголовка
-	if task.Title == "" {
-		res.Error = "Task title not specified"
-		response(w, http.StatusInternalServerError, res)
+		response(w, http.StatusBadRequest, res)
 		return
 	}
 
-	// Получаем сегодняшнюю дату
-	now := time.Now()
-
-	// Проверяем формат даты и устанавливаем текущую дату
-	dateParse, err := time.Parse(config.DateFormat, task.Date)
+	// Прошедшая дата переносится на сегодня или сохраняется по правилу запроса
+	policy, err := overduePolicy(r)
 	if err != nil {
-		res.Error = "Date is in the wrong format"
-		response(w, http.StatusInternalServerError, res)
+		res.Error = err.Error()
+		response(w, http.StatusBadRequest, res)
 		return
 	}
-	if dateParse.Before(now) {
-		task.Date = now.Format(config.DateFormat)
-	}
 
-	// Проверяем правило повторения
-	if task.Repeat != "" {
-		if _, err := services.NextDate(now, task.Date, task.Repeat); err != nil {
-			res.Error = "Invalid format of repeat rule"
-			response(w, http.StatusInternalServerError, res)
-			return
-		}
+	// В отличие от создания, пустая дата при изменении не заменяется на сегодняшнюю
+	if task.Date == "" {
+		res.Error = services.ErrDateFormat.Error()
+		response(w, http.StatusBadRequest, res)
+		return
 	}
 
-	_, err = database.GetTask(task.ID)
-	if err != nil {
-		res := models.TaskResponse{Error: "Task not found"}
+	// Проверяем заголовок, дату и правило повторения по тем же правилам, что и при создании
+	if err := services.ValidateTask(&task, time.Now(), policy); err != nil {
+		res.Error = err.Error()
 		response(w, http.StatusBadRequest, res)
 		return
 	}
 
-	_, err = database.UpdateTask(task)
+	// Проверяем версию и изменяем задачу в одной транзакции
+	task, err = services.UpdateTask(r.Context(), task, ifMatch(r))
 	if err != nil {
-		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to update task"})
+		respondTxError(w, err, "Failed to update task")
 		return
 	}
 
+	setETag(w, task)
 	response(w, http.StatusOK, task)
 }
 
@@ -229,8 +191,8 @@ func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.DeleteTask(id); err != nil {
-		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to delete task"})
+	if err := services.DeleteTask(r.Context(), id, ifMatch(r)); err != nil {
+		respondTxError(w, err, "Failed to delete task")
 		return
 	}
 
@@ -241,30 +203,14 @@ func DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.FormValue("id")
 
-	task, err := database.GetTask(id)
+	task, err := services.CompleteTask(r.Context(), id, time.Now(), ifMatch(r))
 	if err != nil {
-		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Task not found"})
+		respondTxError(w, err, "Failed to update task")
 		return
 	}
 
-	if task.Repeat == "" {
-		if err := database.DeleteTask(task.ID); err != nil {
-			response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to delete task"})
-			return
-		}
-	} else {
-		task.Date, err = services.NextDate(time.Now(), task.Date, task.Repeat)
-		if err != nil {
-			response(w, http.StatusInternalServerError, models.TaskResponse{Error: "Invalid format of repeat rule"})
-			return
-		}
-
-		_, err := database.UpdateTask(task)
-		if err != nil {
-			response(w, http.StatusBadRequest, models.TaskResponse{Error: "Failed to update task"})
-			return
-		}
+	if task != nil {
+		setETag(w, *task)
 	}
-
 	response(w, http.StatusOK, struct{}{})
 }
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// TaskHistoryHandler обрабатывает GET запрос для вывода истории изменений задачи
+func TaskHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Invalid ID"})
+		return
+	}
+
+	history, err := database.GetHistory(services.TaskScope(r.Context()), id)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "error getting task history"})
+		return
+	}
+
+	for i := range history {
+		history[i].Changes = services.DiffTasks(history[i].Before, history[i].After)
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"history": history})
+}
+
+// RevertTaskHandler обрабатывает POST запрос для возврата задачи к версии из истории
+func RevertTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "Invalid ID"})
+		return
+	}
+
+	entry, err := database.GetHistoryEntry(services.TaskScope(r.Context()), id, r.FormValue("entry"))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "History entry not found"})
+		return
+	}
+
+	task, err := services.RevertTask(r.Context(), id, entry, ifMatch(r))
+	if err != nil {
+		respondTxError(w, err, "Failed to revert task")
+		return
+	}
+
+	setETag(w, task)
+	response(w, http.StatusOK, task)
+}
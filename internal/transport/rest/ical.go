@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// ImportCalendarHandler обрабатывает POST запрос для загрузки задач из файла iCalendar.
+// Компоненты VTODO и VEVENT добавляются как новые задачи, а те, чьё правило повторения
+// нельзя перевести, возвращаются в списке ошибок
+func ImportCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := uploadBody(w, r)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Failed to read file"})
+		return
+	}
+	defer body.Close()
+
+	items, err := services.ParseCalendar(body)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ImportResult{Error: "Invalid iCalendar file"})
+		return
+	}
+
+	now := time.Now()
+	rows := make([]importRow, len(items))
+	for i, item := range items {
+		task, rowErr := calendarTask(item, now)
+		rows[i] = importRow{
+			Item:  models.ExportTask{Date: task.Date, Title: item.Title, Comment: task.Comment, Repeat: task.Repeat},
+			Ref:   item.UID,
+			Error: rowErr,
+		}
+	}
+
+	importRows(w, r, models.ImportMerge, rows)
+}
+
+// calendarTask переводит компонент календаря в задачу. Вторым значением возвращается
+// причина, по которой компонент не может быть загружен
+func calendarTask(item models.CalendarItem, now time.Time) (models.Task, string) {
+	if item.Status == "COMPLETED" || item.Status == "CANCELLED" {
+		return models.Task{}, "Item is " + item.Status
+	}
+
+	task, err := services.CalendarItemTask(item)
+	if err != nil {
+		return models.Task{}, err.Error()
+	}
+
+	// Прошедшую дату не меняем: правило обработки применяется при загрузке
+	if err := services.ValidateTask(&task, now, config.OverdueKeep); err != nil {
+		return models.Task{}, err.Error()
+	}
+	return task, ""
+}
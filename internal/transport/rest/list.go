@@ -0,0 +1,203 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// CreateListHandler обрабатывает POST запрос для создания общего списка задач.
+// Создатель списка становится его владельцем
+func CreateListHandler(w http.ResponseWriter, r *http.Request) {
+	var list models.List
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	name, err := services.ValidListName(list.Name)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: err.Error()})
+		return
+	}
+
+	list, err = database.AddList(name, services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: "Failed to create list"})
+		return
+	}
+
+	response(w, http.StatusOK, models.ListResponse{List: list})
+}
+
+// GetListsHandler обрабатывает GET запрос для вывода общих списков вызывающей стороны
+func GetListsHandler(w http.ResponseWriter, r *http.Request) {
+	lists, err := database.GetLists(services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: err.Error()})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"lists": lists})
+}
+
+// DeleteListHandler обрабатывает DELETE запрос владельца для удаления общего списка вместе с его задачами
+func DeleteListHandler(w http.ResponseWriter, r *http.Request) {
+	if err := database.DeleteList(services.TaskScope(r.Context()).List); err != nil {
+		listError(w, err, "Failed to delete list")
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// GetListMembersHandler обрабатывает GET запрос для вывода участников общего списка
+func GetListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	members, err := database.GetListMembers(services.TaskScope(r.Context()).List)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: err.Error()})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"members": members})
+}
+
+// RemoveListMemberHandler обрабатывает DELETE запрос для отзыва доступа к общему списку.
+// Владелец может удалить любого участника, остальные участники — только выйти из списка сами
+func RemoveListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := strconv.ParseInt(r.FormValue("user"), 10, 64)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.ListResponse{Error: "Invalid user ID"})
+		return
+	}
+
+	scope := services.TaskScope(r.Context())
+	if user != scope.Owner {
+		if _, err := services.ListAccess(r.Context(), r.FormValue(services.ListParam), models.ListOwner); err != nil {
+			response(w, http.StatusForbidden, models.ListResponse{Error: "Insufficient list role"})
+			return
+		}
+	}
+
+	if err := database.RemoveListMember(scope.List, user); err != nil {
+		listError(w, err, "Failed to remove member")
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// InviteHandler обрабатывает POST запрос владельца для приглашения пользователя в общий список.
+// Доступ к списку появляется у пользователя после того, как он примет приглашение
+func InviteHandler(w http.ResponseWriter, r *http.Request) {
+	var inv models.Invitation
+	if err := json.NewDecoder(r.Body).Decode(&inv); err != nil {
+		response(w, http.StatusBadRequest, models.InvitationResponse{Error: "JSON deserialization error"})
+		return
+	}
+	if inv.Role == "" {
+		inv.Role = models.ListViewer
+	}
+	if err := services.ValidListRole(inv.Role); err != nil {
+		response(w, http.StatusBadRequest, models.InvitationResponse{Error: err.Error()})
+		return
+	}
+
+	user, _, err := database.GetUserByLogin(inv.Login)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			response(w, http.StatusNotFound, models.InvitationResponse{Error: "User not found"})
+			return
+		}
+		response(w, http.StatusBadRequest, models.InvitationResponse{Error: "Failed to invite user"})
+		return
+	}
+
+	inviter := services.CurrentUser(r.Context())
+	inv.ListID = services.TaskScope(r.Context()).List
+	inv.ID, err = database.AddInvitation(inv.ListID, user.ID, inviter.ID, inv.Role)
+	if err != nil {
+		listError(w, err, "Failed to invite user")
+		return
+	}
+
+	inv.Login = user.Login
+	inv.InvitedBy = inviter.Login
+	response(w, http.StatusOK, models.InvitationResponse{Invitation: inv})
+}
+
+// GetListInvitationsHandler обрабатывает GET запрос владельца для вывода приглашений в общий список
+func GetListInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	invitations, err := database.GetListInvitations(services.TaskScope(r.Context()).List)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.InvitationResponse{Error: err.Error()})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"invitations": invitations})
+}
+
+// CancelInvitationHandler обрабатывает DELETE запрос владельца для отзыва приглашения в общий список
+func CancelInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := database.CancelInvitation(services.TaskScope(r.Context()).List, r.FormValue("id")); err != nil {
+		listError(w, err, "Failed to cancel invitation")
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// GetInvitationsHandler обрабатывает GET запрос для вывода приглашений, адресованных вызывающей стороне
+func GetInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	invitations, err := database.GetInvitations(services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.InvitationResponse{Error: err.Error()})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"invitations": invitations})
+}
+
+// AcceptInvitationHandler обрабатывает POST запрос для принятия приглашения в общий список
+func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := database.AcceptInvitation(services.UserID(r.Context()), r.FormValue("id"))
+	if err != nil {
+		listError(w, err, "Failed to accept invitation")
+		return
+	}
+
+	response(w, http.StatusOK, models.ListResponse{List: list})
+}
+
+// DeclineInvitationHandler обрабатывает DELETE запрос для отказа от приглашения в общий список
+func DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	if err := database.DeclineInvitation(services.UserID(r.Context()), r.FormValue("id")); err != nil {
+		listError(w, err, "Failed to decline invitation")
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// listError отправляет ответ на ошибку работы с общим списком
+func listError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, database.ErrListNotFound):
+		response(w, http.StatusNotFound, models.ListResponse{Error: "List not found"})
+	case errors.Is(err, database.ErrMemberNotFound):
+		response(w, http.StatusNotFound, models.ListResponse{Error: "Member not found"})
+	case errors.Is(err, database.ErrInvitationNotFound):
+		response(w, http.StatusNotFound, models.ListResponse{Error: "Invitation not found"})
+	case errors.Is(err, database.ErrAlreadyMember):
+		response(w, http.StatusConflict, models.ListResponse{Error: "User is already a member"})
+	case errors.Is(err, database.ErrLastOwner):
+		response(w, http.StatusConflict, models.ListResponse{Error: "List must keep at least one owner"})
+	default:
+		response(w, http.StatusBadRequest, models.ListResponse{Error: message})
+	}
+}
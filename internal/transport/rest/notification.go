@@ -0,0 +1,34 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// notificationLimit — наибольшее количество уведомлений в ответе
+const notificationLimit = 50
+
+// NotificationsHandler обрабатывает GET запрос для вывода ленты уведомлений.
+// Параметр since задаёт номер последнего полученного уведомления
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	var since int64
+	if value := r.FormValue("since"); value != "" {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil {
+			response(w, http.StatusBadRequest, models.TaskResponse{Error: "Invalid since"})
+			return
+		}
+	}
+
+	notifications, err := database.GetNotifications(services.UserID(r.Context()), since, notificationLimit)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "error getting notification list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"notifications": notifications})
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// Кука, которая привязывает незавершённый вход через провайдера к браузеру,
+// начавшему его, чтобы чужой ответ провайдера нельзя было подставить в сессию
+const (
+	cookieOIDCState = "oidc_state"
+	oidcPath        = "/api/oidc"
+)
+
+// OIDCLoginHandler обрабатывает GET запрос входа через провайдера OpenID Connect:
+// перенаправляет на страницу входа провайдера с кодом PKCE
+func OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	state, target, err := services.StartOIDC(config.LoadOIDCConfig(), time.Now())
+	switch {
+	case errors.Is(err, services.ErrOIDCDisabled):
+		response(w, http.StatusNotFound, models.JWTTokenResponse{Error: err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to start OIDC sign-in: %v", err)
+		response(w, http.StatusBadGateway, models.JWTTokenResponse{Error: "Identity provider is unavailable"})
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: cookieOIDCState, Value: state, Path: oidcPath,
+		MaxAge: int(services.OIDCStateTTL / time.Second), HttpOnly: true, Secure: secureCookies(r, config.LoadJWTConfig()), SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// OIDCCallbackHandler обрабатывает возврат от провайдера: обменивает код авторизации
+// на ID-токен, открывает сессию связанной учётной записи и перенаправляет в приложение
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if reason := r.FormValue("error"); reason != "" {
+		response(w, http.StatusUnauthorized, models.JWTTokenResponse{Error: "Identity provider refused sign-in: " + reason})
+		return
+	}
+
+	state := r.FormValue("state")
+	cookie, err := r.Cookie(cookieOIDCState)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: services.ErrOIDCState.Error()})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: cookieOIDCState, Path: oidcPath, MaxAge: -1, HttpOnly: true})
+
+	user, err := services.FinishOIDC(config.LoadOIDCConfig(), state, r.FormValue("code"), time.Now())
+	switch {
+	case errors.Is(err, services.ErrOIDCDisabled):
+		response(w, http.StatusNotFound, models.JWTTokenResponse{Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrOIDCState):
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: err.Error()})
+		return
+	case errors.Is(err, services.ErrOIDCNoAccount):
+		response(w, http.StatusForbidden, models.JWTTokenResponse{Error: err.Error()})
+		return
+	case errors.Is(err, database.ErrLoginTaken):
+		response(w, http.StatusConflict, models.JWTTokenResponse{Error: "Login already taken, ask the administrator to link your identity"})
+		return
+	case err != nil:
+		log.Printf("Failed to finish OIDC sign-in: %v", err)
+		response(w, http.StatusUnauthorized, models.JWTTokenResponse{Error: "Identity provider sign-in failed"})
+		return
+	}
+
+	cfg := config.LoadJWTConfig()
+	tokens, err := services.IssueToken(cfg, user.ID, user.Login)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Failed to sign JWT"})
+		return
+	}
+
+	setSessionCookies(w, r, cfg, tokens)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
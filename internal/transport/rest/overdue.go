@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// overduePolicy возвращает правило обработки прошедших дат из параметра overdue запроса
+func overduePolicy(r *http.Request) (string, error) {
+	return services.OverduePolicy(r.URL.Query().Get("overdue"))
+}
+
+// OverdueTasksHandler обрабатывает GET запрос для вывода просроченных задач
+func OverdueTasksHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	tasks, err := database.GetOverdueTasks(services.TaskScope(r.Context()), now.Format(config.DateFormat))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: "error getting task list"})
+		return
+	}
+
+	services.MarkOverdue(tasks, now)
+	response(w, http.StatusOK, map[string]interface{}{"tasks": tasks})
+}
@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"net/http"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+)
+
+// signinLogLimit — количество последних записей в журнале неудачных попыток входа
+const signinLogLimit = 100
+
+// SigninFailuresHandler обрабатывает GET запрос для вывода журнала неудачных попыток входа.
+// Параметры login и ip ограничивают журнал учётной записью и адресом
+func SigninFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	failures, err := database.GetSigninFailures(r.FormValue("login"), r.FormValue("ip"), signinLogLimit)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "error getting signin failure list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"failures": failures})
+}
+
+// ClearLockoutsHandler обрабатывает DELETE запрос для снятия блокировок входа с учётной
+// записи login и адреса ip. Без параметров снимаются все блокировки
+func ClearLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	cleared, err := database.ClearSigninFailures(r.FormValue("login"), r.FormValue("ip"))
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.UserResponse{Error: "Failed to clear lockouts"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"cleared": cleared})
+}
@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// SnoozeTaskHandler обрабатывает POST запрос для откладывания задачи на интервал:
+// +1d, +2w, next-week, next-monday и т. п.
+func SnoozeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	interval := r.FormValue("interval")
+	now := time.Now()
+
+	postponeTask(w, r, func(current models.Task) (string, error) {
+		return services.SnoozeDate(now, current.Date, interval)
+	})
+}
+
+// PostponeTaskHandler обрабатывает POST запрос для переноса задачи на указанную дату
+func PostponeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	date := r.FormValue("date")
+	now := time.Now()
+
+	postponeTask(w, r, func(models.Task) (string, error) {
+		return services.PostponeDate(now, date)
+	})
+}
+
+// postponeTask переносит задачу из параметра id на дату, вычисленную по её текущему состоянию
+func postponeTask(w http.ResponseWriter, r *http.Request, date func(current models.Task) (string, error)) {
+	task, err := services.PostponeTask(r.Context(), r.FormValue("id"), date, ifMatch(r))
+	switch {
+	case errors.Is(err, services.ErrSnoozeFormat), errors.Is(err, services.ErrPostponeDate), errors.Is(err, services.ErrDateFormat):
+		response(w, http.StatusBadRequest, models.TaskResponse{Error: err.Error()})
+		return
+	case err != nil:
+		respondTxError(w, err, "Failed to postpone task")
+		return
+	}
+
+	setETag(w, task)
+	response(w, http.StatusOK, task)
+}
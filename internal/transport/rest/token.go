@@ -1,18 +1,21 @@
 package rest
 
 import (
-	"crypto/sha256"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"todo-rest/internal/config"
+	"todo-rest/internal/database"
 	"todo-rest/internal/models"
-
-	"github.com/golang-jwt/jwt/v5"
+	"todo-rest/internal/services"
 )
 
-// TokenHandler обрабатывает запросы на аутентификацию
+// TokenHandler обрабатывает запросы на аутентификацию. С именем пользователя
+// проверяется его пароль, без него — общий пароль TODO_PASSWORD, которым входят
+// в учётную запись администратора. После неудачных попыток вход задерживается и блокируется
 func TokenHandler(w http.ResponseWriter, r *http.Request) {
 	var creds models.Credentials
 	var token models.JWTTokenResponse
@@ -26,34 +29,201 @@ func TokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем хранимый пароль из переменной окружения
-	pass := cfg.Password
-	if pass == "" {
-		token.Error = "Password not set in environment"
-		response(w, http.StatusInternalServerError, token)
+	account := creds.Login
+	if account == "" {
+		// Без общего пароля вход в учётную запись администратора невозможен
+		set, err := services.SharedPasswordSet(cfg)
+		if err != nil {
+			token.Error = "Failed to check password"
+			response(w, http.StatusInternalServerError, token)
+			return
+		}
+		if !set {
+			token.Error = "Password not set"
+			response(w, http.StatusInternalServerError, token)
+			return
+		}
+		account = models.AdminLogin
+	}
+
+	attempt, ok := startSignin(w, r, account)
+	if !ok {
 		return
 	}
 
-	// Проверяем, что введённый пароль совпадает с хранимым
-	if creds.Password != pass {
+	if creds.Login != "" {
+		userToken(w, r, cfg, creds, attempt)
+		return
+	}
+
+	// Проверяем пароль по хэшу за постоянное время
+	ok, err := services.CheckSharedPassword(cfg, creds.Password)
+	if err != nil {
+		token.Error = "Failed to check password"
+		response(w, http.StatusInternalServerError, token)
+		return
+	}
+	if !ok {
 		token.Error = "Wrong password"
 		response(w, http.StatusUnauthorized, token)
 		return
 	}
 
-	// Создаём JWT и указываем алгоритм хеширования
-	passwordHash := fmt.Sprintf("%x", sha256.Sum256([]byte(pass)))
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"passwordHash": passwordHash,
-	})
+	// Открываем сессию администратора и выдаём токены с её идентификатором
+	finishSignin(w, r, cfg, attempt, models.AdminID, "")
+}
 
-	// Получаем подписанный токен
-	signedToken, err := jwtToken.SignedString([]byte(cfg.Secret))
+// userToken проверяет имя и пароль пользователя и выдаёт токен его сессии
+func userToken(w http.ResponseWriter, r *http.Request, cfg *config.JWTConfig, creds models.Credentials, attempt *services.SigninAttempt) {
+	user, err := services.Authenticate(creds.Login, creds.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		response(w, http.StatusUnauthorized, models.JWTTokenResponse{Error: err.Error()})
+		return
+	}
 	if err != nil {
-		token.Error = "Failed to sign JWT"
-		response(w, http.StatusBadRequest, token)
+		response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to check password"})
+		return
+	}
+
+	finishSignin(w, r, cfg, attempt, user.ID, user.Login)
+}
+
+// finishSignin завершает вход с проверенным паролем: открывает сессию пользователя или,
+// если у него включён второй фактор, выдаёт вызов, который завершается кодом в /api/signin/totp
+func finishSignin(w http.ResponseWriter, r *http.Request, cfg *config.JWTConfig, attempt *services.SigninAttempt, user int64, subject string) {
+	enabled, err := services.TOTPEnabled(user)
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to check password"})
+		return
+	}
+	if enabled {
+		challenge, err := services.StartChallenge(user, subject, attempt, time.Now())
+		if err != nil {
+			response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to start sign-in challenge"})
+			return
+		}
+		response(w, http.StatusOK, challenge)
+		return
+	}
+
+	attempt.Complete()
+	tokens, err := services.IssueToken(cfg, user, subject)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Failed to sign JWT"})
+		return
+	}
+
+	sendTokens(w, r, cfg, tokens)
+}
+
+// startSignin учитывает попытку входа в учётную запись account с адреса клиента.
+// Если вход временно запрещён, отвечает 429 с заголовком Retry-After и возвращает false
+func startSignin(w http.ResponseWriter, r *http.Request, account string) (*services.SigninAttempt, bool) {
+	attempt, retryAfter, err := services.BeginSignin(r, account)
+	switch {
+	case errors.Is(err, services.ErrSigninLocked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+		response(w, http.StatusTooManyRequests, models.JWTTokenResponse{Error: err.Error()})
+		return nil, false
+	case err != nil:
+		response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to check password"})
+		return nil, false
+	}
+	return attempt, true
+}
+
+// Куки с токеном доступа и refresh-токеном. Refresh-токен недоступен скриптам страницы
+// и отправляется браузером только на адрес его обмена
+const (
+	cookieToken   = "token"
+	cookieRefresh = "refresh_token"
+	refreshPath   = "/api/token/refresh"
+)
+
+// RefreshTokenHandler обрабатывает POST запрос обмена refresh-токена на новую пару токенов.
+// Refresh-токен передаётся в теле запроса или в куке refresh_token и действует один раз
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Invalid request body"})
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		if cookie, err := r.Cookie(cookieRefresh); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
+	if req.RefreshToken == "" {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Missing refresh token"})
+		return
+	}
+
+	cfg := config.LoadJWTConfig()
+	tokens, err := services.RefreshToken(cfg, req.RefreshToken)
+	switch {
+	case errors.Is(err, database.ErrSessionNotFound), errors.Is(err, database.ErrRefreshReused):
+		clearSessionCookies(w, r)
+		response(w, http.StatusUnauthorized, models.JWTTokenResponse{Error: "Session has expired, please re-authenticate"})
+		return
+	case err != nil:
+		response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to refresh token"})
 		return
 	}
 
-	response(w, http.StatusOK, models.JWTTokenResponse{Token: signedToken})
+	sendTokens(w, r, cfg, tokens)
+}
+
+// LogoutHandler обрабатывает POST запрос выхода: сессия закрывается, а её токены
+// доступа и refresh-токены перестают действовать
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if session := services.SessionID(r.Context()); session != "" {
+		if err := database.DeleteSession(session); err != nil {
+			response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to close session"})
+			return
+		}
+	}
+
+	clearSessionCookies(w, r)
+	response(w, http.StatusOK, struct{}{})
+}
+
+// sendTokens отправляет токены в ответе и в куках
+func sendTokens(w http.ResponseWriter, r *http.Request, cfg *config.JWTConfig, tokens models.JWTTokenResponse) {
+	setSessionCookies(w, r, cfg, tokens)
+	response(w, http.StatusOK, tokens)
+}
+
+// setSessionCookies сохраняет токены в куках. Токены недоступны скриптам страницы,
+// а CSRF-токен, наоборот, читается веб-интерфейсом и отправляется в заголовке запросов.
+// По HTTPS куки помечаются Secure
+func setSessionCookies(w http.ResponseWriter, r *http.Request, cfg *config.JWTConfig, tokens models.JWTTokenResponse) {
+	maxAge := int(cfg.RefreshTTL / time.Second)
+	secure := secureCookies(r, cfg)
+	http.SetCookie(w, &http.Cookie{Name: cookieToken, Value: tokens.Token, Path: "/", MaxAge: maxAge,
+		HttpOnly: true, Secure: secure, SameSite: http.SameSiteLaxMode})
+	http.SetCookie(w, &http.Cookie{Name: cookieRefresh, Value: tokens.RefreshToken, Path: refreshPath, MaxAge: maxAge,
+		HttpOnly: true, Secure: secure, SameSite: http.SameSiteStrictMode})
+	http.SetCookie(w, &http.Cookie{Name: services.CSRFCookie, Value: tokens.CSRFToken, Path: "/", MaxAge: maxAge,
+		Secure: secure, SameSite: http.SameSiteStrictMode})
+}
+
+// clearSessionCookies удаляет куки с токенами
+func clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	secure := secureCookies(r, config.LoadJWTConfig())
+	http.SetCookie(w, &http.Cookie{Name: cookieToken, Path: "/", MaxAge: -1, HttpOnly: true, Secure: secure})
+	http.SetCookie(w, &http.Cookie{Name: cookieRefresh, Path: refreshPath, MaxAge: -1, HttpOnly: true, Secure: secure})
+	http.SetCookie(w, &http.Cookie{Name: services.CSRFCookie, Path: "/", MaxAge: -1, Secure: secure})
+}
+
+// secureCookies сообщает, что куки нужно отправлять только по HTTPS
+func secureCookies(r *http.Request, cfg *config.JWTConfig) bool {
+	return cfg.SecureCookies || isHTTPS(r)
+}
+
+// isHTTPS сообщает, что запрос пришёл по HTTPS напрямую или через прокси. В отличие
+// от services.ClientIP здесь заголовку прокси доверяем: он влияет только на флаг Secure кук
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
 }
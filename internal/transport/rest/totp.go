@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// TOTPSigninHandler обрабатывает POST запрос второго шага входа: вызов, выданный
+// после проверки пароля, обменивается на токены сессии по коду TOTP или коду восстановления
+func TOTPSigninHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.TOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Invalid request body"})
+		return
+	}
+	if req.Challenge == "" {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Missing challenge"})
+		return
+	}
+
+	challenge, err := services.CompleteChallenge(req, time.Now())
+	switch {
+	case errors.Is(err, services.ErrChallenge), errors.Is(err, services.ErrInvalidCode), errors.Is(err, services.ErrTOTPNotEnrolled):
+		response(w, http.StatusUnauthorized, models.JWTTokenResponse{Error: err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to complete sign-in challenge: %v", err)
+		response(w, http.StatusInternalServerError, models.JWTTokenResponse{Error: "Failed to check code"})
+		return
+	}
+
+	cfg := config.LoadJWTConfig()
+	tokens, err := services.IssueToken(cfg, challenge.User, challenge.Subject)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.JWTTokenResponse{Error: "Failed to sign JWT"})
+		return
+	}
+
+	sendTokens(w, r, cfg, tokens)
+}
+
+// TOTPStatusHandler обрабатывает GET запрос состояния второго фактора текущего пользователя
+func TOTPStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user := services.UserID(r.Context())
+	enabled, err := services.TOTPEnabled(user)
+	if err != nil {
+		response(w, http.StatusInternalServerError, models.TOTPStatus{Error: "Failed to get two-factor status"})
+		return
+	}
+
+	status := models.TOTPStatus{Enabled: enabled}
+	if enabled {
+		if status.RecoveryCodes, err = database.CountRecoveryCodes(user); err != nil {
+			response(w, http.StatusInternalServerError, models.TOTPStatus{Error: "Failed to get two-factor status"})
+			return
+		}
+	}
+	response(w, http.StatusOK, status)
+}
+
+// EnrollTOTPHandler обрабатывает POST запрос подключения второго фактора: возвращает
+// секрет и URI otpauth:// для QR-кода. Второй фактор включается после подтверждения кодом
+func EnrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	if !passwordSession(w, r) {
+		return
+	}
+
+	enrollment, err := services.EnrollTOTP(services.CurrentUser(r.Context()), time.Now())
+	if err != nil {
+		totpError(w, err)
+		return
+	}
+	response(w, http.StatusOK, enrollment)
+}
+
+// ConfirmTOTPHandler обрабатывает POST запрос подтверждения второго фактора кодом
+// из приложения-аутентификатора. Коды восстановления возвращаются только в этом ответе
+func ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := totpRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := services.ConfirmTOTP(services.UserID(r.Context()), req.Code, time.Now())
+	if err != nil {
+		totpError(w, err)
+		return
+	}
+	response(w, http.StatusOK, models.RecoveryCodesResponse{Codes: codes})
+}
+
+// DisableTOTPHandler обрабатывает POST запрос отключения второго фактора по коду или коду восстановления
+func DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := totpRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := services.DisableTOTP(services.UserID(r.Context()), req, time.Now()); err != nil {
+		totpError(w, err)
+		return
+	}
+	response(w, http.StatusOK, struct{}{})
+}
+
+// RecoveryCodesHandler обрабатывает POST запрос замены кодов восстановления
+func RecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := totpRequest(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(services.UserID(r.Context()), req, time.Now())
+	if err != nil {
+		totpError(w, err)
+		return
+	}
+	response(w, http.StatusOK, models.RecoveryCodesResponse{Codes: codes})
+}
+
+// passwordSession пропускает только запросы сессии, открытой входом: ключ API
+// не может управлять вторым фактором своего владельца
+func passwordSession(w http.ResponseWriter, r *http.Request) bool {
+	if services.SessionID(r.Context()) == "" {
+		response(w, http.StatusForbidden, models.TOTPStatus{Error: "Sign in with password to manage two-factor authentication"})
+		return false
+	}
+	return true
+}
+
+// totpRequest читает код второго фактора из тела запроса сессии, открытой входом
+func totpRequest(w http.ResponseWriter, r *http.Request) (models.TOTPRequest, bool) {
+	var req models.TOTPRequest
+	if !passwordSession(w, r) {
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response(w, http.StatusBadRequest, models.TOTPStatus{Error: "JSON deserialization error"})
+		return req, false
+	}
+	return req, true
+}
+
+// totpError отвечает на ошибку управления вторым фактором
+func totpError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCode):
+		response(w, http.StatusUnauthorized, models.TOTPStatus{Error: err.Error()})
+	case errors.Is(err, services.ErrTOTPEnabled):
+		response(w, http.StatusConflict, models.TOTPStatus{Error: err.Error()})
+	case errors.Is(err, services.ErrTOTPNotEnrolled):
+		response(w, http.StatusBadRequest, models.TOTPStatus{Error: err.Error()})
+	default:
+		log.Printf("Two-factor authentication error: %v", err)
+		response(w, http.StatusInternalServerError, models.TOTPStatus{Error: "Failed to update two-factor authentication"})
+	}
+}
@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// SignupHandler обрабатывает POST запрос для самостоятельной регистрации пользователя.
+// Регистрация доступна, если она разрешена параметром TODO_REGISTRATION
+func SignupHandler(w http.ResponseWriter, r *http.Request) {
+	if !config.LoadJWTConfig().Registration {
+		response(w, http.StatusForbidden, models.UserResponse{Error: "Registration is closed"})
+		return
+	}
+
+	var user models.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	createUser(w, user.Login, user.Password, models.RoleUser)
+}
+
+// CreateUserHandler обрабатывает POST запрос администратора для создания учётной записи
+func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	createUser(w, user.Login, user.Password, user.Role)
+}
+
+// createUser создаёт учётную запись и отправляет её в ответе
+func createUser(w http.ResponseWriter, login, password, role string) {
+	user, err := services.RegisterUser(login, password, role)
+	switch {
+	case err == nil:
+		response(w, http.StatusOK, models.UserResponse{User: user})
+	case errors.Is(err, database.ErrLoginTaken):
+		response(w, http.StatusConflict, models.UserResponse{Error: "Login already taken"})
+	case errors.Is(err, services.ErrLoginFormat), errors.Is(err, services.ErrPasswordTooShort), errors.Is(err, services.ErrRole):
+		response(w, http.StatusBadRequest, models.UserResponse{Error: err.Error()})
+	default:
+		response(w, http.StatusInternalServerError, models.UserResponse{Error: "Failed to create user"})
+	}
+}
+
+// CurrentUserHandler обрабатывает GET запрос для вывода учётной записи вызывающей стороны
+func CurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	response(w, http.StatusOK, services.CurrentUser(r.Context()))
+}
+
+// GetUsersHandler обрабатывает GET запрос администратора для вывода списка учётных записей
+func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := database.GetUsers()
+	if err != nil {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "error getting user list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"users": users})
+}
+
+// DeleteUserHandler обрабатывает DELETE запрос администратора для удаления учётной записи
+// вместе с её задачами. Учётную запись администратора по умолчанию удалить нельзя
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "Invalid ID"})
+		return
+	}
+	if id == models.AdminID {
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "Administrator account cannot be deleted"})
+		return
+	}
+
+	if err := database.DeleteUser(id); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			response(w, http.StatusNotFound, models.UserResponse{Error: "User not found"})
+			return
+		}
+		response(w, http.StatusBadRequest, models.UserResponse{Error: "Failed to delete user"})
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+)
+
+// deliveryLogLimit — количество последних доставок в журнале
+const deliveryLogLimit = 100
+
+// CreateWebhookHandler обрабатывает POST запрос для регистрации webhook.
+// Секрет для проверки подписи возвращается только в ответе на этот запрос
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var hook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "JSON deserialization error"})
+		return
+	}
+
+	target, err := url.Parse(hook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Invalid webhook URL"})
+		return
+	}
+	if err := services.CheckWebhookHost(config.LoadWebhookConfig(), target.Hostname()); err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: err.Error()})
+		return
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(services.WebhookEvents, event) {
+			response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Unknown event: " + event})
+			return
+		}
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+
+	if hook.Secret, err = services.RandomToken(32); err != nil {
+		response(w, http.StatusInternalServerError, models.WebhookResponse{Error: "Failed to generate webhook secret"})
+		return
+	}
+
+	hook.Owner = services.UserID(r.Context())
+	id, err := database.AddWebhook(hook)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Failed to create webhook"})
+		return
+	}
+
+	hook.ID = strconv.Itoa(id)
+	response(w, http.StatusOK, models.WebhookResponse{Webhook: hook})
+}
+
+// GetWebhooksHandler обрабатывает GET запрос для вывода списка webhook
+func GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	hooks, err := database.GetWebhooks(services.UserID(r.Context()))
+	if err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "error getting webhook list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+// DeleteWebhookHandler обрабатывает DELETE запрос для удаления webhook
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Invalid ID"})
+		return
+	}
+
+	if err := database.DeleteWebhook(services.UserID(r.Context()), id); err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Failed to delete webhook"})
+		return
+	}
+
+	response(w, http.StatusOK, struct{}{})
+}
+
+// WebhookDeliveriesHandler обрабатывает GET запрос для вывода журнала доставок webhook
+func WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "Invalid ID"})
+		return
+	}
+
+	deliveries, err := database.GetDeliveries(services.UserID(r.Context()), id, deliveryLogLimit)
+	if err != nil {
+		response(w, http.StatusBadRequest, models.WebhookResponse{Error: "error getting delivery list"})
+		return
+	}
+
+	response(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
@@ -6,8 +6,11 @@ import (
 	"os"
 
 	"todo-rest/internal/config"
+	"todo-rest/internal/models"
 	"todo-rest/internal/services"
+	"todo-rest/internal/transport/caldav"
 	"todo-rest/internal/transport/rest"
+	"todo-rest/internal/transport/ws"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -27,21 +30,83 @@ func StartServer(port string) {
 
 	cfg := config.LoadJWTConfig()
 
+	// Ограничиваем частоту запросов каждого клиента
+	r.Use(services.NewRateLimiter(config.LoadRateLimitConfig(), cfg).Middleware)
+
 	// Настраиваем файловый сервер для каталога ./web
 	r.Handle("/*", http.StripPrefix("/", http.FileServer(http.Dir("./web"))))
 
 	// Регистрация обработчика для API
 	r.Post("/api/signin", rest.TokenHandler)
+	r.Post("/api/signup", rest.SignupHandler)
+	r.Post("/api/signin/totp", rest.TOTPSigninHandler)
+	r.Get("/api/oidc/login", rest.OIDCLoginHandler)
+	r.Get("/api/oidc/callback", rest.OIDCCallbackHandler)
+	r.Post("/api/token/refresh", rest.RefreshTokenHandler)
+	r.Post("/api/logout", services.Auth(cfg, rest.LogoutHandler))
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/nextdate", rest.NextDateHandler)
-		r.Post("/task", services.Auth(cfg, rest.CreateTaskHandler))
-		r.Get("/task", services.Auth(cfg, rest.GetTaskIdHandler))
-		r.Put("/task", services.Auth(cfg, rest.UpdateTaskHandler))
-		r.Delete("/task", services.Auth(cfg, rest.DeleteTaskHandler))
-		r.Post("/task/done", services.Auth(cfg, rest.DoneTaskHandler))
-		r.Get("/tasks", services.Auth(cfg, rest.GetTasksListHandler))
+		r.Post("/task", services.Authorize(cfg, models.ListEditor, rest.CreateTaskHandler))
+		r.Get("/task", services.Authorize(cfg, models.ListViewer, rest.GetTaskIdHandler))
+		r.Put("/task", services.Authorize(cfg, models.ListEditor, rest.UpdateTaskHandler))
+		r.Delete("/task", services.Authorize(cfg, models.ListEditor, rest.DeleteTaskHandler))
+		r.Post("/task/done", services.Authorize(cfg, models.ListEditor, rest.DoneTaskHandler))
+		r.Post("/task/snooze", services.Authorize(cfg, models.ListEditor, rest.SnoozeTaskHandler))
+		r.Post("/task/postpone", services.Authorize(cfg, models.ListEditor, rest.PostponeTaskHandler))
+		r.Get("/tasks", services.Authorize(cfg, models.ListViewer, rest.GetTasksListHandler))
+		r.Get("/tasks/overdue", services.Authorize(cfg, models.ListViewer, rest.OverdueTasksHandler))
+		r.Get("/events", services.Auth(cfg, rest.EventsHandler))
+		r.Get("/ws", services.Auth(cfg, ws.Handler))
+		r.Get("/task/history", services.Authorize(cfg, models.ListViewer, rest.TaskHistoryHandler))
+		r.Post("/task/revert", services.Authorize(cfg, models.ListEditor, rest.RevertTaskHandler))
+		r.Get("/export", services.Authorize(cfg, models.ListViewer, rest.ExportHandler))
+		r.Post("/import", services.Authorize(cfg, models.ListEditor, rest.ImportHandler))
+		r.Post("/import/ics", services.Authorize(cfg, models.ListEditor, rest.ImportCalendarHandler))
+		r.Get("/export/csv", services.Authorize(cfg, models.ListViewer, rest.ExportCSVHandler))
+		r.Post("/import/csv", services.Authorize(cfg, models.ListEditor, rest.ImportCSVHandler))
+		r.Get("/export/todotxt", services.Authorize(cfg, models.ListViewer, rest.ExportTodoTxtHandler))
+		r.Post("/import/todotxt", services.Authorize(cfg, models.ListEditor, rest.ImportTodoTxtHandler))
+		r.Get("/backup", services.Admin(cfg, rest.BackupHandler))
+		r.Post("/restore", services.Admin(cfg, rest.RestoreHandler))
+		r.Get("/user", services.Auth(cfg, rest.CurrentUserHandler))
+		r.Post("/users", services.Admin(cfg, rest.CreateUserHandler))
+		r.Get("/users", services.Admin(cfg, rest.GetUsersHandler))
+		r.Delete("/users", services.Admin(cfg, rest.DeleteUserHandler))
+		r.Get("/signin/failures", services.Admin(cfg, rest.SigninFailuresHandler))
+		r.Delete("/signin/lockouts", services.Admin(cfg, rest.ClearLockoutsHandler))
+		r.Post("/lists", services.Auth(cfg, rest.CreateListHandler))
+		r.Get("/lists", services.Auth(cfg, rest.GetListsHandler))
+		r.Delete("/lists", services.Authorize(cfg, models.ListOwner, rest.DeleteListHandler))
+		r.Get("/lists/members", services.Authorize(cfg, models.ListViewer, rest.GetListMembersHandler))
+		r.Delete("/lists/members", services.Authorize(cfg, models.ListViewer, rest.RemoveListMemberHandler))
+		r.Post("/lists/invitations", services.Authorize(cfg, models.ListOwner, rest.InviteHandler))
+		r.Get("/lists/invitations", services.Authorize(cfg, models.ListOwner, rest.GetListInvitationsHandler))
+		r.Delete("/lists/invitations", services.Authorize(cfg, models.ListOwner, rest.CancelInvitationHandler))
+		r.Get("/invitations", services.Auth(cfg, rest.GetInvitationsHandler))
+		r.Post("/invitations/accept", services.Auth(cfg, rest.AcceptInvitationHandler))
+		r.Delete("/invitations", services.Auth(cfg, rest.DeclineInvitationHandler))
+		r.Get("/totp", services.Account(cfg, rest.TOTPStatusHandler))
+		r.Post("/totp/enroll", services.Account(cfg, rest.EnrollTOTPHandler))
+		r.Post("/totp/confirm", services.Account(cfg, rest.ConfirmTOTPHandler))
+		r.Post("/totp/disable", services.Account(cfg, rest.DisableTOTPHandler))
+		r.Post("/totp/recovery-codes", services.Account(cfg, rest.RecoveryCodesHandler))
+		r.Post("/keys", services.Account(cfg, rest.CreateAPIKeyHandler))
+		r.Get("/keys", services.Account(cfg, rest.GetAPIKeysHandler))
+		r.Delete("/keys", services.Account(cfg, rest.DeleteAPIKeyHandler))
+		r.Post("/feeds", services.Account(cfg, rest.CreateFeedHandler))
+		r.Get("/feeds", services.Account(cfg, rest.GetFeedsHandler))
+		r.Delete("/feeds", services.Account(cfg, rest.DeleteFeedHandler))
+		r.Post("/webhooks", services.Account(cfg, rest.CreateWebhookHandler))
+		r.Get("/webhooks", services.Account(cfg, rest.GetWebhooksHandler))
+		r.Delete("/webhooks", services.Account(cfg, rest.DeleteWebhookHandler))
+		r.Get("/webhooks/deliveries", services.Account(cfg, rest.WebhookDeliveriesHandler))
+		r.Get("/notifications", services.Auth(cfg, rest.NotificationsHandler))
+		r.Get("/feed.ics", rest.CalendarFeedHandler)
 	})
 
+	// Сервер CalDAV для синхронизации задач с приложениями-планировщиками
+	caldav.Register(r, cfg)
+
 	log.Printf("Server is running on port: %s\n", port)
 	err := http.ListenAndServe(":"+port, r)
 	if err != nil {
@@ -0,0 +1,313 @@
+// Package ws реализует WebSocket API: клиенты выполняют те же операции с задачами,
+// что и через REST, и получают уведомления об изменениях по тому же соединению
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/gorilla/websocket"
+)
+
+// Операции, которые принимает сервер
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpDone   = "done"
+	OpList   = "list"
+	OpGet    = "get"
+)
+
+const (
+	// writeWait — время на отправку одного сообщения
+	writeWait = 10 * time.Second
+	// pongWait — время ожидания ответа на ping, после которого соединение закрывается
+	pongWait = 60 * time.Second
+	// pingPeriod — период отправки ping, меньше pongWait
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize ограничивает размер сообщения клиента
+	maxMessageSize = 64 << 10
+	// sendBufferSize — размер очереди исходящих сообщений соединения
+	sendBufferSize = 64
+)
+
+// upgrader по умолчанию принимает соединения только со страниц того же источника
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// Handler обрабатывает GET запрос на установку WebSocket соединения
+func Handler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже отправил клиенту ответ с ошибкой
+		return
+	}
+
+	_, events, cancel := services.Events.Subscribe(services.UserID(r.Context()), 0, false)
+	defer cancel()
+
+	// Соединение закрывается, когда сессия закрыта, токен доступа истёк или ключ API отозван
+	ctx, stop := context.WithCancel(r.Context())
+	defer stop()
+	revoked := services.WatchCredentials(ctx)
+
+	send := make(chan models.WSMessage, sendBufferSize)
+	done := make(chan struct{})
+	go writeLoop(conn, send, events, revoked, done)
+
+	conn.SetReadLimit(maxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read failed: %v", err)
+			}
+			break
+		}
+
+		var req models.WSRequest
+		var msg models.WSMessage
+		closing := false
+		if err := json.Unmarshal(data, &req); err != nil {
+			msg = failure(http.StatusBadRequest, "JSON deserialization error")
+		} else {
+			// Учётные данные проверяются перед каждой операцией, а не только при подключении
+			switch err := services.CheckCredentials(r.Context(), time.Now()); {
+			case errors.Is(err, services.ErrCredentialsRevoked):
+				msg = failure(http.StatusUnauthorized, "Session has expired, please re-authenticate")
+				closing = true
+			case err != nil:
+				log.Printf("WebSocket credentials check failed: %v", err)
+				msg = failure(http.StatusInternalServerError, "Failed to check credentials")
+			default:
+				// Операции учитываются в ограничении частоты так же, как запросы REST того же клиента
+				if res := services.AllowOperation(r.Context(), opClass(req.Op), time.Now()); !res.Allowed {
+					msg = failure(http.StatusTooManyRequests, "Too many requests, please retry later")
+					msg.RetryAfter = int(res.RetryAfter / time.Second)
+				} else {
+					msg = handle(r, req)
+				}
+			}
+			msg.ID = req.ID
+			msg.Op = req.Op
+		}
+		msg.Type = models.MessageResult
+
+		select {
+		case send <- msg:
+		case <-done:
+		}
+		if closing {
+			break
+		}
+	}
+
+	close(send)
+	<-done
+}
+
+// writeLoop отправляет ответы, уведомления и ping. Запись в соединение выполняется
+// только здесь, так как gorilla/websocket не допускает одновременной записи
+func writeLoop(conn *websocket.Conn, send <-chan models.WSMessage, events <-chan models.Event, revoked <-chan struct{}, done chan<- struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+		close(done)
+	}()
+
+	write := func(msg models.WSMessage) error {
+		_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(msg)
+	}
+
+	for {
+		select {
+		case msg, ok := <-send:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+				return
+			}
+			if err := write(msg); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				// Клиент не успевает читать уведомления, он должен переподключиться и перечитать задачи
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(writeWait))
+				return
+			}
+			if err := write(models.WSMessage{Type: models.MessageEvent, Event: &event}); err != nil {
+				return
+			}
+		case <-revoked:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session expired"), time.Now().Add(writeWait))
+			return
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// opClass возвращает класс операции для ограничения частоты: чтение или изменение задач
+func opClass(op string) string {
+	if op == OpList || op == OpGet {
+		return services.RateRead
+	}
+	return services.RateWrite
+}
+
+// handle выполняет операцию и формирует ответ на неё
+func handle(r *http.Request, req models.WSRequest) models.WSMessage {
+	// Чтение задач общего списка доступно всем его участникам, изменение — редакторам и владельцам
+	need, scope := models.ListEditor, models.ScopeTasksWrite
+	if req.Op == OpList || req.Op == OpGet {
+		need, scope = models.ListViewer, models.ScopeRead
+	}
+	// Ключ API только для чтения не позволяет изменять задачи через WebSocket
+	if !services.HasScope(r.Context(), scope) {
+		return failure(http.StatusForbidden, "API key scope does not allow this operation")
+	}
+	ctx, err := services.ListAccess(r.Context(), req.List, need)
+	switch {
+	case errors.Is(err, database.ErrListNotFound):
+		return failure(http.StatusNotFound, "List not found")
+	case errors.Is(err, services.ErrListForbidden):
+		return failure(http.StatusForbidden, "Insufficient list role")
+	case err != nil:
+		log.Printf("WebSocket list check failed: %v", err)
+		return failure(http.StatusInternalServerError, "Failed to check list access")
+	}
+	now := time.Now()
+
+	switch req.Op {
+	case OpList:
+		tasks, err := database.GetTasks(services.TaskScope(ctx), services.SearchFilter(req.Search))
+		if err != nil {
+			return failure(http.StatusBadRequest, "error getting task list")
+		}
+		services.MarkOverdue(tasks, now)
+		return models.WSMessage{Status: http.StatusOK, Tasks: tasks}
+
+	case OpGet:
+		task, err := database.GetTask(services.TaskScope(ctx), req.TaskID)
+		if err != nil {
+			return txFailure(err, "Failed to get task")
+		}
+		task.Overdue = services.IsOverdue(task, now)
+		return taskResult(task)
+
+	case OpCreate:
+		if req.Task == nil {
+			return failure(http.StatusBadRequest, "Missing task")
+		}
+		task := *req.Task
+		task.ID = ""
+		policy, err := services.OverduePolicy(req.Overdue)
+		if err != nil {
+			return failure(http.StatusBadRequest, err.Error())
+		}
+		if err := services.ValidateTask(&task, now, policy); err != nil {
+			return failure(http.StatusBadRequest, err.Error())
+		}
+		task, err = services.CreateTask(ctx, task)
+		if err != nil {
+			return txFailure(err, "Failed to create task")
+		}
+		return taskResult(task)
+
+	case OpUpdate:
+		if req.Task == nil {
+			return failure(http.StatusBadRequest, "Missing task")
+		}
+		task := *req.Task
+		if task.ID == "" {
+			task.ID = req.TaskID
+		}
+		if _, err := strconv.Atoi(task.ID); err != nil {
+			return failure(http.StatusBadRequest, "Invalid ID")
+		}
+		policy, err := services.OverduePolicy(req.Overdue)
+		if err != nil {
+			return failure(http.StatusBadRequest, err.Error())
+		}
+		if err := services.ValidateTask(&task, now, policy); err != nil {
+			return failure(http.StatusBadRequest, err.Error())
+		}
+		task, err = services.UpdateTask(ctx, task, services.IfMatch(req.IfMatch))
+		if err != nil {
+			return txFailure(err, "Failed to update task")
+		}
+		return taskResult(task)
+
+	case OpDelete:
+		if _, err := strconv.Atoi(req.TaskID); err != nil {
+			return failure(http.StatusBadRequest, "Invalid ID")
+		}
+		if err := services.DeleteTask(ctx, req.TaskID, services.IfMatch(req.IfMatch)); err != nil {
+			return txFailure(err, "Failed to delete task")
+		}
+		return models.WSMessage{Status: http.StatusOK}
+
+	case OpDone:
+		task, err := services.CompleteTask(ctx, req.TaskID, now, services.IfMatch(req.IfMatch))
+		if err != nil {
+			return txFailure(err, "Failed to update task")
+		}
+		if task == nil {
+			return models.WSMessage{Status: http.StatusOK}
+		}
+		return taskResult(*task)
+
+	default:
+		return failure(http.StatusBadRequest, "Unknown operation")
+	}
+}
+
+// taskResult формирует успешный ответ с задачей и её ETag
+func taskResult(task models.Task) models.WSMessage {
+	return models.WSMessage{Status: http.StatusOK, Task: &task, ETag: services.ETag(task.Version)}
+}
+
+// failure формирует ответ с ошибкой
+func failure(status int, message string) models.WSMessage {
+	return models.WSMessage{Status: status, Error: message}
+}
+
+// txFailure формирует ответ на ошибку чтения или изменения задачи
+func txFailure(err error, message string) models.WSMessage {
+	switch {
+	case errors.Is(err, database.ErrTaskNotFound):
+		return failure(http.StatusNotFound, "Task not found")
+	case errors.Is(err, services.ErrPreconditionFailed), errors.Is(err, database.ErrVersionConflict):
+		return failure(http.StatusPreconditionFailed, "Task has been modified")
+	case errors.Is(err, services.ErrRepeatFormat):
+		return failure(http.StatusInternalServerError, services.ErrRepeatFormat.Error())
+	default:
+		log.Printf("WebSocket operation failed: %v", err)
+		return failure(http.StatusBadRequest, message)
+	}
+}
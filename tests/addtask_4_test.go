@@ -47,6 +47,7 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 			},
 		})
 		client.Jar = jar
+		setCSRF(req, Token)
 	}
 
 	resp, err = client.Do(req)
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bearerRequest выполняет запрос с ключом API или токеном в заголовке Authorization
+func bearerRequest(t *testing.T, bearer, method, apipath string, values map[string]any) (int, []byte) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+bearer)
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, body
+}
+
+func TestAPIKeys(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("authentication is disabled")
+	}
+
+	login := "script" + fmt.Sprint(time.Now().UnixNano())
+	status, body := userRequest(t, Token, http.MethodPost, "api/users", map[string]any{"login": login, "password": "secret-" + login})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var user map[string]any
+	assert.NoError(t, json.Unmarshal(body, &user))
+	defer userRequest(t, Token, http.MethodDelete, fmt.Sprintf("api/users?id=%.0f", user["id"].(float64)), nil)
+	token := signinUser(t, login, "secret-"+login)
+
+	// Токен доступа принимается и в заголовке Authorization
+	status, body = bearerRequest(t, token, http.MethodGet, "api/user", nil)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	assert.Contains(t, string(body), login)
+
+	createKey := func(name string, scopes ...string) (int, map[string]any) {
+		status, body := userRequest(t, token, http.MethodPost, "api/keys", map[string]any{"name": name, "scopes": scopes})
+		var key map[string]any
+		assert.NoError(t, json.Unmarshal(body, &key))
+		return status, key
+	}
+
+	status, readKey := createKey("cron")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []any{"read"}, readKey["scopes"])
+	plain := readKey["key"].(string)
+	assert.Contains(t, plain, readKey["prefix"].(string))
+
+	status, writeKey := createKey("sync", "tasks:write")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Обычный пользователь не может выпустить ключ администратора, неизвестные области отклоняются
+	status, _ = createKey("root", "admin")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = createKey("odd", "everything")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = createKey("  ")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// Ключ только для чтения не изменяет задачи и не выпускает ключи шире себя
+	today := time.Now().Format(`20060102`)
+	status, _ = bearerRequest(t, plain, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = bearerRequest(t, plain, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"})
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, body = bearerRequest(t, writeKey["key"].(string), http.MethodPost, "api/task", map[string]any{"date": today, "title": "Из скрипта"})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	status, _ = bearerRequest(t, writeKey["key"].(string), http.MethodGet, "api/users", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Ключи без области admin не управляют учётной записью: ключами, webhook, лентами и вторым фактором
+	status, _ = bearerRequest(t, writeKey["key"].(string), http.MethodPost, "api/keys", map[string]any{"name": "more", "scopes": []string{"tasks:write"}})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = bearerRequest(t, writeKey["key"].(string), http.MethodPost, "api/webhooks", map[string]any{"url": "https://example.com/hook"})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = bearerRequest(t, writeKey["key"].(string), http.MethodPost, "api/totp/enroll", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = bearerRequest(t, plain, http.MethodGet, "api/keys", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Список ключей не раскрывает сами ключи и показывает время использования
+	status, body = userRequest(t, token, http.MethodGet, "api/keys", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, string(body), plain)
+	var list struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &list))
+	if assert.Len(t, list.Keys, 2) {
+		assert.Equal(t, "cron", list.Keys[0]["name"])
+		assert.NotEmpty(t, list.Keys[0]["last_used_at"])
+	}
+
+	// Отозванный ключ перестаёт действовать
+	status, _ = userRequest(t, token, http.MethodDelete, "api/keys?id="+readKey["id"].(string), nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = userRequest(t, token, http.MethodDelete, "api/keys?id="+readKey["id"].(string), nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = bearerRequest(t, plain, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Ключи администратора открывают административные запросы
+	status, body = userRequest(t, Token, http.MethodPost, "api/keys", map[string]any{"name": "ops", "scopes": []string{"admin"}})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var adminKey map[string]any
+	assert.NoError(t, json.Unmarshal(body, &adminKey))
+	defer userRequest(t, Token, http.MethodDelete, "api/keys?id="+adminKey["id"].(string), nil)
+	status, _ = bearerRequest(t, adminKey["key"].(string), http.MethodGet, "api/users", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = bearerRequest(t, adminKey["key"].(string), http.MethodGet, "api/keys", nil)
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-rest/internal/database"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	data, err := requestJSON("api/backup", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("SQLite format 3\x00")))
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+	assert.NoError(t, database.VerifyBackup(path))
+
+	// Задача, добавленная после копирования, пропадает после восстановления
+	id := addTask(t, task{
+		date:  time.Now().Format(`20060102`),
+		title: "Задача после резервной копии",
+	})
+
+	body, err := postRaw("api/restore", "application/vnd.sqlite3", string(data))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(body))
+	notFoundTask(t, id)
+
+	body, err = postRaw("api/restore", "application/vnd.sqlite3", "not a database")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "error")
+}
+
+func TestRotateBackup(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	backups := filepath.Join(dir, "backups")
+	for i := 0; i < 3; i++ {
+		path, err := database.RotateBackup(backups, 2)
+		assert.NoError(t, err)
+		assert.NoError(t, database.VerifyBackup(path))
+		// Имена копий содержат время с точностью до секунды
+		time.Sleep(time.Second)
+	}
+
+	entries, err := os.ReadDir(backups)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Error(t, database.VerifyBackup(filepath.Join(dir, "missing.db")))
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func davRequest(method, path, body string, headers map[string]string) (*http.Response, string, error) {
+	req, err := http.NewRequest(method, getURL(path), strings.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if len(Password) > 0 {
+		req.SetBasicAuth("admin", Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, string(data), err
+}
+
+func syncCollection(t *testing.T, token string) (string, string) {
+	resp, body, err := davRequest("REPORT", "caldav/tasks/", `<?xml version="1.0"?>
+<d:sync-collection xmlns:d="DAV:">
+  <d:sync-token>`+token+`</d:sync-token>
+  <d:sync-level>1</d:sync-level>
+  <d:prop><d:getetag/></d:prop>
+</d:sync-collection>`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+
+	m := regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>\s*</d:multistatus>`).FindStringSubmatch(body)
+	if !assert.NotNil(t, m, body) {
+		return "", body
+	}
+	return m[1], body
+}
+
+func TestCalDAV(t *testing.T) {
+	resp, body, err := davRequest("PROPFIND", "caldav/tasks/", `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:resourcetype/><c:supported-calendar-component-set/><d:sync-token/><d:quota-used-bytes/></d:prop>
+</d:propfind>`, map[string]string{"Depth": "0"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "<c:calendar/>")
+	assert.Contains(t, body, `<c:comp name="VTODO"/>`)
+	assert.Contains(t, body, "HTTP/1.1 404 Not Found")
+
+	if len(Password) > 0 {
+		req, err := http.NewRequest("PROPFIND", getURL("caldav/tasks/"), nil)
+		assert.NoError(t, err)
+		req.SetBasicAuth("admin", "wrong")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		// Общий пароль действует только для администратора, а не под любым именем
+		req, err = http.NewRequest("PROPFIND", getURL("caldav/tasks/"), nil)
+		assert.NoError(t, err)
+		req.SetBasicAuth("mallory", Password)
+		resp, err = http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	token, _ := syncCollection(t, "")
+
+	// Новая задача из приложения
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:phone-todo-42",
+		"SUMMARY:Задача из телефона",
+		"DUE;VALUE=DATE:" + date,
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,TH",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n") + "\r\n"
+	resp, _, err = davRequest(http.MethodPut, "caldav/tasks/phone-todo-42.ics", ics,
+		map[string]string{"If-None-Match": "*", "Content-Type": "text/calendar"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	tag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	resp, _, err = davRequest(http.MethodPut, "caldav/tasks/phone-todo-42.ics", ics,
+		map[string]string{"If-None-Match": "*", "Content-Type": "text/calendar"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, body, err = davRequest(http.MethodGet, "caldav/tasks/phone-todo-42.ics", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, tag, resp.Header.Get("ETag"))
+	assert.Contains(t, body, "UID:phone-todo-42")
+	assert.Contains(t, body, "SUMMARY:Задача из телефона")
+	assert.Contains(t, body, "RRULE:FREQ=WEEKLY;BYDAY=MO,TH")
+
+	newToken, body := syncCollection(t, token)
+	assert.NotEqual(t, token, newToken)
+	assert.Contains(t, body, "/caldav/tasks/phone-todo-42.ics")
+	assert.Contains(t, body, html.EscapeString(tag))
+
+	// Изменение с устаревшим ETag отклоняется
+	updated := strings.Replace(ics, "Задача из телефона", "Задача из телефона, изменённая", 1)
+	resp, _, err = davRequest(http.MethodPut, "caldav/tasks/phone-todo-42.ics", updated,
+		map[string]string{"If-Match": `"999"`, "Content-Type": "text/calendar"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _, err = davRequest(http.MethodPut, "caldav/tasks/phone-todo-42.ics", updated,
+		map[string]string{"If-Match": tag, "Content-Type": "text/calendar"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	newTag := resp.Header.Get("ETag")
+	assert.NotEqual(t, tag, newTag)
+
+	resp, body, err = davRequest("REPORT", "caldav/tasks/", `<?xml version="1.0"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <d:href>/caldav/tasks/phone-todo-42.ics</d:href>
+  <d:href>/caldav/tasks/missing.ics</d:href>
+</c:calendar-multiget>`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "изменённая")
+	assert.Contains(t, body, "HTTP/1.1 404 Not Found")
+
+	// Удаление отражается в синхронизации кодом 404
+	resp, _, err = davRequest(http.MethodDelete, "caldav/tasks/phone-todo-42.ics", "",
+		map[string]string{"If-Match": newTag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, _, err = davRequest(http.MethodGet, "caldav/tasks/phone-todo-42.ics", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, body = syncCollection(t, newToken)
+	assert.Regexp(t, `phone-todo-42\.ics</d:href><d:status>HTTP/1.1 404 Not Found`, body)
+
+	resp, _, err = davRequest("REPORT", "caldav/tasks/", `<?xml version="1.0"?>
+<d:sync-collection xmlns:d="DAV:"><d:sync-token>invalid</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	if len(Token) == 0 {
+		return
+	}
+
+	// Изменения задач другого пользователя не меняют токен синхронизации
+	token, _ = syncCollection(t, "")
+	login := "caldav" + fmt.Sprint(time.Now().UnixNano())
+	status, created := userRequest(t, Token, http.MethodPost, "api/users", map[string]any{"login": login, "password": "secret-" + login})
+	assert.Equal(t, http.StatusOK, status, string(created))
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	assert.NoError(t, json.Unmarshal(created, &user))
+	defer userRequest(t, Token, http.MethodDelete, fmt.Sprintf("api/users?id=%d", user.ID), nil)
+
+	other := signinUser(t, login, "secret-"+login)
+	status, _ = userRequest(t, other, http.MethodPost, "api/task", map[string]any{"date": date, "title": "Чужая задача"})
+	assert.Equal(t, http.StatusOK, status)
+	current, _ := syncCollection(t, "")
+	assert.Equal(t, token, current)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("authentication is disabled")
+	}
+
+	// Вход выдаёт куки сессии с атрибутами SameSite, а по HTTPS — Secure
+	data, _ := json.Marshal(map[string]string{"password": Password})
+	req, err := http.NewRequest(http.MethodPost, getURL("api/signin"), bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range resp.Cookies() {
+		cookies[cookie.Name] = cookie
+		assert.True(t, cookie.Secure, cookie.Name)
+	}
+	if !assert.Contains(t, cookies, "token") || !assert.Contains(t, cookies, "csrf_token") {
+		return
+	}
+	assert.True(t, cookies["token"].HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookies["token"].SameSite)
+	assert.True(t, cookies["refresh_token"].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies["refresh_token"].SameSite)
+	assert.False(t, cookies["csrf_token"].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookies["csrf_token"].SameSite)
+	token, csrf := cookies["token"].Value, cookies["csrf_token"].Value
+
+	task := map[string]any{"date": time.Now().Format(`20060102`), "title": "CSRF"}
+	send := func(method, path string, header map[string]string, bearer bool) int {
+		data, _ := json.Marshal(task)
+		req, err := http.NewRequest(method, getURL(path), bytes.NewReader(data))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if bearer {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else {
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Изменяющий запрос с токеном из куки без CSRF-токена отклоняется
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "api/task", nil, false))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "api/task", map[string]string{"X-CSRF-Token": "forged"}, false))
+	// CSRF-токен другой сессии не подходит
+	other, _ := csrfTokens.Load(Token)
+	assert.NotEqual(t, csrf, other)
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "api/task", map[string]string{"X-CSRF-Token": other.(string)}, false))
+
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "api/task", map[string]string{"X-CSRF-Token": csrf}, false))
+	// Чтение и запросы с токеном в заголовке Authorization CSRF-токена не требуют
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "api/tasks", nil, false))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "api/task", nil, true))
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "api/logout", nil, false))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "api/logout", map[string]string{"X-CSRF-Token": csrf}, false))
+}
@@ -16,6 +16,10 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+	Version int64  `db:"version"`
+	Anchor  string `db:"anchor"`
+	Owner   int64  `db:"owner"`
+	List    int64  `db:"list_id"`
 }
 
 func count(db *sqlx.DB) (int, error) {
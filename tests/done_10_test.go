@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentDone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Одновременные отметки выполнения",
+		repeat: "d 3",
+	})
+
+	const clicks = 10
+	var wg sync.WaitGroup
+	codes := make(chan int, clicks)
+	for i := 0; i < clicks; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _, err := requestWithHeaders("api/task/done?id="+id, nil, http.MethodPost, nil)
+			if assert.NoError(t, err) {
+				codes <- resp.StatusCode
+			}
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	// Каждая отметка переносит задачу ровно на одно повторение
+	var task Task
+	err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, 3*clicks).Format(`20060102`), task.Date)
+
+	done := 0
+	for _, entry := range getHistory(t, id) {
+		if entry.Action == "done" {
+			done++
+		}
+	}
+	assert.Equal(t, clicks, done)
+}
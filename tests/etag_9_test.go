@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWithHeaders(apipath string, values map[string]any, method string,
+	headers map[string]string) (*http.Response, []byte, error) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+		setCSRF(req, Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func TestETag(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	id := addTask(t, task{
+		date:   now,
+		title:  "Проверить ETag",
+		repeat: "d 2",
+	})
+
+	resp, _, err := requestWithHeaders("api/task?id="+id, nil, http.MethodGet, nil)
+	assert.NoError(t, err)
+	tag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	update := map[string]any{"id": id, "date": now, "title": "Проверить ETag ещё раз", "repeat": "d 2"}
+	resp, _, err = requestWithHeaders("api/task", update, http.MethodPut,
+		map[string]string{"If-Match": tag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	newTag := resp.Header.Get("ETag")
+	assert.NotEqual(t, tag, newTag)
+
+	// Слабый тег не подходит для условия If-Match
+	resp, _, err = requestWithHeaders("api/task", update, http.MethodPut,
+		map[string]string{"If-Match": "W/" + newTag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	// Устаревшая версия задачи
+	resp, _, err = requestWithHeaders("api/task", update, http.MethodPut,
+		map[string]string{"If-Match": tag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _, err = requestWithHeaders("api/task/done?id="+id, nil, http.MethodPost,
+		map[string]string{"If-Match": tag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _, err = requestWithHeaders("api/task?id="+id, nil, http.MethodDelete,
+		map[string]string{"If-Match": tag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, _, err = requestWithHeaders("api/task?id="+id, nil, http.MethodDelete,
+		map[string]string{"If-Match": newTag})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	notFoundTask(t, id)
+}
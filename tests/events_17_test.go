@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sseEvent struct {
+	ID     int64  `json:"id"`
+	Type   string `json:"type"`
+	TaskID string `json:"task_id"`
+}
+
+// openEvents подключается к потоку событий и передаёт прочитанные события в канал
+func openEvents(t *testing.T, lastID string) (<-chan sseEvent, func()) {
+	req, err := http.NewRequest(http.MethodGet, getURL("api/events"), nil)
+	assert.NoError(t, err)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok {
+				continue
+			}
+			var event sseEvent
+			if json.Unmarshal([]byte(data), &event) == nil {
+				events <- event
+			}
+		}
+	}()
+
+	return events, func() { resp.Body.Close() }
+}
+
+// waitEvent ждёт событие указанного типа для задачи
+func waitEvent(t *testing.T, events <-chan sseEvent, typ, id string) sseEvent {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("Поток событий закрыт до события %s для задачи %s", typ, id)
+			}
+			if event.Type == typ && event.TaskID == id {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Не получено событие %s для задачи %s", typ, id)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	events, closeEvents := openEvents(t, "")
+
+	id := addTask(t, task{
+		date:  time.Now().Format(`20060102`),
+		title: "Проверить события",
+	})
+	created := waitEvent(t, events, "created", id)
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	done := waitEvent(t, events, "done", id)
+	assert.Greater(t, done.ID, created.ID)
+	closeEvents()
+
+	// После переподключения повторяются события, пропущенные клиентом
+	events, closeEvents = openEvents(t, strconv.FormatInt(created.ID, 10))
+	defer closeEvents()
+	replayed := waitEvent(t, events, "done", id)
+	assert.Equal(t, done.ID, replayed.ID)
+}
+
+func TestEventsExpired(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := &config.JWTConfig{Password: "password", Secret: "secret", AccessTTL: time.Second, RefreshTTL: time.Hour}
+	server := httptest.NewServer(services.Auth(cfg, rest.EventsHandler))
+	defer server.Close()
+
+	tokens, err := services.IssueToken(cfg, models.AdminID, "")
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Поток завершается сервером, когда истекает срок действия токена доступа
+	ended := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		close(ended)
+	}()
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Поток событий не завершён после истечения токена")
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type exportDoc struct {
+	Version int              `json:"version"`
+	Tasks   []map[string]any `json:"tasks"`
+}
+
+type importResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Deleted int              `json:"deleted"`
+	Errors  []map[string]any `json:"errors"`
+}
+
+func exportTasks(t *testing.T) exportDoc {
+	body, err := requestJSON("api/export", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var doc exportDoc
+	assert.NoError(t, json.Unmarshal(body, &doc))
+	return doc
+}
+
+func importTasks(t *testing.T, mode string, doc map[string]any) importResult {
+	body, err := requestJSON("api/import?mode="+mode, doc, http.MethodPost)
+	assert.NoError(t, err)
+	var res importResult
+	assert.NoError(t, json.Unmarshal(body, &res))
+	return res
+}
+
+func TestExportImport(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now().Format(`20060102`)
+	id := addTask(t, task{
+		date:   now,
+		title:  "Выгрузить задачи",
+		repeat: "d 7",
+	})
+
+	doc := exportTasks(t)
+	assert.Equal(t, 1, doc.Version)
+	found := false
+	for _, v := range doc.Tasks {
+		if v["id"] == id {
+			found = true
+			assert.Equal(t, "d 7", v["repeat"])
+		}
+	}
+	assert.True(t, found)
+
+	res := importTasks(t, "merge", map[string]any{
+		"version": 1,
+		"tasks": []map[string]any{
+			{"id": id, "date": now, "title": "Загрузить задачи", "repeat": "d 7"},
+			{"date": now, "title": "Новая задача"},
+			{"date": now, "title": ""},
+			{"date": now, "title": "Ошибка", "repeat": "ooops"},
+		},
+	})
+	assert.Equal(t, 1, res.Created)
+	assert.Equal(t, 1, res.Updated)
+	if assert.Len(t, res.Errors, 2) {
+		assert.EqualValues(t, 3, res.Errors[0]["row"])
+		assert.EqualValues(t, 4, res.Errors[1]["row"])
+	}
+
+	var task Task
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Загрузить задачи", task.Title)
+
+	// Отложенная повторяющаяся задача выгружается вместе с датой по расписанию
+	later := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	_, err := requestJSON("api/task/postpone?id="+id+"&date="+later, nil, http.MethodPost)
+	assert.NoError(t, err)
+	found = false
+	for _, v := range exportTasks(t).Tasks {
+		if v["id"] == id {
+			found = true
+			assert.Equal(t, later, v["date"])
+			assert.Equal(t, now, v["anchor"])
+		}
+	}
+	assert.True(t, found)
+
+	res = importTasks(t, "merge", map[string]any{
+		"version": 1,
+		"tasks": []map[string]any{
+			{"date": later, "title": "Загруженная отложенная задача", "repeat": "d 7", "anchor": now},
+			{"date": later, "title": "Неверная дата по расписанию", "repeat": "d 7", "anchor": "ooops"},
+		},
+	})
+	assert.Equal(t, 1, res.Created)
+	if assert.Len(t, res.Errors, 1) {
+		assert.Equal(t, "Invalid anchor date", res.Errors[0]["error"])
+	}
+	var snoozed Task
+	assert.NoError(t, db.Get(&snoozed, `SELECT * FROM scheduler WHERE title=?`, "Загруженная отложенная задача"))
+	assert.Equal(t, now, snoozed.Anchor)
+
+	// Полная замена выгрузкой сохраняет все задачи и их идентификаторы
+	before, err := count(db)
+	assert.NoError(t, err)
+	full := exportTasks(t)
+	res = importTasks(t, "replace", map[string]any{"version": full.Version, "tasks": full.Tasks})
+	assert.Equal(t, before, res.Deleted)
+	assert.Equal(t, before, res.Created)
+	assert.Empty(t, res.Errors)
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, later, task.Date)
+	assert.Equal(t, now, task.Anchor)
+
+	ret, err := postJSON("api/import?mode=unknown", map[string]any{"version": 1}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestImportLimits(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	importDoc := func(query string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/import"+query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		rest.ImportHandler(rec, req)
+		return rec
+	}
+
+	// Тело больше 10 МБ отклоняется
+	large := `{"version": 1, "tasks": [], "padding": "` + strings.Repeat("x", 11<<20) + `"}`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, importDoc("", []byte(large)).Code)
+
+	// Прошедшие даты по умолчанию сохраняются и переносятся на сегодня только с overdue=clamp
+	doc, err := json.Marshal(models.ExportDocument{Version: models.ExportVersion, Tasks: []models.ExportTask{
+		{Date: "20200115", Title: "Историческая задача"},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, importDoc("", doc).Code)
+	assert.Equal(t, http.StatusOK, importDoc("?overdue=clamp", doc).Code)
+	assert.Equal(t, http.StatusBadRequest, importDoc("?overdue=later", doc).Code)
+
+	tasks, err := database.GetTasks(models.Scope{Owner: models.AdminID}, services.SearchFilter(""))
+	assert.NoError(t, err)
+	dates := []string{}
+	for _, task := range tasks {
+		dates = append(dates, task.Date)
+	}
+	assert.ElementsMatch(t, []string{"20200115", time.Now().Format(`20060102`)}, dates)
+}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTodoTxt(t *testing.T) {
+	task, err := services.ParseTodoTxt("(A) 2024-01-02 Позвонить маме +семья due:2024-03-05 rec:2w")
+	assert.NoError(t, err)
+	assert.Equal(t, "(A) Позвонить маме +семья", task.Title)
+	assert.Equal(t, "20240305", task.Date)
+	assert.Equal(t, "d 14", task.Repeat)
+
+	task, err = services.ParseTodoTxt("Оплатить аренду due:2024-03-05 rec:1m")
+	assert.NoError(t, err)
+	assert.Equal(t, "m 5", task.Repeat)
+
+	task, err = services.ParseTodoTxt("Сходить в спортзал repeat:w_1,3,5")
+	assert.NoError(t, err)
+	assert.Equal(t, "w 1,3,5", task.Repeat)
+
+	_, err = services.ParseTodoTxt("x 2024-01-03 Готово")
+	assert.ErrorIs(t, err, services.ErrTodoTxtCompleted)
+	_, err = services.ParseTodoTxt("Каждый рабочий день rec:1b")
+	assert.Error(t, err)
+
+	tbl := []struct {
+		task models.Task
+		line string
+	}{
+		{models.Task{Title: "(B) Полить цветы", Date: "20240305", Repeat: "d 3"}, "(B) Полить цветы due:2024-03-05 rec:3d"},
+		{models.Task{Title: "День рождения", Date: "20240305", Repeat: "y"}, "День рождения due:2024-03-05 rec:1y"},
+		{models.Task{Title: "Аренда", Date: "20240305", Repeat: "m 5"}, "Аренда due:2024-03-05 rec:1m"},
+		{models.Task{Title: "Спорт", Date: "20240305", Repeat: "w 1,3"}, "Спорт due:2024-03-05 repeat:w_1,3"},
+	}
+	for _, v := range tbl {
+		assert.Equal(t, v.line, services.FormatTodoTxt(v.task))
+	}
+}
+
+func TestCSVImportExport(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 1)
+	csv := "Задача,Срок,Заметки\n" +
+		"Купить молоко из CSV," + date.Format("2006-01-02") + ",\"2 литра, обезжиренное\"\n" +
+		"," + date.Format("20060102") + ",без названия\n" +
+		"Неверная дата из CSV,31.02.2024,\n"
+
+	body, err := postRaw("api/import/csv?columns=title:Задача,date:Срок,comment:Заметки", "text/csv", csv)
+	assert.NoError(t, err)
+	var res importResult
+	assert.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, 1, res.Created)
+	if assert.Len(t, res.Errors, 2) {
+		// Номера строк файла считаются с заголовком
+		assert.EqualValues(t, 3, res.Errors[0]["row"])
+		assert.EqualValues(t, 4, res.Errors[1]["row"])
+	}
+
+	tasks := getTasks(t, "обезжиренное")
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, date.Format(`20060102`), tasks[0]["date"])
+		assert.Equal(t, "2 литра, обезжиренное", tasks[0]["comment"])
+	}
+
+	body, err = requestJSON("api/export/csv?columns=title:Задача,date:Срок", nil, http.MethodGet)
+	assert.NoError(t, err)
+	lines := strings.Split(string(body), "\n")
+	assert.Equal(t, "Задача,Срок", lines[0])
+	assert.Contains(t, string(body), "Купить молоко из CSV,"+date.Format(`20060102`))
+
+	ret, err := postJSON("api/export/csv?columns=unknown", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
+
+func TestTodoTxtImportExport(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	data := "(C) Вынести мусор из todo.txt due:" + date + " rec:1w\n" +
+		"\n" +
+		"x 2024-01-01 Уже сделано\n"
+
+	body, err := postRaw("api/import/todotxt", "text/plain", data)
+	assert.NoError(t, err)
+	var res importResult
+	assert.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, 1, res.Created)
+	if assert.Len(t, res.Errors, 1) {
+		assert.EqualValues(t, 3, res.Errors[0]["row"])
+	}
+
+	body, err = requestJSON("api/export/todotxt", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "(C) Вынести мусор из todo.txt due:"+date+" rec:7d")
+}
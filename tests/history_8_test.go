@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type historyEntry struct {
+	ID      int64                        `json:"id"`
+	Action  string                       `json:"action"`
+	Actor   string                       `json:"actor"`
+	Changes map[string]map[string]string `json:"changes"`
+}
+
+func getHistory(t *testing.T, id string) []historyEntry {
+	body, err := requestJSON("api/task/history?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string][]historyEntry
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m["history"]
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	id := addTask(t, task{
+		date:  now,
+		title: "Полить цветы",
+	})
+
+	ret, err := postJSON("api/task", map[string]any{
+		"id":    id,
+		"date":  now,
+		"title": "Полить кактус",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	ret, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	history := getHistory(t, id)
+	if !assert.Len(t, history, 3) {
+		return
+	}
+	assert.Equal(t, "create", history[0].Action)
+	assert.Equal(t, "update", history[1].Action)
+	assert.Equal(t, "delete", history[2].Action)
+	assert.NotEmpty(t, history[1].Actor)
+	assert.Equal(t, map[string]string{"from": "Полить цветы", "to": "Полить кактус"},
+		history[1].Changes["title"])
+
+	// Возвращаем задачу к первоначальной версии
+	ret, err = postJSON(fmt.Sprintf("api/task/revert?id=%s&entry=%d", id, history[0].ID), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, "Полить цветы", m["title"])
+
+	history = getHistory(t, id)
+	assert.Equal(t, "revert", history[len(history)-1].Action)
+
+	ret, err = postJSON("api/task/revert?id="+id+"&entry=0", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatToRRule(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		rrule  string
+	}{
+		{"d 1", "FREQ=DAILY;INTERVAL=1"},
+		{"d 14", "FREQ=DAILY;INTERVAL=14"},
+		{"y", "FREQ=YEARLY"},
+		{"w 1,3,7", "FREQ=WEEKLY;BYDAY=MO,WE,SU"},
+		{"m 1,-1", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"m 15 3,9", "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=15"},
+	}
+	for _, v := range tbl {
+		rrule, err := services.RepeatToRRule(v.repeat)
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.rrule, rrule, v.repeat)
+	}
+
+	for _, repeat := range []string{"", "d", "d 500", "w 8", "m 0", "m 1 13", "k 3"} {
+		_, err := services.RepeatToRRule(repeat)
+		assert.Error(t, err, repeat)
+	}
+}
+
+func TestCalendarFolding(t *testing.T) {
+	title := strings.Repeat("Длинный заголовок задачи для проверки переноса строк ", 5)
+	task := models.Task{ID: "1", Date: "20260301", Title: title, Comment: strings.Repeat("x", 300)}
+	var buf bytes.Buffer
+	err := services.WriteCalendar(&buf, "Задачи", "VTODO", []models.Task{task}, time.Now(), nil)
+	assert.NoError(t, err)
+
+	// Каждая строка, включая продолжения с начальным пробелом, не длиннее 75 октетов
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), 75, line)
+	}
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	assert.Contains(t, unfolded, "SUMMARY:"+title+"\r\n")
+	assert.Contains(t, unfolded, "DESCRIPTION:"+strings.Repeat("x", 300)+"\r\n")
+}
+
+func getFeed(t *testing.T, token string) (int, string) {
+	resp, err := http.Get(getURL("api/feed.ics?token=" + token))
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestCalendarFeed(t *testing.T) {
+	addTask(t, task{
+		date:   time.Now().Format(`20060102`),
+		title:  "Задача для календаря",
+		repeat: "w 2,4",
+	})
+
+	feed, err := postJSON("api/feeds", map[string]any{"name": "Задачи", "component": "VTODO"}, http.MethodPost)
+	assert.NoError(t, err)
+	token, _ := feed["token"].(string)
+	assert.NotEmpty(t, token)
+	assert.Contains(t, feed["url"], token)
+
+	// Календарь обращается к ленте без куки token
+	code, body := getFeed(t, token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n"))
+	assert.Contains(t, body, "BEGIN:VTODO")
+	assert.Contains(t, body, "SUMMARY:Задача для календаря")
+	assert.Contains(t, body, "RRULE:FREQ=WEEKLY;BYDAY=TU,TH")
+
+	code, _ = getFeed(t, "wrong")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	ret, err := postJSON("api/feeds?id="+feed["id"].(string), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	code, _ = getFeed(t, token)
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postRaw(apipath, contentType, data string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, getURL(apipath), strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+		setCSRF(req, Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func TestRRuleToRepeat(t *testing.T) {
+	start := time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC) // среда
+
+	tbl := []struct {
+		rrule  string
+		repeat string
+	}{
+		{"FREQ=DAILY", "d 1"},
+		{"FREQ=DAILY;INTERVAL=5", "d 5"},
+		{"FREQ=WEEKLY", "w 3"},
+		{"FREQ=WEEKLY;INTERVAL=2", "d 14"},
+		{"FREQ=WEEKLY;BYDAY=MO,FR;WKST=MO", "w 1,5"},
+		{"FREQ=MONTHLY", "m 17"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", "m 1,-1"},
+		{"FREQ=YEARLY", "y"},
+		{"FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=15", "m 15 3,9"},
+		{"FREQ=YEARLY;BYMONTH=3,9", "m 17 3,9"},
+		{"FREQ=YEARLY;BYMONTHDAY=15", "m 15"},
+	}
+	for _, v := range tbl {
+		repeat, err := services.RRuleToRepeat(v.rrule, start)
+		assert.NoError(t, err, v.rrule)
+		assert.Equal(t, v.repeat, repeat, v.rrule)
+	}
+
+	for _, rrule := range []string{
+		"FREQ=MONTHLY;BYDAY=1MO",
+		"FREQ=DAILY;COUNT=10",
+		"FREQ=YEARLY;INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=MONTHLY;BYMONTHDAY=-5",
+		"INTERVAL=2",
+	} {
+		_, err := services.RRuleToRepeat(rrule, start)
+		assert.Error(t, err, rrule)
+	}
+}
+
+func TestImportCalendar(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:todo-1",
+		"SUMMARY:Полить цветы из календаря",
+		"DESCRIPTION:Все\\, кроме кактуса",
+		"DTSTART;VALUE=DATE:" + date,
+		"RRULE:FREQ=DAILY;INTERVAL=3",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Напоминание",
+		"END:VALARM",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:event-1",
+		"SUMMARY:Планёрка из календаря с очень длинным названием, которое переносится на сл",
+		" едующую строку",
+		"DTSTART:" + date + "T090000",
+		"RRULE:FREQ=WEEKLY;BYDAY=TU,TH",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:event-2",
+		"SUMMARY:Первый понедельник месяца",
+		"DTSTART;VALUE=DATE:" + date,
+		"RRULE:FREQ=MONTHLY;BYDAY=1MO",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	body, err := postRaw("api/import/ics", "text/calendar", ics)
+	assert.NoError(t, err)
+	var res importResult
+	assert.NoError(t, json.Unmarshal(body, &res))
+	assert.Equal(t, 2, res.Created)
+	if assert.Len(t, res.Errors, 1) {
+		assert.Equal(t, "event-2", res.Errors[0]["id"])
+		assert.Contains(t, res.Errors[0]["error"], "BYDAY")
+	}
+
+	tasks := getTasks(t, "кактуса")
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "Полить цветы из календаря", tasks[0]["title"])
+		assert.Equal(t, "Все, кроме кактуса", tasks[0]["comment"])
+		assert.Equal(t, "d 3", tasks[0]["repeat"])
+		assert.Equal(t, date, tasks[0]["date"])
+	}
+	tasks = getTasks(t, "переносится")
+	if assert.Len(t, tasks, 1) {
+		assert.Contains(t, tasks[0]["title"], "на следующую строку")
+		assert.Equal(t, "w 2,4", tasks[0]["repeat"])
+	}
+}
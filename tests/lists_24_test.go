@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedLists(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("authentication is disabled")
+	}
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	mom, kid, guest := "mom"+suffix, "kid"+suffix, "guest"+suffix
+	ids := map[string]float64{}
+	for _, login := range []string{mom, kid, guest} {
+		status, body := userRequest(t, Token, http.MethodPost, "api/users",
+			map[string]any{"login": login, "password": "secret-" + login})
+		assert.Equal(t, http.StatusOK, status, string(body))
+		var user map[string]any
+		assert.NoError(t, json.Unmarshal(body, &user))
+		ids[login] = user["id"].(float64)
+	}
+	defer func() {
+		for _, id := range ids {
+			status, _ := userRequest(t, Token, http.MethodDelete, fmt.Sprintf("api/users?id=%.0f", id), nil)
+			assert.Equal(t, http.StatusOK, status)
+		}
+	}()
+	momToken := signinUser(t, mom, "secret-"+mom)
+	kidToken := signinUser(t, kid, "secret-"+kid)
+	guestToken := signinUser(t, guest, "secret-"+guest)
+
+	status, body := userRequest(t, momToken, http.MethodPost, "api/lists", map[string]any{"name": "Домашние дела"})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var list map[string]any
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Equal(t, "owner", list["role"])
+	listParam := fmt.Sprintf("list=%.0f", list["id"].(float64))
+
+	today := time.Now().Format(`20060102`)
+	status, body = userRequest(t, momToken, http.MethodPost, "api/task?"+listParam, map[string]any{"date": today, "title": "Вынести мусор"})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var created map[string]any
+	assert.NoError(t, json.Unmarshal(body, &created))
+	taskID := created["id"].(string)
+
+	listTitles := func(token, query string) (int, []string) {
+		status, body := userRequest(t, token, http.MethodGet, "api/tasks"+query, nil)
+		var resp struct {
+			Tasks []map[string]any `json:"tasks"`
+		}
+		_ = json.Unmarshal(body, &resp)
+		var titles []string
+		for _, task := range resp.Tasks {
+			titles = append(titles, task["title"].(string))
+		}
+		return status, titles
+	}
+
+	// Задача списка не попадает в личные задачи создателя
+	_, titles := listTitles(momToken, "")
+	assert.NotContains(t, titles, "Вынести мусор")
+
+	// До принятия приглашения список недоступен
+	status, _ = listTitles(kidToken, "?"+listParam)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	// Приглашать может только владелец
+	status, body = userRequest(t, momToken, http.MethodPost, "api/lists/invitations?"+listParam, map[string]any{"login": kid, "role": "viewer"})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	status, _ = userRequest(t, momToken, http.MethodPost, "api/lists/invitations?"+listParam, map[string]any{"login": kid, "role": "boss"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = userRequest(t, guestToken, http.MethodPost, "api/lists/invitations?"+listParam, map[string]any{"login": guest})
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body = userRequest(t, kidToken, http.MethodGet, "api/invitations", nil)
+	assert.Equal(t, http.StatusOK, status)
+	var invitations struct {
+		Invitations []map[string]any `json:"invitations"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &invitations))
+	if !assert.Len(t, invitations.Invitations, 1) {
+		return
+	}
+	assert.Equal(t, "Домашние дела", invitations.Invitations[0]["list_name"])
+	assert.Equal(t, mom, invitations.Invitations[0]["invited_by"])
+	invitationID := fmt.Sprintf("%.0f", invitations.Invitations[0]["id"].(float64))
+
+	// Чужое приглашение принять нельзя
+	status, _ = userRequest(t, guestToken, http.MethodPost, "api/invitations/accept?id="+invitationID, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, body = userRequest(t, kidToken, http.MethodPost, "api/invitations/accept?id="+invitationID, nil)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	assert.Contains(t, string(body), `"role":"viewer"`)
+
+	// Наблюдатель видит задачи списка, но не может их изменять
+	status, titles = listTitles(kidToken, "?"+listParam)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"Вынести мусор"}, titles)
+	status, _ = userRequest(t, kidToken, http.MethodPost, "api/task/done?id="+taskID+"&"+listParam, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, kidToken, http.MethodPost, "api/task?"+listParam, map[string]any{"date": today, "title": "Поиграть"})
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, kidToken, http.MethodDelete, "api/lists?"+listParam, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Без параметра list задача списка не находится
+	status, body = userRequest(t, kidToken, http.MethodGet, "api/task?id="+taskID, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, string(body), "error")
+
+	// Повторное приглашение участника отклоняется, повысить роль можно, удалив его и пригласив снова
+	status, _ = userRequest(t, momToken, http.MethodPost, "api/lists/invitations?"+listParam, map[string]any{"login": kid, "role": "editor"})
+	assert.Equal(t, http.StatusConflict, status)
+	kidParam := fmt.Sprintf("%s&user=%.0f", listParam, ids[kid])
+	status, _ = userRequest(t, momToken, http.MethodDelete, "api/lists/members?"+kidParam, nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = userRequest(t, momToken, http.MethodPost, "api/lists/invitations?"+listParam, map[string]any{"login": kid, "role": "editor"})
+	assert.Equal(t, http.StatusOK, status)
+	_, body = userRequest(t, kidToken, http.MethodGet, "api/invitations", nil)
+	assert.NoError(t, json.Unmarshal(body, &invitations))
+	if !assert.Len(t, invitations.Invitations, 1) {
+		return
+	}
+	invitationID = fmt.Sprintf("%.0f", invitations.Invitations[0]["id"].(float64))
+	status, _ = userRequest(t, kidToken, http.MethodPost, "api/invitations/accept?id="+invitationID, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Редактор изменяет задачи списка, изменения видны владельцу
+	status, body = userRequest(t, kidToken, http.MethodPost, "api/task/done?id="+taskID+"&"+listParam, nil)
+	assert.Equal(t, http.StatusOK, status, string(body))
+	_, titles = listTitles(momToken, "?"+listParam)
+	assert.Empty(t, titles)
+
+	status, body = userRequest(t, momToken, http.MethodGet, "api/lists/members?"+listParam, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"login":"`+kid+`","role":"editor"`)
+
+	// Редактор не может удалить другого участника, а последний владелец не может выйти из списка
+	momParam := fmt.Sprintf("%s&user=%.0f", listParam, ids[mom])
+	status, _ = userRequest(t, kidToken, http.MethodDelete, "api/lists/members?"+momParam, nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, momToken, http.MethodDelete, "api/lists/members?"+momParam, nil)
+	assert.Equal(t, http.StatusConflict, status)
+
+	// После отзыва доступа список снова недоступен
+	status, _ = userRequest(t, momToken, http.MethodDelete, "api/lists/members?"+kidParam, nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = listTitles(kidToken, "?"+listParam)
+	assert.Equal(t, http.StatusNotFound, status)
+	_, body = userRequest(t, kidToken, http.MethodGet, "api/lists", nil)
+	assert.JSONEq(t, `{"lists":[]}`, string(body))
+
+	status, _ = userRequest(t, momToken, http.MethodDelete, "api/lists?"+listParam, nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = listTitles(momToken, "?"+listParam)
+	assert.Equal(t, http.StatusNotFound, status)
+}
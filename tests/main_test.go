@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+)
+
+// TestMain получает токен сессии по паролю Password, если он не задан в настройках
+func TestMain(m *testing.M) {
+	if len(Token) == 0 && len(Password) > 0 {
+		token, err := signin(Password)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to sign in: %v\n", err)
+			os.Exit(1)
+		}
+		Token = token
+	}
+	os.Exit(m.Run())
+}
+
+// signin выполняет вход по общему паролю и возвращает токен
+func signin(password string) (string, error) {
+	data, err := json.Marshal(map[string]string{"password": password})
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.Post(getURL("api/signin"), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Token     string `json:"token"`
+		CSRFToken string `json:"csrf_token"`
+		Error     string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, body.Error)
+	}
+	rememberCSRF(body.Token, body.CSRFToken)
+	return body.Token, nil
+}
+
+// csrfTokens хранит CSRF-токены сессий, открытых тестами, по их токенам доступа
+var csrfTokens sync.Map
+
+// rememberCSRF запоминает CSRF-токен сессии токена доступа token
+func rememberCSRF(token, csrf string) {
+	if token != "" && csrf != "" {
+		csrfTokens.Store(token, csrf)
+	}
+}
+
+// setCSRF добавляет в запрос с токеном из куки CSRF-токен его сессии
+func setCSRF(req *http.Request, token string) {
+	if csrf, ok := csrfTokens.Load(token); ok {
+		req.Header.Set("X-CSRF-Token", csrf.(string))
+	}
+}
@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProvider — провайдер OpenID Connect, который выдаёт код авторизации без страницы входа
+type mockProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	subject string
+	claims  jwt.MapClaims
+	codes   map[string]url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	p := &mockProvider{key: key, codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	// Пользователь сразу считается вошедшим: провайдер возвращает код на redirect_uri
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		code := "code-" + r.FormValue("state")
+		p.codes[code] = r.URL.Query()
+		http.Redirect(w, r, r.FormValue("redirect_uri")+"?code="+code+"&state="+r.FormValue("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		auth := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		id, secret, _ := r.BasicAuth()
+		if auth == nil || id != "todo" || secret != "client-secret" ||
+			services.PKCEChallenge(r.FormValue("code_verifier")) != auth.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": p.URL, "aud": "todo", "sub": p.subject, "nonce": auth.Get("nonce"),
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(), "preferred_username": "carol.smith"}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		signed, _ := token.SignedString(key)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "access_token": "unused", "token_type": "Bearer"})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func TestOIDCSignin(t *testing.T) {
+	provider := newMockProvider(t)
+	defer provider.Close()
+
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_JWT_SECRET", "secret")
+	configFile := filepath.Join(dir, "oidc.json")
+	data, _ := json.Marshal(map[string]string{"issuer": provider.URL, "client_id": "todo", "client_secret": "wrong"})
+	assert.NoError(t, os.WriteFile(configFile, data, 0o600))
+	t.Setenv("TODO_OIDC_CONFIG", configFile)
+	t.Setenv("TODO_OIDC_CLIENT_SECRET", "client-secret")
+	t.Setenv("TODO_OIDC_REDIRECT_URL", "http://todo.example/api/oidc/callback")
+	db := database.InitDb()
+	defer db.Close()
+
+	// login проходит вход до возврата от провайдера и возвращает адрес возврата и куку state
+	login := func() (*url.URL, *http.Cookie) {
+		rec := httptest.NewRecorder()
+		rest.OIDCLoginHandler(rec, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+		assert.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+		authorize, err := url.Parse(rec.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "S256", authorize.Query().Get("code_challenge_method"))
+		assert.NotEmpty(t, authorize.Query().Get("nonce"))
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := client.Get(authorize.String())
+		assert.NoError(t, err)
+		resp.Body.Close()
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		assert.NoError(t, err)
+		return callback, rec.Result().Cookies()[0]
+	}
+	callback := func(target *url.URL, state *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target.RequestURI(), nil)
+		if state != nil {
+			req.AddCookie(state)
+		}
+		rec := httptest.NewRecorder()
+		rest.OIDCCallbackHandler(rec, req)
+		return rec
+	}
+	sessionUser := func(rec *httptest.ResponseRecorder) string {
+		for _, cookie := range rec.Result().Cookies() {
+			if cookie.Name == "token" && cookie.Value != "" {
+				cfg := config.LoadJWTConfig()
+				cfg.Password = "password"
+				req := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+				req.AddCookie(cookie)
+				var login string
+				services.Auth(cfg, func(w http.ResponseWriter, r *http.Request) {
+					login = services.CurrentUser(r.Context()).Login
+				})(httptest.NewRecorder(), req)
+				return login
+			}
+		}
+		return ""
+	}
+
+	// Первый вход создаёт учётную запись и открывает её сессию
+	provider.subject = "ext-1"
+	target, state := login()
+	rec := callback(target, state)
+	assert.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+	assert.Equal(t, "carol.smith", sessionUser(rec))
+
+	// state действует один раз и только в браузере, начавшем вход
+	rec = callback(target, state)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	target, _ = login()
+	rec = callback(target, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Повторный вход попадает в ту же учётную запись, даже если имя у провайдера изменилось
+	provider.claims = jwt.MapClaims{"preferred_username": "carol.jones"}
+	target, state = login()
+	rec = callback(target, state)
+	assert.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+	assert.Equal(t, "carol.smith", sessionUser(rec))
+
+	// Другой пользователь провайдера с занятым именем не получает чужую учётную запись
+	provider.subject, provider.claims = "ext-2", nil
+	target, state = login()
+	rec = callback(target, state)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// Связать его можно явно
+	_, err := services.RegisterUser("carol2", "correct horse", "")
+	assert.NoError(t, err)
+	assert.NoError(t, services.LinkOIDCIdentity(config.LoadOIDCConfig(), "carol2", "ext-2"))
+	target, state = login()
+	rec = callback(target, state)
+	assert.Equal(t, "carol2", sessionUser(rec))
+
+	// ID-токен с чужим nonce или получателем отклоняется
+	provider.claims = jwt.MapClaims{"nonce": "replayed"}
+	target, state = login()
+	assert.Equal(t, http.StatusUnauthorized, callback(target, state).Code)
+	provider.claims = jwt.MapClaims{"aud": "another-client"}
+	target, state = login()
+	assert.Equal(t, http.StatusUnauthorized, callback(target, state).Code)
+
+	// Без автоматического создания неизвестная учётная запись не создаётся
+	t.Setenv("TODO_OIDC_AUTO_CREATE", "off")
+	provider.subject, provider.claims = "ext-3", jwt.MapClaims{"preferred_username": "dave"}
+	target, state = login()
+	assert.Equal(t, http.StatusForbidden, callback(target, state).Code)
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type overdueTask struct {
+	ID      string `json:"id"`
+	Date    string `json:"date"`
+	Title   string `json:"title"`
+	Overdue bool   `json:"overdue"`
+}
+
+func TestOverduePolicy(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+	past := now.AddDate(0, 0, -3).Format(`20060102`)
+
+	// По умолчанию прошедшая дата переносится на сегодня
+	m, err := postJSON("api/task", map[string]any{"date": past, "title": "Сдать отчёт"}, http.MethodPost)
+	assert.NoError(t, err)
+	clamped := m["id"].(string)
+	body, err := requestJSON("api/task?id="+clamped, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var task overdueTask
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, today, task.Date)
+	assert.False(t, task.Overdue)
+
+	// С overdue=keep дата сохраняется, а задача отмечается просроченной
+	m, err = postJSON("api/task?overdue=keep", map[string]any{"date": past, "title": "Оплатить счёт"}, http.MethodPost)
+	assert.NoError(t, err)
+	kept := m["id"].(string)
+	body, err = requestJSON("api/task?id="+kept, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &task))
+	assert.Equal(t, past, task.Date)
+	assert.True(t, task.Overdue)
+
+	var list struct {
+		Tasks []overdueTask `json:"tasks"`
+	}
+	body, err = requestJSON("api/tasks/overdue", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &list))
+	ids := map[string]bool{}
+	for _, v := range list.Tasks {
+		assert.True(t, v.Overdue, v.ID)
+		ids[v.ID] = true
+	}
+	assert.True(t, ids[kept])
+	assert.False(t, ids[clamped])
+
+	body, err = requestJSON("api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &list))
+	for _, v := range list.Tasks {
+		if v.ID == kept {
+			assert.True(t, v.Overdue)
+		}
+	}
+
+	// Изменение с правилом clamp переносит дату на сегодня
+	m, err = postJSON("api/task?overdue=clamp", map[string]any{"id": kept, "date": past, "title": "Оплатить счёт"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, today, m["date"])
+
+	m, err = postJSON("api/task?overdue=later", map[string]any{"date": past, "title": "Оплатить счёт"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	for _, id := range []string{clamped, kept} {
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
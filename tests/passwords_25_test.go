@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordHashes(t *testing.T) {
+	for _, algo := range []string{config.PasswordBcrypt, config.PasswordArgon2id} {
+		t.Setenv("TODO_PASSWORD_ALGO", algo)
+		hash, err := services.HashPassword("correct horse")
+		assert.NoError(t, err)
+		assert.True(t, services.IsPasswordHash(hash), hash)
+		assert.NotContains(t, hash, "correct horse")
+		assert.True(t, services.VerifyPassword(hash, "correct horse"), algo)
+		assert.False(t, services.VerifyPassword(hash, "correct horsf"), algo)
+	}
+	assert.True(t, strings.HasPrefix(mustHash(t, config.PasswordArgon2id), "$argon2id$v=19$"))
+
+	// Значение, не являющееся хэшем, сравнивается как пароль
+	assert.True(t, services.VerifyPassword("plain", "plain"))
+	assert.False(t, services.VerifyPassword("plain", "plai"))
+	assert.False(t, services.VerifyPassword("$argon2id$broken", "broken"))
+}
+
+func mustHash(t *testing.T, algo string) string {
+	t.Setenv("TODO_PASSWORD_ALGO", algo)
+	hash, err := services.HashPassword("password")
+	assert.NoError(t, err)
+	return hash
+}
+
+func TestSessions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	hash := mustHash(t, config.PasswordBcrypt)
+	cfg := &config.JWTConfig{Password: hash, Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour}
+
+	// Общий пароль может быть задан хэшем
+	ok, err := services.CheckSharedPassword(cfg, "password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = services.CheckSharedPassword(cfg, hash)
+	assert.False(t, ok)
+
+	status := func(token string) int {
+		handler := services.Auth(cfg, func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	tokens, err := services.IssueToken(cfg, models.AdminID, "")
+	assert.NoError(t, err)
+	token := tokens.Token
+	assert.Equal(t, http.StatusOK, status(token))
+
+	// Токен содержит идентификатор сессии, а не хэш пароля
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	assert.NoError(t, err)
+	assert.Nil(t, claims["passwordHash"])
+	assert.Len(t, claims[services.ClaimSession], 64)
+
+	// Токен с хэшем пароля вместо сессии больше не принимается
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"passwordHash": "9834876d"}).SignedString([]byte(cfg.Secret))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(legacy))
+
+	// Смена пароля закрывает сессии, пароль администратора заменяет общий пароль
+	assert.ErrorIs(t, services.SetPassword(models.AdminLogin, "short"), services.ErrPasswordTooShort)
+	assert.NoError(t, services.SetPassword(models.AdminLogin, "new admin password"))
+	assert.Equal(t, http.StatusUnauthorized, status(token))
+	ok, _ = services.CheckSharedPassword(cfg, "password")
+	assert.False(t, ok)
+	ok, _ = services.CheckSharedPassword(cfg, "new admin password")
+	assert.True(t, ok)
+
+	admin, err := services.Authenticate(models.AdminLogin, "new admin password")
+	assert.NoError(t, err)
+	assert.Equal(t, models.AdminID, admin.ID)
+	assert.ErrorIs(t, services.SetPassword("nobody", "long enough"), database.ErrUserNotFound)
+}
+
+func TestAdminPasswordWithoutEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_PASSWORD", "")
+	t.Setenv("TODO_JWT_SECRET", "secret")
+	db := database.InitDb()
+	defer db.Close()
+
+	signin := func(password string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]string{"password": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewReader(data))
+		rec := httptest.NewRecorder()
+		rest.TokenHandler(rec, req)
+		return rec
+	}
+	status := func(token string) int {
+		handler := services.Auth(config.LoadJWTConfig(), func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, signin("new admin password").Code)
+
+	// Пароль администратора, заданный командой passwd, включает аутентификацию без TODO_PASSWORD
+	assert.NoError(t, services.SetPassword(models.AdminLogin, "new admin password"))
+	assert.Equal(t, http.StatusUnauthorized, status(""))
+	assert.Equal(t, http.StatusUnauthorized, signin("wrong").Code)
+
+	rec := signin("new admin password")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var tokens models.JWTTokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	assert.Equal(t, http.StatusOK, status(tokens.Token))
+}
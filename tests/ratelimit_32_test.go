@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/ws"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitBucket(t *testing.T) {
+	cfg := &config.RateLimitConfig{Enabled: true, Read: 6, Write: 3, NextDate: 60, Window: time.Minute}
+	limiter := services.NewRateLimiter(cfg, &config.JWTConfig{})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Корзина вмещает лимит запросов, затем запросы отклоняются
+	for i := 0; i < 3; i++ {
+		res := limiter.Allow("ip:192.0.2.1", services.RateWrite, start)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	res := limiter.Allow("ip:192.0.2.1", services.RateWrite, start)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assert.Equal(t, 20*time.Second, res.RetryAfter)
+	assert.Equal(t, time.Minute, res.Reset)
+
+	// Классы запросов и клиенты учитываются раздельно
+	assert.True(t, limiter.Allow("ip:192.0.2.1", services.RateRead, start).Allowed)
+	assert.True(t, limiter.Allow("ip:192.0.2.1", services.RateNextDate, start).Allowed)
+	assert.True(t, limiter.Allow("ip:198.51.100.7", services.RateWrite, start).Allowed)
+
+	// Корзина пополняется равномерно и не больше лимита
+	assert.False(t, limiter.Allow("ip:192.0.2.1", services.RateWrite, start.Add(19*time.Second)).Allowed)
+	assert.True(t, limiter.Allow("ip:192.0.2.1", services.RateWrite, start.Add(20*time.Second)).Allowed)
+	res = limiter.Allow("ip:192.0.2.1", services.RateWrite, start.Add(time.Hour))
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+	assert.Equal(t, 20*time.Second, res.Reset)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	jwtCfg := &config.JWTConfig{Password: "password", Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour}
+	cfg := &config.RateLimitConfig{Enabled: true, Read: 2, Write: 1, NextDate: 1, Window: time.Minute}
+	handler := services.NewRateLimiter(cfg, jwtCfg).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(method, path string, prepare func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if prepare != nil {
+			prepare(req)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodGet, "/api/tasks", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "2;w=60", rec.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/tasks", nil).Code)
+	rec = send(http.MethodGet, "/api/tasks", nil)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	// Изменяющие запросы и расчёт даты ограничиваются отдельно
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/task", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodPut, "/api/task", nil).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/api/nextdate", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/api/nextdate", nil).Code)
+
+	// Пользователь сессии получает свою корзину для всех своих сессий и адресов
+	first, err := services.IssueToken(jwtCfg, models.AdminID, "")
+	assert.NoError(t, err)
+	second, err := services.IssueToken(jwtCfg, models.AdminID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/task", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+first.Token)
+	}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodPost, "/api/task", func(r *http.Request) {
+		r.RemoteAddr = "198.51.100.7:1234"
+		r.AddCookie(&http.Cookie{Name: "token", Value: second.Token})
+	}).Code)
+
+	// Ключ API учитывается отдельно от сессий владельца, а недействительный ключ — по адресу
+	key, err := services.CreateAPIKey(context.Background(), models.APIKey{Name: "sync", Scopes: []string{models.ScopeTasksWrite}})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/task", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+key.Key)
+	}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodPost, "/api/task", func(r *http.Request) {
+		r.SetBasicAuth("admin", key.Key)
+	}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodPost, "/api/task", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+services.APIKeyPrefix+"forged")
+	}).Code)
+
+	// Статические файлы учитываются по адресу, даже если запрос содержит ключ API
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/index.html", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+key.Key)
+	}).Code)
+
+	// Выключенное ограничение пропускает все запросы без заголовков
+	cfg.Enabled = false
+	handler = services.NewRateLimiter(cfg, jwtCfg).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		rec = send(http.MethodPost, "/api/task", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitWebSocket(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	jwtCfg := &config.JWTConfig{Password: "password", Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour}
+	cfg := &config.RateLimitConfig{Enabled: true, Read: 2, Write: 1, NextDate: 1, Window: time.Minute}
+	server := httptest.NewServer(services.NewRateLimiter(cfg, jwtCfg).Middleware(services.Auth(jwtCfg, ws.Handler)))
+	defer server.Close()
+
+	tokens, err := services.IssueToken(jwtCfg, models.AdminID, "")
+	assert.NoError(t, err)
+	header := http.Header{"Authorization": {"Bearer " + tokens.Token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", header)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	// Подключение и каждая операция расходуют корзину пользователя
+	res := wsCall(t, conn, map[string]any{"id": "1", "op": "list"})
+	assert.Equal(t, http.StatusOK, res.Status)
+	res = wsCall(t, conn, map[string]any{"id": "2", "op": "get", "task_id": "1"})
+	assert.Equal(t, http.StatusTooManyRequests, res.Status)
+	assert.Equal(t, 30, res.RetryAfter)
+
+	res = wsCall(t, conn, map[string]any{"id": "3", "op": "create", "task": map[string]any{"date": "20260301", "title": "Задача"}})
+	assert.Equal(t, http.StatusOK, res.Status)
+	res = wsCall(t, conn, map[string]any{"id": "4", "op": "delete", "task_id": res.Task["id"]})
+	assert.Equal(t, http.StatusTooManyRequests, res.Status)
+	assert.Equal(t, 60, res.RetryAfter)
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// smtpStandIn запускает локальный SMTP сервер, который принимает письма без проверок
+// и передаёт их содержимое в канал
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+	reply("220 localhost SMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			messages <- data.String()
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestReminders(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	addr, messages := smtpStandIn(t)
+	srv, requests := webhookReceiver(t, func(int32) int { return http.StatusOK })
+
+	cfg := &config.ReminderConfig{
+		At:            9 * time.Hour,
+		Leads:         []time.Duration{24 * time.Hour, 0},
+		SMTPAddr:      addr,
+		SMTPFrom:      "todo@localhost",
+		SMTPTo:        []string{"team@localhost"},
+		WebhookURL:    srv.URL,
+		WebhookSecret: "secret",
+	}
+	notifiers := services.NewNotifiers(cfg)
+	assert.Len(t, notifiers, 3)
+
+	// Фиксированное время проверки: 10:00 сегодняшнего дня
+	today := time.Now()
+	now := time.Date(today.Year(), today.Month(), today.Day(), 10, 0, 0, 0, time.Local)
+
+	due, err := services.CreateTask(context.Background(), models.Task{
+		Date:  now.Format(`20060102`),
+		Title: "Сдать отчёт",
+	})
+	assert.NoError(t, err)
+	tomorrow, err := services.CreateTask(context.Background(), models.Task{
+		Date:  now.AddDate(0, 0, 1).Format(`20060102`),
+		Title: "Позвонить поставщику",
+	})
+	assert.NoError(t, err)
+	_, err = services.CreateTask(context.Background(), models.Task{
+		Date:  now.AddDate(0, 0, 2).Format(`20060102`),
+		Title: "Подготовить презентацию",
+	})
+	assert.NoError(t, err)
+
+	// Личная задача другого пользователя не уходит на общие адреса администратора
+	bob, err := services.RegisterUser("bob", "correct horse", "")
+	assert.NoError(t, err)
+	private, err := services.CreateTask(services.WithUser(context.Background(), bob), models.Task{
+		Date:  now.Format(`20060102`),
+		Title: "Личная задача Боба",
+	})
+	assert.NoError(t, err)
+
+	// Задача на сегодня и задача на завтра с опережением 24 часа — по три канала,
+	// задача другого пользователя — только в его ленту
+	sent, err := services.CheckReminders(context.Background(), cfg, notifiers, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, sent)
+
+	var mail []string
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-messages:
+			mail = append(mail, m)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Письмо не получено")
+		}
+	}
+	joined := strings.Join(mail, "\n")
+	assert.Contains(t, joined, "Задача: Сдать отчёт")
+	assert.Contains(t, joined, "Задача: Позвонить поставщику")
+	assert.Contains(t, joined, "To: team@localhost")
+
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		req := <-requests
+		assert.Equal(t, "reminder", req.header.Get("X-Todo-Event"))
+		assert.Equal(t, services.SignWebhook("secret", req.body), req.header.Get("X-Todo-Signature"))
+		var reminder models.Reminder
+		assert.NoError(t, json.Unmarshal(req.body, &reminder))
+		got[reminder.TaskID] = true
+	}
+	assert.True(t, got[due.ID])
+	assert.True(t, got[tomorrow.ID])
+	assert.Empty(t, messages)
+	assert.Empty(t, requests)
+
+	notifications, err := database.GetNotifications(models.AdminID, 0, 10)
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 2)
+	own, err := database.GetNotifications(bob.ID, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, own, 1) {
+		assert.Equal(t, private.ID, own[0].TaskID)
+	}
+
+	// Повторная проверка не отправляет те же напоминания
+	sent, err = services.CheckReminders(context.Background(), cfg, notifiers, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Завтра в 9:00 наступает момент задачи на завтра, и напоминание без опережения
+	// отправляется отдельно, а для задачи на послезавтра наступает опережение 24 часа
+	sent, err = services.CheckReminders(context.Background(), cfg, []services.Notifier{services.FeedNotifier{}},
+		now.Add(23*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+
+	notifications, err = database.GetNotifications(models.AdminID, notifications[1].ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, notifications, 2) {
+		assert.Equal(t, tomorrow.ID, notifications[0].TaskID)
+	}
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/caldav"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigninProtection(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := &config.SigninConfig{Enabled: true, FreeAttempts: 2, Delay: time.Second, MaxAttempts: 5, IPMaxAttempts: 100, Lockout: time.Minute}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	attempt := func(login, ip string, offset time.Duration) error {
+		_, err := services.StartSignin(cfg, login, ip, start.Add(offset))
+		return err
+	}
+
+	// Первые попытки не задерживаются, затем задержка удваивается
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, attempt("victim", "192.0.2.1", 0))
+	}
+	locked, err := services.StartSignin(cfg, "victim", "192.0.2.1", start)
+	assert.ErrorIs(t, err, services.ErrSigninLocked)
+	assert.Equal(t, start.Add(time.Second), locked.RetryAt)
+	assert.NoError(t, attempt("victim", "192.0.2.1", time.Second))
+	assert.ErrorIs(t, attempt("victim", "192.0.2.1", 2*time.Second), services.ErrSigninLocked)
+	assert.NoError(t, attempt("victim", "192.0.2.1", 3*time.Second))
+
+	// После MaxAttempts учётная запись заблокирована с любого адреса
+	assert.ErrorIs(t, attempt("victim", "198.51.100.7", 30*time.Second), services.ErrSigninLocked)
+	assert.NoError(t, attempt("other", "198.51.100.7", 30*time.Second))
+	assert.NoError(t, attempt("victim", "198.51.100.7", 64*time.Second))
+
+	// Журнал хранит неудачные попытки, снятие блокировки их не удаляет
+	failures, err := database.GetSigninFailures("victim", "", 100)
+	assert.NoError(t, err)
+	assert.Len(t, failures, 6)
+	assert.Equal(t, "198.51.100.7", failures[0].IP)
+	cleared, err := database.ClearSigninFailures("victim", "")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, cleared)
+
+	// Успешный вход удаляет свою попытку и обнуляет счётчик учётной записи
+	ok, err := services.StartSignin(cfg, "victim", "192.0.2.1", start.Add(65*time.Second))
+	assert.NoError(t, err)
+	assert.NoError(t, ok.Succeed())
+	failures, _ = database.GetSigninFailures("victim", "", 100)
+	assert.Len(t, failures, 6)
+	for _, failure := range failures {
+		assert.True(t, failure.LoginCleared)
+		assert.False(t, failure.IPCleared)
+	}
+
+	// Перебор учётных записей с одного адреса блокирует адрес
+	cfg.FreeAttempts, cfg.IPMaxAttempts = 100, 3
+	for _, login := range []string{"a", "b", "c"} {
+		assert.NoError(t, attempt(login, "203.0.113.9", 70*time.Second))
+	}
+	assert.ErrorIs(t, attempt("d", "203.0.113.9", 70*time.Second), services.ErrSigninLocked)
+	assert.NoError(t, attempt("d", "203.0.113.10", 70*time.Second))
+	_, err = database.ClearSigninFailures("", "203.0.113.9")
+	assert.NoError(t, err)
+	assert.NoError(t, attempt("d", "203.0.113.9", 70*time.Second))
+
+	// Без защиты попытки не учитываются
+	cfg.Enabled = false
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, attempt("e", "203.0.113.9", 70*time.Second))
+	}
+	failures, _ = database.GetSigninFailures("e", "", 100)
+	assert.Empty(t, failures)
+}
+
+func TestSigninLockoutHandler(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_JWT_SECRET", "secret")
+	t.Setenv("TODO_SIGNIN_MAX_ATTEMPTS", "1")
+	t.Setenv("TODO_SIGNIN_LOCKOUT", "1h")
+	db := database.InitDb()
+	defer db.Close()
+
+	_, err := services.RegisterUser("mallory", "correct horse", "")
+	assert.NoError(t, err)
+
+	signin := func(password string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(map[string]string{"login": "mallory", "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewReader(data))
+		req.RemoteAddr = "192.0.2.1:40000"
+		rec := httptest.NewRecorder()
+		rest.TokenHandler(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, signin("wrong").Code)
+
+	// Заблокированная учётная запись не принимает даже верный пароль
+	rec := signin("correct horse")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	rest.SigninFailuresHandler(rec, httptest.NewRequest(http.MethodGet, "/api/signin/failures?ip=192.0.2.1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"login":"mallory"`)
+
+	rec = httptest.NewRecorder()
+	rest.ClearLockoutsHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/signin/lockouts?login=mallory", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"cleared":1}`, rec.Body.String())
+
+	assert.Equal(t, http.StatusOK, signin("correct horse").Code)
+}
+
+func TestCalDAVSigninLockout(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_SIGNIN_MAX_ATTEMPTS", "1")
+	t.Setenv("TODO_SIGNIN_LOCKOUT", "1h")
+	db := database.InitDb()
+	defer db.Close()
+
+	_, err := services.RegisterUser("mallory", "correct horse", "")
+	assert.NoError(t, err)
+
+	r := chi.NewRouter()
+	caldav.Register(r, &config.JWTConfig{Password: "password", Secret: "secret"})
+	propfind := func(ip, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PROPFIND", "/caldav/", nil)
+		req.Header.Set("Depth", "0")
+		req.RemoteAddr = ip + ":40000"
+		req.SetBasicAuth("mallory", password)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Успешный вход не оставляет попыток в журнале
+	assert.Equal(t, http.StatusMultiStatus, propfind("192.0.2.1", "correct horse").Code)
+	assert.Equal(t, http.StatusMultiStatus, propfind("192.0.2.1", "correct horse").Code)
+
+	// Неудачная попытка через CalDAV блокирует учётную запись
+	assert.Equal(t, http.StatusUnauthorized, propfind("192.0.2.1", "wrong").Code)
+	rec := propfind("192.0.2.1", "correct horse")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Учётная запись, заблокированная через /api/signin, не принимается и в CalDAV
+	_, err = database.ClearSigninFailures("mallory", "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMultiStatus, propfind("198.51.100.7", "correct horse").Code)
+	data, _ := json.Marshal(map[string]string{"login": "mallory", "password": "wrong"})
+	req := httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewReader(data))
+	req.RemoteAddr = "198.51.100.7:40000"
+	signin := httptest.NewRecorder()
+	rest.TokenHandler(signin, req)
+	assert.Equal(t, http.StatusUnauthorized, signin.Code)
+	rec = propfind("203.0.113.9", "correct horse")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
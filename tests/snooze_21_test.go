@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnoozeDate(t *testing.T) {
+	now := time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC) // среда
+
+	tbl := []struct {
+		date, interval, expected string
+	}{
+		{"20240117", "+1d", "20240118"},
+		{"20240120", "+3d", "20240123"},
+		{"20240117", "+2w", "20240131"},
+		{"20240117", "next-week", "20240124"},
+		{"20240117", "next-monday", "20240122"},
+		{"20240117", "next-wednesday", "20240124"},
+		{"20240117", "Next-Friday", "20240119"},
+		// Просроченная задача откладывается от сегодняшнего дня
+		{"20240110", "+1d", "20240118"},
+	}
+	for _, v := range tbl {
+		date, err := services.SnoozeDate(now, v.date, v.interval)
+		assert.NoError(t, err, v.interval)
+		assert.Equal(t, v.expected, date, v.interval)
+	}
+
+	for _, interval := range []string{"", "1d", "+0d", "+d", "+2m", "next-month", "next-funday"} {
+		_, err := services.SnoozeDate(now, "20240117", interval)
+		assert.Error(t, err, interval)
+	}
+}
+
+func TestSnoozeTask(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+	id := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы на балконе",
+		repeat: "d 7",
+	})
+
+	body, err := requestJSON("api/task/snooze?id="+id+"&interval=%2B2d", nil, http.MethodPost)
+	assert.NoError(t, err)
+	var snoozed map[string]string
+	assert.NoError(t, json.Unmarshal(body, &snoozed))
+	assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), snoozed["date"])
+	assert.Equal(t, today, snoozed["anchor"])
+
+	// Повторное откладывание сохраняет исходную дату по расписанию
+	body, err = requestJSON("api/task/postpone?id="+id+"&date="+now.AddDate(0, 0, 3).Format(`20060102`), nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(body, &snoozed))
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), snoozed["date"])
+	assert.Equal(t, today, snoozed["anchor"])
+
+	history := getHistory(t, id)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, "snooze", history[1].Action)
+		assert.Equal(t, today, history[1].Changes["date"]["from"])
+		assert.Equal(t, "snooze", history[2].Action)
+	}
+
+	// После выполнения следующая дата отсчитывается от даты по расписанию, а не от переноса
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	body, err = requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var done map[string]string
+	assert.NoError(t, json.Unmarshal(body, &done))
+	assert.Equal(t, now.AddDate(0, 0, 7).Format(`20060102`), done["date"])
+	assert.Empty(t, done["anchor"])
+
+	for _, path := range []string{
+		"api/task/snooze?id=" + id + "&interval=tomorrow",
+		"api/task/postpone?id=" + id + "&date=" + now.AddDate(0, 0, -1).Format(`20060102`),
+		"api/task/postpone?id=" + id + "&date=01.02.2024",
+	} {
+		m, err := postJSON(path, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], path)
+	}
+}
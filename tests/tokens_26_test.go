@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenPair выполняет запрос, возвращающий пару токенов
+func tokenPair(t *testing.T, path string, values map[string]any) (int, models.JWTTokenResponse) {
+	status, body := userRequest(t, "", http.MethodPost, path, values)
+	var tokens models.JWTTokenResponse
+	assert.NoError(t, json.Unmarshal(body, &tokens))
+	rememberCSRF(tokens.Token, tokens.CSRFToken)
+	return status, tokens
+}
+
+func TestRefreshTokens(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("authentication is disabled")
+	}
+
+	status, first := tokenPair(t, "api/signin", map[string]any{"password": Password})
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, first.RefreshToken)
+	assert.Positive(t, first.ExpiresIn)
+
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(first.Token, claims)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims["jti"])
+	assert.NotEmpty(t, claims["iat"])
+	assert.NotEmpty(t, claims["exp"])
+
+	// Refresh-токен обменивается на новую пару один раз
+	status, second := tokenPair(t, "api/token/refresh", map[string]any{"refresh_token": first.RefreshToken})
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, first.Token, second.Token)
+	status, _ = userRequest(t, second.Token, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Повторное предъявление закрывает сессию вместе со всеми её токенами
+	status, _ = tokenPair(t, "api/token/refresh", map[string]any{"refresh_token": first.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = userRequest(t, second.Token, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = tokenPair(t, "api/token/refresh", map[string]any{"refresh_token": second.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// После выхода токены сессии не действуют
+	_, third := tokenPair(t, "api/signin", map[string]any{"password": Password})
+	status, _ = userRequest(t, third.Token, http.MethodPost, "api/logout", nil)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = userRequest(t, third.Token, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = tokenPair(t, "api/token/refresh", map[string]any{"refresh_token": third.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Выход из одной сессии не затрагивает другие
+	status, _ = userRequest(t, Token, http.MethodGet, "api/tasks", nil)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := &config.JWTConfig{Password: "password", Secret: "secret", AccessTTL: -time.Minute, RefreshTTL: time.Hour}
+	status := func(token string) int {
+		handler := services.Auth(cfg, func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	// Истёкший токен доступа отклоняется, но сессию можно продлить
+	expired, err := services.IssueToken(cfg, models.AdminID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(expired.Token))
+
+	cfg.AccessTTL = time.Minute
+	fresh, err := services.RefreshToken(cfg, expired.RefreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status(fresh.Token))
+
+	// Токен без срока действия не принимается
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(fresh.Token, claims)
+	assert.NoError(t, err)
+	delete(claims, "exp")
+	endless, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Secret))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(endless))
+
+	// Сессия, которую не продлевали дольше RefreshTTL, закрыта
+	cfg.RefreshTTL = -time.Minute
+	stale, err := services.IssueToken(cfg, models.AdminID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(stale.Token))
+	_, err = services.RefreshToken(cfg, stale.RefreshToken)
+	assert.ErrorIs(t, err, database.ErrSessionNotFound)
+}
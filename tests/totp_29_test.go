@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Контрольные значения RFC 6238 для секрета "12345678901234567890", последние шесть цифр
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		code, err := services.TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestTOTPEnrollment(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	user, err := services.RegisterUser("alice", "correct horse", "")
+	assert.NoError(t, err)
+	now := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	enrollment, err := services.EnrollTOTP(user, now)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/todolist:alice?"), enrollment.URI)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "issuer=todolist")
+
+	// До подтверждения второй фактор не включён
+	enabled, err := services.TOTPEnabled(user.ID)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = services.ConfirmTOTP(user.ID, "000000", now)
+	assert.ErrorIs(t, err, services.ErrInvalidCode)
+	code, _ := services.TOTPCode(enrollment.Secret, now)
+	codes, err := services.ConfirmTOTP(user.ID, code, now)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	_, err = services.EnrollTOTP(user, now)
+	assert.ErrorIs(t, err, services.ErrTOTPEnabled)
+
+	// Принятый код повторно не принимается, код соседнего интервала принимается
+	assert.ErrorIs(t, services.VerifySecondFactor(user.ID, models.TOTPRequest{Code: code}, now), services.ErrInvalidCode)
+	later := now.Add(30 * time.Second)
+	next, _ := services.TOTPCode(enrollment.Secret, later)
+	assert.NoError(t, services.VerifySecondFactor(user.ID, models.TOTPRequest{Code: next}, now))
+	stale, _ := services.TOTPCode(enrollment.Secret, now.Add(-5*time.Minute))
+	assert.ErrorIs(t, services.VerifySecondFactor(user.ID, models.TOTPRequest{Code: stale}, later), services.ErrInvalidCode)
+
+	// Код восстановления действует один раз и вводится в любом регистре
+	recovery := models.TOTPRequest{RecoveryCode: strings.ToUpper(codes[0])}
+	assert.NoError(t, services.VerifySecondFactor(user.ID, recovery, later))
+	assert.ErrorIs(t, services.VerifySecondFactor(user.ID, recovery, later), services.ErrInvalidCode)
+	remaining, _ := database.CountRecoveryCodes(user.ID)
+	assert.Equal(t, 9, remaining)
+
+	fresh, err := services.RegenerateRecoveryCodes(user.ID, models.TOTPRequest{RecoveryCode: codes[1]}, later)
+	assert.NoError(t, err)
+	assert.Len(t, fresh, 10)
+	assert.ErrorIs(t, services.VerifySecondFactor(user.ID, models.TOTPRequest{RecoveryCode: codes[2]}, later), services.ErrInvalidCode)
+
+	// Незавершённый вход истекает
+	attempt, err := services.StartSignin(&config.SigninConfig{}, user.Login, "192.0.2.1", later)
+	assert.NoError(t, err)
+	challenge, err := services.StartChallenge(user.ID, user.Login, attempt, later)
+	assert.NoError(t, err)
+	assert.True(t, challenge.TOTPRequired)
+	codeAt := later.Add(services.ChallengeTTL + time.Minute)
+	lateCode, _ := services.TOTPCode(enrollment.Secret, codeAt)
+	_, err = services.CompleteChallenge(models.TOTPRequest{Challenge: challenge.Challenge, Code: lateCode}, codeAt)
+	assert.ErrorIs(t, err, services.ErrChallenge)
+
+	// Отключение требует кода
+	disableAt := later.Add(time.Hour)
+	assert.ErrorIs(t, services.DisableTOTP(user.ID, models.TOTPRequest{Code: "123456"}, disableAt), services.ErrInvalidCode)
+	assert.NoError(t, services.DisableTOTP(user.ID, models.TOTPRequest{RecoveryCode: fresh[0]}, disableAt))
+	enabled, _ = services.TOTPEnabled(user.ID)
+	assert.False(t, enabled)
+}
+
+func TestTOTPSignin(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_JWT_SECRET", "secret")
+	t.Setenv("TODO_SIGNIN_PROTECTION", "off")
+	db := database.InitDb()
+	defer db.Close()
+
+	user, err := services.RegisterUser("bob", "correct horse", "")
+	assert.NoError(t, err)
+	enrolledAt := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	enrollment, err := services.EnrollTOTP(user, enrolledAt)
+	assert.NoError(t, err)
+	code, _ := services.TOTPCode(enrollment.Secret, enrolledAt)
+	recovery, err := services.ConfirmTOTP(user.ID, code, enrolledAt)
+	assert.NoError(t, err)
+
+	post := func(handler http.HandlerFunc, values any) (int, models.JWTTokenResponse) {
+		data, _ := json.Marshal(values)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data)))
+		var resp models.JWTTokenResponse
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+	password := map[string]string{"login": "bob", "password": "correct horse"}
+
+	// Пароль даёт только вызов для ввода кода
+	status, resp := post(rest.TokenHandler, password)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, resp.TOTPRequired)
+	assert.Empty(t, resp.Token)
+	assert.NotEmpty(t, resp.Challenge)
+
+	status, _ = post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, Code: "000000"})
+	assert.Equal(t, http.StatusUnauthorized, status)
+	code, _ = services.TOTPCode(enrollment.Secret, time.Now())
+	status, tokens := post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, Code: code})
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, tokens.Token)
+
+	// Вызов действует один раз
+	status, _ = post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, Code: code})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Вход по коду восстановления
+	_, resp = post(rest.TokenHandler, password)
+	status, tokens = post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, RecoveryCode: recovery[0]})
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, tokens.Token)
+
+	// После нескольких неверных кодов вызов отменяется
+	_, resp = post(rest.TokenHandler, password)
+	for i := 0; i < 5; i++ {
+		status, _ = post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, RecoveryCode: "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	}
+	status, _ = post(rest.TOTPSigninHandler, models.TOTPRequest{Challenge: resp.Challenge, RecoveryCode: recovery[1]})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// Вторым фактором управляет только сессия, открытая входом
+	rec := httptest.NewRecorder()
+	rest.EnrollTOTPHandler(rec, httptest.NewRequest(http.MethodPost, "/api/totp/enroll", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// userRequest выполняет запрос с токеном пользователя и возвращает код ответа и тело
+func userRequest(t *testing.T, token, method, apipath string, values map[string]any) (int, []byte) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	setCSRF(req, token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return resp.StatusCode, body
+}
+
+// signinUser получает токен пользователя по имени и паролю
+func signinUser(t *testing.T, login, password string) string {
+	status, body := userRequest(t, "", http.MethodPost, "api/signin", map[string]any{"login": login, "password": password})
+	assert.Equal(t, http.StatusOK, status, string(body))
+	var resp struct {
+		Token     string `json:"token"`
+		CSRFToken string `json:"csrf_token"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	rememberCSRF(resp.Token, resp.CSRFToken)
+	return resp.Token
+}
+
+func TestUsers(t *testing.T) {
+	if len(Token) == 0 {
+		t.Skip("authentication is disabled")
+	}
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	ids := map[string]float64{}
+	for _, login := range []string{"alice" + suffix, "bob" + suffix} {
+		status, body := userRequest(t, Token, http.MethodPost, "api/users",
+			map[string]any{"login": login, "password": "secret-" + login})
+		assert.Equal(t, http.StatusOK, status, string(body))
+		var user map[string]any
+		assert.NoError(t, json.Unmarshal(body, &user))
+		assert.Equal(t, "user", user["role"])
+		assert.Nil(t, user["password"])
+		ids[login] = user["id"].(float64)
+	}
+	alice, bob := "alice"+suffix, "bob"+suffix
+	defer func() {
+		for _, id := range ids {
+			status, _ := userRequest(t, Token, http.MethodDelete, fmt.Sprintf("api/users?id=%.0f", id), nil)
+			assert.Equal(t, http.StatusOK, status)
+		}
+	}()
+
+	// Имя пользователя занято, пароль короткий
+	status, _ := userRequest(t, Token, http.MethodPost, "api/users", map[string]any{"login": alice, "password": "another-password"})
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = userRequest(t, Token, http.MethodPost, "api/users", map[string]any{"login": "carol" + suffix, "password": "short"})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = userRequest(t, "", http.MethodPost, "api/signin", map[string]any{"login": alice, "password": "wrong password"})
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	aliceToken := signinUser(t, alice, "secret-"+alice)
+	bobToken := signinUser(t, bob, "secret-"+bob)
+
+	status, body := userRequest(t, aliceToken, http.MethodGet, "api/user", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), `"login":"`+alice+`"`)
+
+	// Управлять учётными записями может только администратор
+	status, _ = userRequest(t, aliceToken, http.MethodGet, "api/users", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = userRequest(t, aliceToken, http.MethodGet, "api/backup", nil)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	today := time.Now().Format(`20060102`)
+	status, body = userRequest(t, aliceToken, http.MethodPost, "api/task", map[string]any{"date": today, "title": "Задача Алисы"})
+	assert.Equal(t, http.StatusOK, status)
+	var created map[string]string
+	assert.NoError(t, json.Unmarshal(body, &created))
+	taskID := created["id"]
+
+	listTitles := func(token string) []string {
+		status, body := userRequest(t, token, http.MethodGet, "api/tasks", nil)
+		assert.Equal(t, http.StatusOK, status)
+		var list struct {
+			Tasks []map[string]any `json:"tasks"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &list))
+		var titles []string
+		for _, task := range list.Tasks {
+			titles = append(titles, task["title"].(string))
+		}
+		return titles
+	}
+	assert.Equal(t, []string{"Задача Алисы"}, listTitles(aliceToken))
+	assert.Empty(t, listTitles(bobToken))
+	assert.NotContains(t, listTitles(Token), "Задача Алисы")
+
+	// Чужая задача не видна и не изменяется, как если бы её не было
+	_, body = userRequest(t, bobToken, http.MethodGet, "api/task?id="+taskID, nil)
+	assert.Contains(t, string(body), "error")
+	_, body = userRequest(t, bobToken, http.MethodPut, "api/task",
+		map[string]any{"id": taskID, "date": today, "title": "Чужая задача"})
+	assert.Contains(t, string(body), "Task not found")
+	_, body = userRequest(t, bobToken, http.MethodDelete, "api/task?id="+taskID, nil)
+	assert.Contains(t, string(body), "Task not found")
+	_, body = userRequest(t, Token, http.MethodPost, "api/task/done?id="+taskID, nil)
+	assert.Contains(t, string(body), "Task not found")
+	_, body = userRequest(t, bobToken, http.MethodGet, "api/task/history?id="+taskID, nil)
+	assert.JSONEq(t, `{"history":[]}`, string(body))
+
+	status, body = userRequest(t, aliceToken, http.MethodGet, "api/task?id="+taskID, nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "Задача Алисы")
+
+	// Самостоятельная регистрация по умолчанию закрыта
+	status, _ = userRequest(t, "", http.MethodPost, "api/signup", map[string]any{"login": "dave" + suffix, "password": "long enough password"})
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestAuthWithoutSharedPassword(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	t.Setenv("TODO_PASSWORD", "")
+	t.Setenv("TODO_JWT_SECRET", "secret")
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := config.LoadJWTConfig()
+	var caller models.User
+	handler := func(w http.ResponseWriter, r *http.Request) { caller = services.CurrentUser(r.Context()) }
+	status := func(wrap func(*config.JWTConfig, http.HandlerFunc) http.HandlerFunc, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/keys", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		wrap(cfg, handler)(rec, req)
+		return rec.Code
+	}
+
+	// Без учётных данных аутентификация отключена, но вызывающая сторона не получает роль администратора
+	assert.Equal(t, http.StatusOK, status(services.Auth, ""))
+	assert.Equal(t, models.AdminID, caller.ID)
+	assert.Equal(t, models.RoleUser, caller.Role)
+	assert.Equal(t, http.StatusForbidden, status(services.Admin, ""))
+	assert.Equal(t, http.StatusForbidden, status(services.Account, ""))
+
+	// Учётная запись пользователя включает аутентификацию и без TODO_PASSWORD
+	_, err := services.RegisterUser("alice", "correct horse", "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(services.Auth, ""))
+
+	data, _ := json.Marshal(map[string]string{"login": "alice", "password": "correct horse"})
+	rec := httptest.NewRecorder()
+	rest.TokenHandler(rec, httptest.NewRequest(http.MethodPost, "/api/signin", bytes.NewReader(data)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var tokens models.JWTTokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tokens))
+	assert.Equal(t, http.StatusOK, status(services.Account, tokens.Token))
+	assert.Equal(t, "alice", caller.Login)
+	assert.Equal(t, http.StatusForbidden, status(services.Admin, tokens.Token))
+
+	forged, err := services.IssueToken(&config.JWTConfig{Secret: "other", AccessTTL: time.Minute, RefreshTTL: time.Hour}, models.AdminID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(services.Auth, forged.Token))
+}
@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskValidationStatus(t *testing.T) {
+	now := time.Now().Format(`20060102`)
+	id := addTask(t, task{date: now, title: "Проверка задачи"})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	// Добавление и изменение проверяются одинаково, ошибки возвращаются с кодом 400
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		for _, values := range []map[string]any{
+			{"id": id, "date": "20240192", "title": "Qwerty"},
+			{"id": id, "date": "20240212", "title": ""},
+			{"id": id, "date": "20240212", "title": "Заголовок", "repeat": "ooops"},
+		} {
+			resp, _, err := requestWithHeaders("api/task", values, method, nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "%s %v", method, values)
+			}
+		}
+	}
+
+	// Пустая дата при изменении отклоняется, а при добавлении заменяется на сегодняшнюю
+	resp, _, err := requestWithHeaders("api/task", map[string]any{"id": id, "date": "", "title": "Без даты"}, http.MethodPut, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+	for _, values := range []map[string]any{{"title": "Без ID"}, {"id": "abc", "title": "Неверный ID"}} {
+		values["date"] = now
+		resp, _, err := requestWithHeaders("api/task", values, http.MethodPut, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "%v", values)
+		}
+	}
+
+	created := addTask(t, task{title: "Без даты"})
+	defer requestJSON("api/task?id="+created, nil, http.MethodDelete)
+	body, err := requestJSON("api/task?id="+created, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"date":"`+now+`"`)
+}
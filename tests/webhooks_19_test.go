@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver запускает локальный приёмник webhook. status возвращает код ответа
+// для попытки с указанным номером
+func webhookReceiver(t *testing.T, status func(attempt int32) int) (*httptest.Server, <-chan webhookRequest) {
+	requests := make(chan webhookRequest, 16)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status(attempts.Add(1)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func TestWebhooks(t *testing.T) {
+	srv, requests := webhookReceiver(t, func(int32) int { return http.StatusNoContent })
+
+	m, err := postJSON("api/webhooks", map[string]any{"url": "ftp://example.com"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	m, err = postJSON("api/webhooks", map[string]any{"url": srv.URL, "events": []string{"done"}}, http.MethodPost)
+	assert.NoError(t, err)
+	if m["error"] == services.ErrWebhookAddress.Error() {
+		t.Skip("server does not deliver webhooks to local addresses, run it with TODO_WEBHOOK_ALLOW_PRIVATE=on")
+	}
+	assert.Empty(t, m["error"])
+	hookID, _ := m["id"].(string)
+	secret, _ := m["secret"].(string)
+	assert.NotEmpty(t, hookID)
+	assert.NotEmpty(t, secret)
+	defer func() {
+		_, err := postJSON("api/webhooks?id="+hookID, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}()
+
+	id := addTask(t, task{
+		date:  time.Now().Format(`20060102`),
+		title: "Проверить webhook",
+	})
+	_, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+
+	var req webhookRequest
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook не получен")
+	}
+
+	// Создание задачи не отправляется, так как webhook подписан только на выполнение
+	assert.Equal(t, "done", req.header.Get("X-Todo-Event"))
+	assert.Equal(t, services.SignWebhook(secret, req.body), req.header.Get("X-Todo-Signature"))
+	var payload models.WebhookPayload
+	assert.NoError(t, json.Unmarshal(req.body, &payload))
+	assert.Equal(t, "done", payload.Event)
+	assert.Equal(t, id, payload.TaskID)
+
+	// Журнал обновляется после ответа приёмника
+	var deliveries []models.WebhookDelivery
+	assert.Eventually(t, func() bool {
+		body, err := requestJSON("api/webhooks/deliveries?id="+hookID, nil, http.MethodGet)
+		if err != nil {
+			return false
+		}
+		var res struct {
+			Deliveries []models.WebhookDelivery `json:"deliveries"`
+		}
+		if json.Unmarshal(body, &res) != nil {
+			return false
+		}
+		deliveries = res.Deliveries
+		return len(deliveries) == 1 && deliveries[0].Status == models.DeliveryDelivered
+	}, 5*time.Second, 100*time.Millisecond)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].LastStatus)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	// Приёмник отвечает ошибкой на первую попытку
+	srv, requests := webhookReceiver(t, func(attempt int32) int {
+		if attempt == 1 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	hookID, err := database.AddWebhook(models.Webhook{URL: srv.URL, Secret: "secret", Owner: models.AdminID})
+	assert.NoError(t, err)
+
+	_, err = services.CreateTask(context.Background(), models.Task{
+		Date:  time.Now().Format(`20060102`),
+		Title: "Повторная доставка",
+	})
+	assert.NoError(t, err)
+
+	cfg := &config.WebhookConfig{RetryBase: time.Minute, MaxAttempts: 3, Timeout: time.Second, AllowPrivate: true}
+	now := time.Now()
+
+	delivered, err := services.DeliverWebhooks(context.Background(), cfg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	req := <-requests
+	assert.Equal(t, "created", req.header.Get("X-Todo-Event"))
+
+	deliveries, err := database.GetDeliveries(models.AdminID, strconv.Itoa(hookID), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, models.DeliveryPending, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].LastStatus)
+	}
+
+	// До истечения задержки повторная попытка не выполняется
+	delivered, err = services.DeliverWebhooks(context.Background(), cfg, now.Add(30*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+
+	delivered, err = services.DeliverWebhooks(context.Background(), cfg, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	<-requests
+
+	deliveries, err = database.GetDeliveries(models.AdminID, strconv.Itoa(hookID), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, models.DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, 2, deliveries[0].Attempts)
+	}
+}
+
+func TestWebhookPrivateAddress(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := &config.WebhookConfig{RetryBase: time.Minute, MaxAttempts: 1, Timeout: time.Second}
+	for _, host := range []string{"localhost", "127.0.0.1", "10.1.2.3", "192.168.0.10", "169.254.169.254", "::1", "fd00::1", "100.64.0.1"} {
+		assert.ErrorIs(t, services.CheckWebhookHost(cfg, host), services.ErrWebhookAddress, host)
+	}
+	assert.NoError(t, services.CheckWebhookHost(cfg, "example.com"))
+	assert.NoError(t, services.CheckWebhookHost(cfg, "93.184.216.34"))
+	assert.NoError(t, services.CheckWebhookHost(&config.WebhookConfig{AllowPrivate: true}, "127.0.0.1"))
+
+	// Имя, которое разрешается во внутренний адрес, отклоняется при соединении
+	srv, requests := webhookReceiver(t, func(int32) int { return http.StatusOK })
+	target := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	hookID, err := database.AddWebhook(models.Webhook{URL: target, Secret: "secret", Owner: models.AdminID})
+	assert.NoError(t, err)
+	_, err = services.CreateTask(context.Background(), models.Task{Date: time.Now().Format(`20060102`), Title: "Внутренний адрес"})
+	assert.NoError(t, err)
+
+	delivered, err := services.DeliverWebhooks(context.Background(), cfg, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	assert.Empty(t, requests)
+
+	deliveries, err := database.GetDeliveries(models.AdminID, strconv.Itoa(hookID), 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, models.DeliveryFailed, deliveries[0].Status)
+		assert.Contains(t, deliveries[0].LastError, services.ErrWebhookAddress.Error())
+	}
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-rest/internal/config"
+	"todo-rest/internal/database"
+	"todo-rest/internal/models"
+	"todo-rest/internal/services"
+	"todo-rest/internal/transport/rest"
+	"todo-rest/internal/transport/ws"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type wsMessage struct {
+	Type   string         `json:"type"`
+	ID     string         `json:"id"`
+	Status int            `json:"status"`
+	Error  string         `json:"error"`
+	Task   map[string]any `json:"task"`
+	ETag   string         `json:"etag"`
+	Tasks  []any          `json:"tasks"`
+	// RetryAfter — задержка операции, отклонённой ограничением частоты
+	RetryAfter int `json:"retry_after"`
+	Event  struct {
+		Type   string `json:"type"`
+		TaskID string `json:"task_id"`
+	} `json:"event"`
+}
+
+// wsCall отправляет операцию и ждёт ответ с тем же ID, пропуская уведомления
+func wsCall(t *testing.T, conn *websocket.Conn, req map[string]any) wsMessage {
+	assert.NoError(t, conn.WriteJSON(req))
+	for {
+		var msg wsMessage
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&msg); !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if msg.Type == "result" && msg.ID == req["id"] {
+			return msg
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	header := http.Header{}
+	if len(Token) > 0 {
+		header.Set("Cookie", "token="+Token)
+	}
+	url := "ws" + strings.TrimPrefix(getURL("api/ws"), "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	// Второй клиент получает уведомления об изменениях
+	watcher, _, err := websocket.DefaultDialer.Dial(url, header)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer watcher.Close()
+
+	now := time.Now().Format(`20060102`)
+	created := wsCall(t, conn, map[string]any{
+		"id": "1", "op": "create",
+		"task": map[string]any{"date": now, "title": "Задача по WebSocket", "repeat": "d 3"},
+	})
+	assert.Equal(t, http.StatusOK, created.Status)
+	assert.Empty(t, created.Error)
+	id, _ := created.Task["id"].(string)
+	assert.NotEmpty(t, id)
+
+	for {
+		var msg wsMessage
+		_ = watcher.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if !assert.NoError(t, watcher.ReadJSON(&msg)) {
+			t.FailNow()
+		}
+		if msg.Type == "event" && msg.Event.TaskID == id {
+			assert.Equal(t, "created", msg.Event.Type)
+			break
+		}
+	}
+
+	res := wsCall(t, conn, map[string]any{"id": "2", "op": "create", "task": map[string]any{"date": now}})
+	assert.Equal(t, http.StatusBadRequest, res.Status)
+	assert.NotEmpty(t, res.Error)
+
+	res = wsCall(t, conn, map[string]any{
+		"id": "3", "op": "update", "if_match": created.ETag,
+		"task": map[string]any{"id": id, "date": now, "title": "Задача по WebSocket изменена", "repeat": "d 3"},
+	})
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.NotEqual(t, created.ETag, res.ETag)
+
+	res = wsCall(t, conn, map[string]any{"id": "4", "op": "done", "task_id": id, "if_match": created.ETag})
+	assert.Equal(t, http.StatusPreconditionFailed, res.Status)
+
+	res = wsCall(t, conn, map[string]any{"id": "5", "op": "list", "search": "WebSocket"})
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.Len(t, res.Tasks, 1)
+
+	res = wsCall(t, conn, map[string]any{"id": "6", "op": "delete", "task_id": id})
+	assert.Equal(t, http.StatusOK, res.Status)
+
+	res = wsCall(t, conn, map[string]any{"id": "7", "op": "get", "task_id": id})
+	assert.Equal(t, http.StatusNotFound, res.Status)
+
+	res = wsCall(t, conn, map[string]any{"id": "8", "op": "unknown"})
+	assert.Equal(t, http.StatusBadRequest, res.Status)
+}
+
+func TestWebSocketRevoked(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TODO_DBFILE", filepath.Join(dir, "scheduler.db"))
+	db := database.InitDb()
+	defer db.Close()
+
+	cfg := &config.JWTConfig{Password: "password", Secret: "secret", AccessTTL: time.Minute, RefreshTTL: time.Hour}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws", services.Auth(cfg, ws.Handler))
+	mux.HandleFunc("/api/logout", services.Auth(cfg, rest.LogoutHandler))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tokens, err := services.IssueToken(cfg, models.AdminID, "")
+	assert.NoError(t, err)
+	header := http.Header{"Authorization": {"Bearer " + tokens.Token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", header)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	res := wsCall(t, conn, map[string]any{"id": "1", "op": "list"})
+	assert.Equal(t, http.StatusOK, res.Status)
+
+	// После выхода открытое соединение не выполняет операции и закрывается
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/api/logout", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	res = wsCall(t, conn, map[string]any{"id": "2", "op": "list"})
+	assert.Equal(t, http.StatusUnauthorized, res.Status)
+	assert.Empty(t, res.Tasks)
+
+	var msg wsMessage
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	assert.True(t, websocket.IsCloseError(conn.ReadJSON(&msg), websocket.CloseNormalClosure))
+}
@@ -0,0 +1,61 @@
+// Продление сессии: токен доступа действует недолго, поэтому при ответе 401
+// запрос повторяется после обмена refresh-токена из куки на новую пару токенов
+(function () {
+    let refreshing = null;
+
+    // Защита от CSRF: изменяющие запросы передают CSRF-токен сессии из куки csrf_token
+    // в заголовке X-CSRF-Token, который чужой сайт прочитать и подставить не может
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : "";
+    }
+
+    axios.interceptors.request.use(function (config) {
+        const method = (config.method || "get").toLowerCase();
+        const token = csrfToken();
+        if (token && ["get", "head", "options"].indexOf(method) === -1) {
+            config.headers = config.headers || {};
+            config.headers["X-CSRF-Token"] = token;
+        }
+        return config;
+    });
+
+    // Второй шаг входа: если для учётной записи включён второй фактор, пароль даёт
+    // только вызов, который обменивается на токены по коду из приложения-аутентификатора
+    axios.interceptors.response.use(function (response) {
+        if (!response.data || !response.data.totp_required || response.config.url.indexOf("api/signin") === -1) {
+            return response;
+        }
+        const code = (window.prompt("Код из приложения-аутентификатора или код восстановления") || "").trim();
+        if (!code) {
+            return {data: {error: "Требуется код второго фактора"}};
+        }
+        const body = {challenge: response.data.challenge};
+        if (/^\d{6}$/.test(code)) {
+            body.code = code;
+        } else {
+            body.recovery_code = code;
+        }
+        return axios.post("/api/signin/totp", body);
+    });
+
+    axios.interceptors.response.use(null, function (error) {
+        const config = error.config;
+        if (!error.response || error.response.status !== 401 || !config || config.retried ||
+            config.url.indexOf("api/token/refresh") !== -1 || config.url.indexOf("api/signin") !== -1) {
+            return Promise.reject(error);
+        }
+        config.retried = true;
+
+        if (!refreshing) {
+            refreshing = axios.post("/api/token/refresh").finally(function () {
+                refreshing = null;
+            });
+        }
+        return refreshing.then(function () {
+            return axios(config);
+        }, function () {
+            return Promise.reject(error);
+        });
+    });
+})();